@@ -0,0 +1,473 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/italolelis/seedbox_downloader/internal/downloader"
+	"github.com/italolelis/seedbox_downloader/internal/http/rest"
+	"github.com/italolelis/seedbox_downloader/internal/logctx"
+	"github.com/italolelis/seedbox_downloader/internal/storage"
+	"github.com/italolelis/seedbox_downloader/internal/storage/sqlite"
+	"github.com/italolelis/seedbox_downloader/internal/telemetry"
+	"github.com/italolelis/seedbox_downloader/internal/transfer"
+)
+
+// errUsage reports arguments a command cannot run with. The usage has been
+// printed by then.
+var errUsage = errors.New("invalid usage")
+
+// command is a subcommand of the binary.
+type command struct {
+	name string
+	// args describes its flags and arguments, for the usage.
+	args    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+// commands lists the subcommands in the order the usage gives them. serve is
+// run when none is named.
+func commands() []command {
+	return []command{
+		{"serve", "[--once] [--dry-run]", "Run the service: poll, download, and serve the Transmission RPC and API (the default)", serveCommand},
+		{"doctor", "", "Check the configuration and every dependency, and print a report", doctorCommand},
+		{"list", "[--json]", "List transfers with where each stands, from the database and the seedbox", listCommand},
+		{"retry", "<id>", "Release a failed or missing transfer for the next poll to claim", retryCommand},
+		{"forget", "<id>", "Delete a transfer and its history from the database", forgetCommand},
+		{"download", "[--force] [--dry-run] <id>", "Download one transfer into DOWNLOAD_DIR now", downloadCommand},
+		{"db", "export [file] | import [--replace] <file>", "Export the database as JSON, or import an export", dbCommand},
+		{"version", "", "Print the version", versionCommand},
+	}
+}
+
+// runCommand runs the subcommand args name, or serve.
+func runCommand(ctx context.Context, args []string) (string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help" {
+		return "serve", serveCommand(ctx, args)
+	}
+
+	name := args[0]
+
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage(os.Stdout)
+
+		return name, nil
+	}
+
+	for _, c := range commands() {
+		if c.name == name {
+			return name, c.run(ctx, args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+
+	return name, errUsage
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprint(w, "Usage: seedbox_downloader [command] [arguments]\n\nCommands:\n")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range commands() {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", c.name, c.args, c.summary)
+	}
+
+	tw.Flush()
+
+	fmt.Fprint(w, "\nEvery command reads the configuration as serve does, from CONFIG_FILE and the environment.\n")
+}
+
+// parseArgs parses fs's flags out of args, and checks that n arguments are left.
+// A negative n takes up to -n.
+func parseArgs(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+
+		return nil, errUsage
+	}
+
+	if fs.NArg() == n || n < 0 && fs.NArg() <= -n {
+		return fs.Args(), nil
+	}
+
+	fs.Usage()
+
+	return nil, errUsage
+}
+
+// newFlagSet creates the flags of the command name, whose usage is usage.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: seedbox_downloader %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// setupCommand loads the configuration for a command other than serve, logging
+// to stderr so stdout is left to what the command prints: warnings and errors
+// only, unless LOG_LEVEL is DEBUG.
+func setupCommand(ctx context.Context) (context.Context, *config, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	level := slog.LevelWarn
+	if cfg.LogLevel.Level() <= slog.LevelDebug {
+		level = cfg.LogLevel.Level()
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	slog.SetDefault(logger)
+
+	return logctx.WithLogger(ctx, logger), cfg, nil
+}
+
+// commandTelemetry exports nothing: a one-off command has no business reporting
+// metrics as though it were the service.
+func commandTelemetry(ctx context.Context, cfg *config) (*telemetry.Telemetry, error) {
+	return telemetry.New(ctx, telemetry.Config{ServiceName: cfg.Telemetry.ServiceName, ServiceVersion: version})
+}
+
+// openLedger opens the database as the service does, for a command. The
+// function returned closes it.
+func openLedger(ctx context.Context, cfg *config, tel *telemetry.Telemetry) (*sqlite.InstrumentedDownloadRepository, func(), error) {
+	database, err := openDatabase(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sqlite.NewInstrumentedDownloadRepository(database, tel), func() { database.Close() }, nil
+}
+
+func serveCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("serve", "[--once] [--dry-run]")
+	once := fs.Bool("once", false, "poll once, download and check on what there is, and exit; for cron")
+	dryRun := fs.Bool("dry-run", false, "log what would be claimed, downloaded, deleted and removed, and do none of it; as DRY_RUN")
+
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	return run(ctx, runOptions{once: *once, dryRun: *dryRun})
+}
+
+func doctorCommand(ctx context.Context, args []string) error {
+	if _, err := parseArgs(newFlagSet("doctor", ""), args, 0); err != nil {
+		return err
+	}
+
+	if !runDoctor(ctx, os.Stdout) {
+		return errors.New("not every check passed")
+	}
+
+	return nil
+}
+
+func versionCommand(_ context.Context, args []string) error {
+	if _, err := parseArgs(newFlagSet("version", ""), args, 0); err != nil {
+		return err
+	}
+
+	fmt.Printf("seedbox_downloader %s (%s, %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+	return nil
+}
+
+// listCommand lists what GET /api/v1/transfers does. A download client that
+// cannot be reached leaves the database to be listed alone.
+func listCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("list", "[--json]")
+	asJSON := fs.Bool("json", false, "print the transfers as JSON, as the API reports them")
+
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	ctx, cfg, err := setupCommand(ctx)
+	if err != nil {
+		return err
+	}
+
+	tel, err := commandTelemetry(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	repo, closeDB, err := openLedger(ctx, cfg, tel)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	// The failure is logged by connectDownloadClient.
+	var seedbox rest.TransferLister
+	if dc, _, err := connectDownloadClient(ctx, cfg, tel); err == nil {
+		seedbox = dc
+	}
+
+	transfers, err := rest.ListTransfers(ctx, repo, seedbox, cfg.TargetLabel)
+	if err != nil {
+		return fmt.Errorf("failed to read the database: %w", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(map[string][]rest.APITransfer{"transfers": transfers})
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPIPELINE\tSEEDBOX\tSIZE\tCLAIMED\tNAME")
+
+	for _, t := range transfers {
+		seedboxStatus, size := "-", "-"
+		if t.OnSeedbox {
+			seedboxStatus, size = t.SeedboxStatus, humanize.Bytes(uint64(max(t.Size, 0)))
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", t.ID, t.PipelineStatus, seedboxStatus, size, orDash(t.ClaimedAt), orDash(t.Name))
+	}
+
+	return tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+// retryCommand does what POST /api/v1/transfers/{id}/retry does. A running
+// service claims the transfer on its next poll.
+func retryCommand(ctx context.Context, args []string) error {
+	args, err := parseArgs(newFlagSet("retry", "<id>"), args, 1)
+	if err != nil {
+		return err
+	}
+
+	ctx, cfg, err := setupCommand(ctx)
+	if err != nil {
+		return err
+	}
+
+	tel, err := commandTelemetry(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	repo, closeDB, err := openLedger(ctx, cfg, tel)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	previous, err := storage.RetryTransfer(repo, args[0])
+	if err != nil {
+		return ledgerError(args[0], err)
+	}
+
+	fmt.Printf("transfer %s released from %s; the next poll claims it\n", args[0], previous)
+
+	return nil
+}
+
+// forgetCommand does what DELETE /api/v1/transfers/{id} does.
+func forgetCommand(ctx context.Context, args []string) error {
+	args, err := parseArgs(newFlagSet("forget", "<id>"), args, 1)
+	if err != nil {
+		return err
+	}
+
+	ctx, cfg, err := setupCommand(ctx)
+	if err != nil {
+		return err
+	}
+
+	tel, err := commandTelemetry(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	repo, closeDB, err := openLedger(ctx, cfg, tel)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	if err := repo.ForgetTransfer(args[0]); err != nil {
+		return ledgerError(args[0], err)
+	}
+
+	fmt.Printf("transfer %s forgotten\n", args[0])
+
+	return nil
+}
+
+// ledgerError names the transfer id in err, from the ledger.
+func ledgerError(id string, err error) error {
+	if errors.Is(err, storage.ErrTransferNotFound) {
+		return fmt.Errorf("transfer %s is not in the database", id)
+	}
+
+	return fmt.Errorf("transfer %s: %w", id, err)
+}
+
+// downloadCommand downloads one transfer into its Local Layout, claiming it as
+// a poll would, so a running service leaves it alone, and recording how it went.
+// Its import is left to the *arr apps' completed download handling: nothing
+// watches for it, and the transfer stays on the seedbox.
+func downloadCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("download", "[--force] [--dry-run] <id>")
+	force := fs.Bool("force", false, "download a transfer already downloaded, or claimed by another run, again")
+	dryRun := fs.Bool("dry-run", false, "print what would be written, and neither claim nor download anything; as DRY_RUN")
+
+	args, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	id := args[0]
+
+	ctx, cfg, err := setupCommand(ctx)
+	if err != nil {
+		return err
+	}
+
+	tel, err := commandTelemetry(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	repo, closeDB, err := openLedger(ctx, cfg, tel)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	dc, tc, err := connectDownloadClient(ctx, cfg, tel)
+	if err != nil {
+		return err
+	}
+
+	t, err := findTransfer(ctx, dc, cfg.TargetLabel, id)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun || cfg.DryRun {
+		printDryRunDownload(cfg.DownloadDir, downloader.NewDownloader(cfg.DownloadDir, cfg.MaxParallel, dc, tc, nil), t)
+
+		return nil
+	}
+
+	if err := claimForDownload(repo, id, *force); err != nil {
+		return err
+	}
+
+	dl := downloader.NewDownloader(cfg.DownloadDir, cfg.MaxParallel, dc, tc, nil)
+
+	fmt.Printf("downloading %s: %d files, %s, to %s\n", t.Name, len(t.Files), humanize.Bytes(uint64(max(t.TotalSize(), 0))), dl.LocalPath(t))
+
+	if _, err := dl.DownloadTransfer(ctx, t); err != nil {
+		if updateErr := repo.UpdateTransferStatus(id, "failed"); updateErr != nil {
+			logctx.LoggerFromContext(ctx).ErrorContext(ctx, "failed to update transfer status", "transfer_id", id, "err", updateErr)
+		}
+
+		return fmt.Errorf("failed to download transfer %s: %w", id, err)
+	}
+
+	if err := repo.UpdateTransferStatus(id, "downloaded"); err != nil {
+		return fmt.Errorf("transfer %s downloaded, but not recorded as such: %w", id, err)
+	}
+
+	fmt.Printf("downloaded %s\n", dl.LocalPath(t))
+
+	return nil
+}
+
+// printDryRunDownload prints where each of t's files would be written, and how
+// large it is, for download --dry-run.
+func printDryRunDownload(downloadDir string, dl *downloader.Downloader, t *transfer.Transfer) {
+	fmt.Printf("dry run: would download %s: %d files, %s, to %s\n",
+		t.Name, len(t.Files), humanize.Bytes(uint64(max(t.TotalSize(), 0))), dl.LocalPath(t))
+
+	for _, file := range t.Files {
+		fmt.Printf("  %s\t%s\n", filepath.Join(downloadDir, file.Path), humanize.Bytes(uint64(max(file.Size, 0))))
+	}
+}
+
+// findTransfer looks the transfer id up under label, and checks it is complete.
+func findTransfer(ctx context.Context, dc transfer.DownloadClient, label, id string) (*transfer.Transfer, error) {
+	transfers, err := dc.GetTaggedTorrents(ctx, label)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list seedbox transfers: %w", err)
+	}
+
+	for _, t := range transfers {
+		if t.ID != id {
+			continue
+		}
+
+		if !t.IsAvailable() || !t.IsDownloadable() {
+			return nil, fmt.Errorf("transfer %s is not ready to download: it is %s on the seedbox", id, t.Status)
+		}
+
+		return t, nil
+	}
+
+	return nil, fmt.Errorf("transfer %s not found on the seedbox under the label %q", id, label)
+}
+
+// claimForDownload claims the transfer id, as a poll does. force releases it
+// first, whatever state it is in.
+func claimForDownload(repo *sqlite.InstrumentedDownloadRepository, id string, force bool) error {
+	if force {
+		if err := repo.ResetTransfer(id); err != nil && !errors.Is(err, storage.ErrTransferNotFound) {
+			return fmt.Errorf("failed to release transfer %s: %w", id, err)
+		}
+	}
+
+	claimed, err := repo.ClaimTransfer(id)
+	if errors.Is(err, storage.ErrDownloaded) {
+		return fmt.Errorf("transfer %s is already downloaded; use --force to download it again", id)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to claim transfer %s: %w", id, err)
+	}
+
+	if !claimed {
+		record, err := repo.GetDownload(id)
+		if err != nil {
+			return fmt.Errorf("failed to claim transfer %s: %w", id, err)
+		}
+
+		return fmt.Errorf("transfer %s is %s; use --force to download it anyway", id, record.Status)
+	}
+
+	return nil
+}
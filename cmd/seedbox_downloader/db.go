@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/italolelis/seedbox_downloader/internal/storage/sqlite"
+)
+
+// dbCommand exports the database at DB_PATH as JSON, or imports an export into
+// it, for backups and for moving the service to another host.
+func dbCommand(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			return dbExport(ctx, args[1:], os.Stdout)
+		case "import":
+			return dbImport(ctx, args[1:], os.Stdin, os.Stdout)
+		}
+	}
+
+	fmt.Fprint(os.Stderr, "Usage: seedbox_downloader db export [file]\n       seedbox_downloader db import [--replace] <file>\n")
+
+	return errUsage
+}
+
+// dbExport writes the export to the file named, reporting it on out, or to out
+// itself.
+func dbExport(ctx context.Context, args []string, out io.Writer) error {
+	args, err := parseArgs(newFlagSet("db export", "[file]"), args, -1)
+	if err != nil {
+		return err
+	}
+
+	ctx, cfg, err := setupCommand(ctx)
+	if err != nil {
+		return err
+	}
+
+	database, err := openDatabase(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	dump, err := sqlite.NewDownloadRepository(database).Export(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 || args[0] == "-" {
+		return writeDump(out, dump)
+	}
+
+	f, err := os.Create(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to create the export: %w", err)
+	}
+
+	if err := writeDump(f, dump); err != nil {
+		f.Close()
+
+		return err
+	}
+
+	// Closed explicitly rather than deferred, as a failed final write is a failed
+	// export.
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write the export: %w", err)
+	}
+
+	fmt.Fprintf(out, "exported %d transfers and %d notifications to %s\n", len(dump.Downloads), len(dump.Notifications), args[0])
+
+	return nil
+}
+
+func writeDump(w io.Writer, dump sqlite.Dump) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(dump); err != nil {
+		return fmt.Errorf("failed to write the export: %w", err)
+	}
+
+	return nil
+}
+
+// dbImport reads an export from the file named, or in for "-", and reports
+// what it imported to out. The service should be stopped first: what it holds
+// in memory is not reloaded.
+func dbImport(ctx context.Context, args []string, in io.Reader, out io.Writer) error {
+	fs := newFlagSet("db import", "[--replace] <file>")
+	replace := fs.Bool("replace", false, "delete everything in the database before importing")
+
+	args, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open the export: %w", err)
+		}
+		defer f.Close()
+
+		in = f
+	}
+
+	var dump sqlite.Dump
+	if err := json.NewDecoder(in).Decode(&dump); err != nil {
+		return fmt.Errorf("failed to read the export: %w", err)
+	}
+
+	ctx, cfg, err := setupCommand(ctx)
+	if err != nil {
+		return err
+	}
+
+	database, err := openDatabase(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	err = sqlite.NewDownloadRepository(database).Import(ctx, dump, *replace)
+	if errors.Is(err, sqlite.ErrNotEmpty) {
+		return fmt.Errorf("%w; use --replace to delete what is in %s and import the export in its place", err, cfg.DBPath)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "imported %d transfers and %d notifications into %s\n", len(dump.Downloads), len(dump.Notifications), cfg.DBPath)
+
+	return nil
+}
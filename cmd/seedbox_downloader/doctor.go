@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/italolelis/seedbox_downloader/internal/doctor"
+	"github.com/italolelis/seedbox_downloader/internal/health"
+	"github.com/italolelis/seedbox_downloader/internal/logctx"
+	"github.com/italolelis/seedbox_downloader/internal/notifier"
+	"github.com/italolelis/seedbox_downloader/internal/storage/sqlite"
+	"github.com/italolelis/seedbox_downloader/internal/svc/arr"
+	"github.com/italolelis/seedbox_downloader/internal/transfer"
+)
+
+// runDoctor checks everything the service depends on, sending a test
+// notification to every target, and prints the report to w. It reports whether
+// every check passed.
+func runDoctor(ctx context.Context, w io.Writer) bool {
+	fmt.Fprintf(w, "seedbox_downloader %s doctor\n\n", version)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		report := doctor.Report{Results: []doctor.Result{{
+			Name: "configuration", Status: doctor.Fail, Detail: err.Error(),
+			Remedy: "Fix the config file or the environment as the error says.",
+		}}}
+		report.Print(w)
+
+		return false
+	}
+
+	// The report says all there is to say; the clients' own logs are only
+	// wanted when debugging.
+	logs := io.Discard
+	if cfg.LogLevel.Level() <= slog.LevelDebug {
+		logs = os.Stderr
+	}
+
+	ctx = logctx.WithLogger(ctx, slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: cfg.LogLevel})))
+
+	report := doctor.Run(ctx, doctorChecks(ctx, cfg, false))
+	report.Print(w)
+
+	return report.Passed()
+}
+
+// validateStartup runs the checks a service can be started without, logging
+// what it finds, and fails if one it cannot do without failed.
+func validateStartup(ctx context.Context, cfg *config) error {
+	logger := logctx.LoggerFromContext(ctx).With("component", "doctor")
+
+	report := doctor.Run(ctx, doctorChecks(ctx, cfg, true))
+	report.Log(ctx, logger)
+
+	if report.Failed() {
+		return errors.New("startup validation failed; run the doctor command for a full report")
+	}
+
+	return nil
+}
+
+// doctorChecks lists the checks of everything the service depends on. At
+// startup the database is left for the services to open, no notification is
+// sent, and what the service can run without only warns.
+func doctorChecks(ctx context.Context, cfg *config, startup bool) []doctor.Check {
+	checks := []doctor.Check{
+		configurationCheck(),
+		targetLabelCheck(cfg),
+		arrInstancesCheck(cfg),
+		notifyTargetsCheck(cfg),
+	}
+
+	if !startup {
+		checks = append(checks, databaseCheck(cfg))
+	}
+
+	checks = append(checks, downloadClientChecks(cfg, startup)...)
+
+	for _, client := range quietArrClients(ctx, cfg) {
+		check := arrCheck(client)
+		check.Optional = startup
+		checks = append(checks, check)
+	}
+
+	if !startup {
+		checks = append(checks, notifySendChecks(cfg)...)
+	}
+
+	return append(checks, downloadDirChecks(cfg, startup)...)
+}
+
+func configurationCheck() doctor.Check {
+	return doctor.Check{
+		Name: "configuration",
+		Run: func(context.Context) (string, error) {
+			if path := os.Getenv("CONFIG_FILE"); path != "" {
+				return path + " and the environment", nil
+			}
+
+			return "the environment", nil
+		},
+	}
+}
+
+func targetLabelCheck(cfg *config) doctor.Check {
+	return doctor.Check{
+		Name: "target_label",
+		Run: func(context.Context) (string, error) {
+			if cfg.TargetLabel == "" {
+				return "", errors.New("TARGET_LABEL is empty")
+			}
+
+			return cfg.TargetLabel, nil
+		},
+		Remedy: "Set TARGET_LABEL to the label the *arr apps file transfers under: the Put.io folder, or the Deluge label.",
+	}
+}
+
+// arrInstancesCheck fails an instance with a URL and no API key. One with a key
+// and no URL is skipped, as it is at startup: API_KEY also fills in the
+// shorthands' keys.
+func arrInstancesCheck(cfg *config) doctor.Check {
+	return doctor.Check{
+		Name: "arr_instances",
+		Run: func(ctx context.Context) (string, error) {
+			for _, instance := range cfg.instances() {
+				if instance.URL != "" && instance.APIKey == "" {
+					name := instance.Name
+					if name == "" {
+						name = instance.Type
+					}
+
+					return "", fmt.Errorf("*arr instance %q has a URL but no API key", name)
+				}
+			}
+
+			clients, err := quietBuild(ctx, cfg)
+			if err != nil {
+				return "", err
+			}
+
+			if len(clients) == 0 {
+				return "none configured", nil
+			}
+
+			names := make([]string, 0, len(clients))
+			for _, client := range clients {
+				names = append(names, client.Name())
+			}
+
+			return strings.Join(names, ", "), nil
+		},
+		Remedy: "Give every *arr instance in ARR_INSTANCES, SONARR_* and RADARR_* a URL and an API key, " +
+			"a known type and a name of its own, and path mappings that round-trip DOWNLOAD_DIR.",
+	}
+}
+
+func notifyTargetsCheck(cfg *config) doctor.Check {
+	return doctor.Check{
+		Name: "notify_targets",
+		Run: func(context.Context) (string, error) {
+			targets := cfg.targets()
+			if len(targets) == 0 {
+				return "none configured", nil
+			}
+
+			labels := make([]string, 0, len(targets))
+
+			for _, target := range targets {
+				if _, err := notifier.New(target); err != nil {
+					return "", err
+				}
+
+				if _, err := target.Rules(); err != nil {
+					return "", err
+				}
+
+				labels = append(labels, target.Label())
+			}
+
+			return strings.Join(labels, ", "), nil
+		},
+		Remedy: "Fix the target in NOTIFY_TARGETS as the error says.",
+	}
+}
+
+func databaseCheck(cfg *config) doctor.Check {
+	return doctor.Check{
+		Name: "database",
+		Run: func(ctx context.Context) (string, error) {
+			db, err := sqlite.InitDB(ctx, cfg.DBPath, 1, 1)
+			if err != nil {
+				return "", err
+			}
+
+			db.Close()
+
+			return cfg.DBPath + ", schema up to date", nil
+		},
+		Remedy: "Check DB_PATH: its directory must exist and be writable, and no other process may hold the database locked.",
+	}
+}
+
+// downloadClientChecks authenticates with the download client, and then checks
+// the label exists on it.
+func downloadClientChecks(cfg *config, startup bool) []doctor.Check {
+	var (
+		dc      transfer.DownloadClient
+		authErr = errors.New("not checked, as the download client could not be reached")
+	)
+
+	authRemedy := "Check DELUGE_BASE_URL, DELUGE_API_URL_PATH and DELUGE_PASSWORD, and that Deluge's web UI is reachable from here."
+	labelRemedy := fmt.Sprintf("Enable Deluge's Label plugin and add the label %q, or set TARGET_LABEL to an existing one.", cfg.TargetLabel)
+
+	if cfg.DownloadClient == "putio" {
+		authRemedy = "Check PUTIO_TOKEN: it must be a current OAuth token for the account, with access to files and transfers."
+		labelRemedy = fmt.Sprintf("Create a folder named %q at the top of your Put.io files, or set TARGET_LABEL to an existing one.",
+			cfg.TargetLabel)
+	}
+
+	auth := doctor.Check{
+		Name: "download_client",
+		Run: func(ctx context.Context) (string, error) {
+			client, err := buildDownloadClient(cfg)
+			if err != nil {
+				return "", doctor.WithRemedy(err, "Set DOWNLOAD_CLIENT to deluge or putio.")
+			}
+
+			if err := client.Authenticate(ctx); err != nil {
+				return "", err
+			}
+
+			dc, authErr = client, nil
+
+			return cfg.DownloadClient, nil
+		},
+		Remedy: authRemedy,
+	}
+
+	label := doctor.Check{
+		Name: "label",
+		Run: func(ctx context.Context) (string, error) {
+			if authErr != nil {
+				return "", authErr
+			}
+
+			if cfg.TargetLabel == "" {
+				return "", errors.New("not checked, as TARGET_LABEL is empty")
+			}
+
+			checker, ok := dc.(transfer.LabelChecker)
+			if !ok {
+				return "not checked by " + cfg.DownloadClient, nil
+			}
+
+			if err := checker.CheckLabel(ctx, cfg.TargetLabel); err != nil {
+				return "", err
+			}
+
+			return cfg.TargetLabel + " exists", nil
+		},
+		Remedy:   labelRemedy,
+		Optional: startup,
+	}
+
+	return []doctor.Check{auth, label}
+}
+
+// quietArrClients builds the *arr clients without logging, or none if they
+// cannot be built: arrInstancesCheck reports why.
+func quietArrClients(ctx context.Context, cfg *config) []*arr.Client {
+	clients, err := quietBuild(ctx, cfg)
+	if err != nil {
+		return nil
+	}
+
+	return clients
+}
+
+func quietBuild(ctx context.Context, cfg *config) ([]*arr.Client, error) {
+	return buildArrClients(logctx.WithLogger(ctx, slog.New(slog.NewTextHandler(io.Discard, nil))), cfg)
+}
+
+// arrCheck checks an *arr instance is reachable, takes its key, and is the app
+// it was configured as, on the API version the client speaks.
+func arrCheck(client *arr.Client) doctor.Check {
+	return doctor.Check{
+		Name: "arr:" + client.Name(),
+		Run: func(ctx context.Context) (string, error) {
+			status, err := client.SystemStatus(ctx)
+
+			switch {
+			case errors.Is(err, arr.ErrUnauthorized):
+				return "", doctor.WithRemedy(err, "Copy the API key from the app's Settings > General into the instance's api_key.")
+			case errors.Is(err, arr.ErrAPIVersion):
+				return "", doctor.WithRemedy(err, fmt.Sprintf(
+					"Check the URL is the app's own, with any URL base, and that its type is right: %s needs API %s.",
+					client.App(), client.APIVersion()))
+			case err != nil:
+				return "", err
+			}
+
+			if app := client.App(); app != "" && !strings.EqualFold(status.AppName, string(app)) {
+				return "", doctor.WithRemedy(fmt.Errorf("the app is %s, not %s", status.AppName, app),
+					"Check the instance's URL and type: one of them names the wrong app.")
+			}
+
+			return fmt.Sprintf("%s %s, API %s", status.AppName, status.Version, client.APIVersion()), nil
+		},
+		Remedy: "Check the instance's URL, and that the app is up and reachable from here.",
+	}
+}
+
+// notifySendChecks sends a test notification to every target, past its rules.
+func notifySendChecks(cfg *config) []doctor.Check {
+	var checks []doctor.Check
+
+	seen := map[string]int{}
+
+	for _, target := range cfg.targets() {
+		key := target.Label()
+		if seen[key]++; seen[key] > 1 {
+			key = fmt.Sprintf("%s-%d", key, seen[key])
+		}
+
+		checks = append(checks, doctor.Check{
+			Name: "notify:" + key,
+			Run: func(ctx context.Context) (string, error) {
+				n, err := notifier.New(target)
+				if err != nil {
+					return "", err
+				}
+
+				test := notifier.Event{
+					Type: notifier.EventMessage, Time: time.Now().UTC(),
+					Message: "🩺 Test notification from seedbox_downloader doctor: this target works.",
+				}
+
+				if err := notifier.SendContext(ctx, n, test); err != nil {
+					return "", err
+				}
+
+				return "test notification sent", nil
+			},
+			Remedy: "Check the target's URL and credentials: a refusal means the service did not accept them.",
+		})
+	}
+
+	return checks
+}
+
+func downloadDirChecks(cfg *config, startup bool) []doctor.Check {
+	writable := health.Writable("", cfg.DownloadDir)
+
+	return []doctor.Check{
+		{
+			Name: "download_dir_writable",
+			Run: func(ctx context.Context) (string, error) {
+				return cfg.DownloadDir, writable.Run(ctx)
+			},
+			Remedy: "Create DOWNLOAD_DIR and make it writable by the user the service runs as; in Docker, check the volume is mounted.",
+		},
+		{
+			Name: "download_dir_free_space",
+			Run: func(ctx context.Context) (string, error) {
+				minFree, err := humanize.ParseBytes(cfg.Health.MinFreeSpace)
+				if err != nil {
+					return "", doctor.WithRemedy(fmt.Errorf("invalid HEALTH_MIN_FREE_SPACE %q: %w", cfg.Health.MinFreeSpace, err),
+						"Set HEALTH_MIN_FREE_SPACE to a size such as 10GB.")
+				}
+
+				if err := health.FreeSpace("", cfg.DownloadDir, minFree).Run(ctx); err != nil {
+					return "", err
+				}
+
+				return "at least " + humanize.Bytes(minFree) + " free", nil
+			},
+			Remedy:   "Free up space under DOWNLOAD_DIR, or lower HEALTH_MIN_FREE_SPACE.",
+			Optional: startup,
+		},
+	}
+}
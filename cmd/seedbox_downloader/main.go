@@ -2,33 +2,46 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/go-chi/chi"
+	"github.com/dustin/go-humanize"
+	"github.com/go-chi/chi/v5"
+	configfile "github.com/italolelis/seedbox_downloader/internal/config"
 	"github.com/italolelis/seedbox_downloader/internal/dc/deluge"
 	"github.com/italolelis/seedbox_downloader/internal/dc/putio"
 	"github.com/italolelis/seedbox_downloader/internal/downloader"
+	"github.com/italolelis/seedbox_downloader/internal/events"
+	"github.com/italolelis/seedbox_downloader/internal/health"
 	"github.com/italolelis/seedbox_downloader/internal/http/rest"
+	"github.com/italolelis/seedbox_downloader/internal/http/web"
 	"github.com/italolelis/seedbox_downloader/internal/logctx"
 	"github.com/italolelis/seedbox_downloader/internal/notifier"
+	"github.com/italolelis/seedbox_downloader/internal/pathmap"
 	"github.com/italolelis/seedbox_downloader/internal/storage"
 	"github.com/italolelis/seedbox_downloader/internal/storage/sqlite"
 	"github.com/italolelis/seedbox_downloader/internal/svc/arr"
 	"github.com/italolelis/seedbox_downloader/internal/telemetry"
 	"github.com/italolelis/seedbox_downloader/internal/transfer"
-	"github.com/kelseyhightower/envconfig"
 )
 
 var version = "develop"
 
-// Config struct for environment variables.
+// Config struct for environment variables, and the keys of the config file.
 type config struct {
 	DownloadClient string `envconfig:"DOWNLOAD_CLIENT" default:"deluge"`
 
@@ -55,12 +68,55 @@ type config struct {
 	DBMaxOpenConns    int            `envconfig:"DB_MAX_OPEN_CONNS" default:"25"`
 	DBMaxIdleConns    int            `envconfig:"DB_MAX_IDLE_CONNS" default:"5"`
 	MaxParallel       int            `envconfig:"MAX_PARALLEL" default:"5"`
+	// DryRun runs the pipeline against the real seedbox, *arr apps and database
+	// while changing none of them: what would be claimed, written, deleted,
+	// removed or notified is logged instead. serve --dry-run sets it too.
+	DryRun bool `envconfig:"DRY_RUN" default:"false"`
+
+	// NotifyTargets lists every place notifications go, as a JSON array.
+	// DISCORD_WEBHOOK_URL remains as a shorthand for a Discord target.
+	NotifyTargets notifyTargets `envconfig:"NOTIFY_TARGETS"`
+	// NotifyOutbox holds notifications in the database until each target has
+	// taken them.
+	NotifyOutbox struct {
+		// Size is how many may wait for one target before the oldest are dropped.
+		Size int `default:"1000"`
+		// MaxAttempts is how many times one is tried before it is given up on.
+		MaxAttempts int `split_words:"true" default:"10"`
+	} `envconfig:"NOTIFY_OUTBOX"`
+
+	// ImportTimeout gives up on a downloaded transfer the *arr apps have not
+	// imported in this long, as if they had refused it; a season pack from its
+	// last episode imported. Zero waits forever.
+	ImportTimeout time.Duration `envconfig:"IMPORT_TIMEOUT" default:"0"`
+	// ImportFailedCleanup deletes the local files and the seedbox transfer of one
+	// the apps refused or timed out on. Off, both are kept for a person to look at.
+	ImportFailedCleanup bool `envconfig:"IMPORT_FAILED_CLEANUP" default:"false"`
+	// ImportIgnorePatterns match the base names of files the apps are not expected
+	// to import, so a transfer counts as imported without them.
+	ImportIgnorePatterns []string `envconfig:"IMPORT_IGNORE_PATTERNS" default:"*.nfo,*.txt,*sample*"`
 
 	Transmission struct {
 		Username string `split_words:"true"`
 		Password string `split_words:"true"`
 	}
 
+	// API is the native /api/v1 and the dashboard at /ui/ built on it. Both are
+	// authenticated by their own key, never the Transmission credentials, which
+	// are shared with every *arr app. Without a key neither is served.
+	API struct {
+		Key string `split_words:"true"`
+	}
+
+	// Health tunes /healthz and /readyz.
+	Health struct {
+		// MinFreeSpace is the least free space DOWNLOAD_DIR may have and still be
+		// ready, in humanized form such as "10GB".
+		MinFreeSpace string `split_words:"true" default:"1GB"`
+		// CacheTTL is how long a readiness report is reused between probes.
+		CacheTTL time.Duration `split_words:"true" default:"30s"`
+	}
+
 	Web struct {
 		BindAddress     string        `split_words:"true" default:"0.0.0.0:9091"`
 		ReadTimeout     time.Duration `split_words:"true" default:"30s"`
@@ -80,33 +136,163 @@ type config struct {
 		// over a network you do not trust.
 		OTELInsecure bool   `split_words:"true" default:"true"`
 		ServiceName  string `split_words:"true" default:"seedbox_downloader"`
+
+		// Prometheus serves the same metrics for scraping, for setups without a
+		// collector. Independent of Enabled, which governs OTLP alone.
+		Prometheus struct {
+			Enabled bool `split_words:"true" default:"false"`
+			// BindAddress is the metrics listener. Set it to WEB_BIND_ADDRESS to
+			// serve metrics from the main listener instead of a second one.
+			BindAddress string `split_words:"true" default:"0.0.0.0:9464"`
+			Path        string `split_words:"true" default:"/metrics"`
+		}
 	}
 
-	Sonarr arrConfig `envconfig:"SONARR"`
-	Radarr arrConfig `envconfig:"RADARR"`
+	// ArrInstances lists every *arr instance to track imports in. SONARR_* and
+	// RADARR_* remain as a shorthand for the common pair.
+	ArrInstances arrInstances `envconfig:"ARR_INSTANCES"`
+	Sonarr       arrConfig    `envconfig:"SONARR"`
+	Radarr       arrConfig    `envconfig:"RADARR"`
+	// PathMappings translate paths for every *arr instance without mappings of
+	// its own, and for apps that do not identify themselves over RPC. Each is
+	// "local=remote".
+	PathMappings []string `envconfig:"PATH_MAPPINGS"`
+	// ArrHistory is the copy of each *arr instance's history that import checks
+	// are answered from, shared by every watched transfer.
+	ArrHistory struct {
+		// MaxAge is how stale the copy may get before a check brings it up to date.
+		MaxAge time.Duration `split_words:"true" default:"1m"`
+		// Size is how many of the newest records are kept.
+		Size int `default:"5000"`
+	} `envconfig:"ARR_HISTORY"`
+
+	// PushImport asks the *arr apps to import a transfer the moment it is
+	// downloaded, rather than waiting for their completed download handling to
+	// get round to it.
+	PushImport struct {
+		Enabled bool `split_words:"true" default:"false"`
+		// Timeout is how long each app's import command is followed before it is
+		// recorded as an error. The transfer is still watched for its import.
+		Timeout time.Duration `split_words:"true" default:"10m"`
+	} `envconfig:"PUSH_IMPORT"`
 }
 
 type arrConfig struct {
-	APIKey  string `envconfig:"API_KEY"`
-	BaseURL string `envconfig:"BASE_URL"`
+	APIKey       string   `envconfig:"API_KEY"`
+	BaseURL      string   `envconfig:"BASE_URL"`
+	PathMappings []string `envconfig:"PATH_MAPPINGS"`
+}
+
+// arrInstance is one *arr instance: which app it is, what it is called, and how
+// to reach it.
+type arrInstance struct {
+	Type         string   `json:"type"`
+	Name         string   `json:"name"`
+	URL          string   `json:"url"`
+	APIKey       string   `json:"api_key"`
+	PathMappings []string `json:"path_mappings"`
+}
+
+// arrInstances is decoded from a JSON array, which is what envconfig cannot do
+// for a list of structs on its own.
+type arrInstances []arrInstance
+
+func (a *arrInstances) Decode(value string) error {
+	return decodeStrict(value, (*[]arrInstance)(a))
+}
+
+// notifyTargets is decoded from a JSON array, as arrInstances is.
+type notifyTargets []notifier.Target
+
+func (n *notifyTargets) Decode(value string) error {
+	return decodeStrict(value, (*[]notifier.Target)(n))
+}
+
+// decodeStrict decodes value as JSON into v, refusing keys v has no field for:
+// a misspelt one would otherwise be dropped without a word.
+func decodeStrict(value string, v any) error {
+	dec := json.NewDecoder(strings.NewReader(value))
+	dec.DisallowUnknownFields()
+
+	return dec.Decode(v)
+}
+
+// targets returns every configured notification target: NOTIFY_TARGETS followed
+// by DISCORD_WEBHOOK_URL.
+func (c *config) targets() []notifier.Target {
+	targets := append([]notifier.Target(nil), c.NotifyTargets...)
+
+	if c.DiscordWebhookURL != "" {
+		targets = append(targets, notifier.Target{Type: "discord", URL: c.DiscordWebhookURL})
+	}
+
+	return targets
+}
+
+// instances returns every configured *arr instance: ARR_INSTANCES followed by
+// the SONARR_* and RADARR_* shorthands, named after their app.
+func (c *config) instances() []arrInstance {
+	instances := append([]arrInstance(nil), c.ArrInstances...)
+
+	shorthands := []struct {
+		app string
+		cfg arrConfig
+	}{{"sonarr", c.Sonarr}, {"radarr", c.Radarr}}
+
+	for _, shorthand := range shorthands {
+		if shorthand.cfg.BaseURL == "" && shorthand.cfg.APIKey == "" {
+			continue
+		}
+
+		instances = append(instances, arrInstance{
+			Type:         shorthand.app,
+			Name:         shorthand.app,
+			URL:          shorthand.cfg.BaseURL,
+			APIKey:       shorthand.cfg.APIKey,
+			PathMappings: shorthand.cfg.PathMappings,
+		})
+	}
+
+	return instances
 }
 
 func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	if err := run(ctx); err != nil {
+	name, err := runCommand(ctx, os.Args[1:])
+
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case name == "serve":
 		slog.ErrorContext(ctx, "fatal error", "err", err)
 		os.Exit(1)
+	default:
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(1)
 	}
 }
 
-func run(ctx context.Context) error {
+// runOptions are the serve command's flags.
+type runOptions struct {
+	// once does one round of work and returns; see runOnce.
+	once bool
+	// dryRun turns DRY_RUN on.
+	dryRun bool
+}
+
+// run runs the service until ctx is done or, with once, does one round of work
+// and returns.
+func run(ctx context.Context, opts runOptions) error {
 	cfg, logger, err := initializeConfig()
 	if err != nil {
 		return err
 	}
 
+	cfg.DryRun = cfg.DryRun || opts.dryRun
+
 	ctx = logctx.WithLogger(ctx, logger)
 	logger = logger.WithGroup("main")
 
@@ -125,8 +311,20 @@ func run(ctx context.Context) error {
 		"bind_address", cfg.Web.BindAddress,
 		"telemetry_enabled", cfg.Telemetry.Enabled,
 		"putio_seed_ratio", cfg.PutioSeedRatio,
+		"dry_run", cfg.DryRun,
 	)
 
+	if cfg.DryRun {
+		logger.WarnContext(ctx, "dry run: nothing will be claimed, downloaded, deleted or removed from the seedbox, "+
+			"and no one notified; what would have been is logged instead")
+	}
+
+	logger.InfoContext(ctx, "validating configuration")
+
+	if err := validateStartup(ctx, cfg); err != nil {
+		return err
+	}
+
 	logger.InfoContext(ctx, "initializing telemetry")
 
 	tel, err := initializeTelemetry(ctx, cfg)
@@ -144,14 +342,23 @@ func run(ctx context.Context) error {
 	logger.InfoContext(ctx, "initializing services")
 
 	// The services run as goroutines that stop on context cancellation; there is
-	// nothing to tear down here, so no handle is kept.
-	if err := initializeServices(ctx, cfg, tel); err != nil {
+	// nothing to tear down. The handle is kept only so the API can reach them.
+	svcs, err := initializeServices(ctx, cfg, tel)
+	if err != nil {
 		return err
 	}
 
+	if opts.once {
+		return runOnce(ctx, cfg, svcs)
+	}
+
+	startPipeline(ctx, cfg, svcs)
+
+	reload := newReloader(cfg, svcs)
+
 	logger.InfoContext(ctx, "starting HTTP server")
 
-	servers, err := startServers(ctx, cfg, tel)
+	servers, err := startServers(ctx, cfg, tel, svcs, reload)
 	if err != nil {
 		return err
 	}
@@ -164,20 +371,74 @@ func run(ctx context.Context) error {
 		"version", version,
 	)
 
-	return runMainLoop(ctx, cfg, servers)
+	return runMainLoop(ctx, cfg, svcs, servers, reload)
+}
+
+// services holds what the HTTP surfaces need to reach of the running pipeline.
+type services struct {
+	repo *sqlite.InstrumentedDownloadRepository
+	// ledger is what the pipeline writes to: repo, or in a dry run a
+	// storage.DryRunRepository over it.
+	ledger       storage.DownloadRepository
+	dc           transfer.DownloadClient
+	downloader   *downloader.Downloader
+	orchestrator *transfer.TransferOrchestrator
+	events       *events.Bus
+	arrApps      []*arr.Client
+	outbox       *notifier.Outbox
+	notifiers    *notifier.Switch
 }
 
-// servers holds the HTTP listeners. There is only one: metrics leave over OTLP,
-// so nothing is scraped from this process and no second listener is opened.
+// servers holds the HTTP listeners. metrics is nil unless the Prometheus
+// exporter is enabled on an address of its own; over OTLP, or on the main
+// listener, no second listener is opened.
 type servers struct {
-	api    *http.Server
-	errors chan error
+	api     *http.Server
+	metrics *http.Server
+	errors  chan error
 }
 
-func initializeConfig() (*config, *slog.Logger, error) {
+// loadConfig loads the configuration from the file CONFIG_FILE names, if any,
+// and the environment, which overrides it key by key.
+func loadConfig() (*config, error) {
 	var cfg config
-	if err := envconfig.Process("", &cfg); err != nil {
-		return nil, nil, fmt.Errorf("failed to load the env vars: %w", err)
+	if err := configfile.Load(os.Getenv("CONFIG_FILE"), &cfg); err != nil {
+		return nil, fmt.Errorf("failed to load the configuration: %w", err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// validate checks the settings that parse but make no sense.
+func (c *config) validate() error {
+	if c.PollingInterval <= 0 {
+		return fmt.Errorf("POLLING_INTERVAL must be positive, not %s", c.PollingInterval)
+	}
+
+	if c.MaxParallel < 1 {
+		return fmt.Errorf("MAX_PARALLEL must be at least 1, not %d", c.MaxParallel)
+	}
+
+	if c.ArrHistory.MaxAge <= 0 {
+		return fmt.Errorf("ARR_HISTORY_MAX_AGE must be positive, not %s", c.ArrHistory.MaxAge)
+	}
+
+	if c.ArrHistory.Size <= 0 {
+		return fmt.Errorf("ARR_HISTORY_SIZE must be positive, not %d", c.ArrHistory.Size)
+	}
+
+	return nil
+}
+
+// initializeConfig loads the configuration and sets up logging at its level.
+func initializeConfig() (*config, *slog.Logger, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
 	}
 
 	jsonHandler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: cfg.LogLevel})
@@ -186,7 +447,7 @@ func initializeConfig() (*config, *slog.Logger, error) {
 
 	slog.SetDefault(logger)
 
-	return &cfg, logger, nil
+	return cfg, logger, nil
 }
 
 func initializeTelemetry(ctx context.Context, cfg *config) (*telemetry.Telemetry, error) {
@@ -196,6 +457,7 @@ func initializeTelemetry(ctx context.Context, cfg *config) (*telemetry.Telemetry
 		ServiceVersion: version,
 		OTELAddress:    cfg.Telemetry.OTELAddress,
 		Insecure:       cfg.Telemetry.OTELInsecure,
+		Prometheus:     cfg.Telemetry.Prometheus.Enabled,
 	})
 	if err != nil {
 		logger := logctx.LoggerFromContext(ctx)
@@ -223,7 +485,104 @@ func initializeTelemetry(ctx context.Context, cfg *config) (*telemetry.Telemetry
 	return tel, nil
 }
 
-func initializeServices(ctx context.Context, cfg *config, tel *telemetry.Telemetry) error {
+func initializeServices(ctx context.Context, cfg *config, tel *telemetry.Telemetry) (*services, error) {
+	logger := logctx.LoggerFromContext(ctx)
+
+	database, err := openDatabase(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	dr := sqlite.NewInstrumentedDownloadRepository(database, tel)
+
+	var ledger storage.DownloadRepository = dr
+	if cfg.DryRun {
+		ledger = storage.NewDryRunRepository(dr)
+	}
+
+	instrumentedDC, instrumentedTC, err := connectDownloadClient(ctx, cfg, tel)
+	if err != nil {
+		return nil, err
+	}
+
+	arrApps, err := buildArrClients(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// The targets are switched as a whole when the configuration is reloaded.
+	notifiers := notifier.NewSwitch(nil)
+
+	// A notification given up on is told of to every target; the one it was
+	// for may yet take that, if it refused only what it was sent.
+	outbox := notifier.NewOutbox(dr,
+		notifier.WithOutboxSize(cfg.NotifyOutbox.Size),
+		notifier.WithMaxAttempts(cfg.NotifyOutbox.MaxAttempts),
+		notifier.WithRecorder(tel),
+		notifier.WithDeadLetter(func(target string, event notifier.Event, err error) {
+			notify(ctx, logger, notifiers, notifier.DeadLetter(target, event, err))
+		}))
+
+	targets, _, err := buildNotifier(ctx, cfg, outbox)
+	if err != nil {
+		return nil, err
+	}
+
+	notifiers.Set(targets)
+	outbox.Deliver(ctx)
+
+	bus := events.NewBus(events.DefaultHistory)
+
+	downloaderOpts := []downloader.Option{
+		downloader.WithEvents(bus), downloader.WithOwners(dr), downloader.WithImportTimeout(cfg.ImportTimeout),
+		downloader.WithImportIgnore(cfg.ImportIgnorePatterns),
+	}
+	if cfg.PushImport.Enabled {
+		downloaderOpts = append(downloaderOpts, downloader.WithPushImport(cfg.PushImport.Timeout))
+	}
+
+	if cfg.DryRun {
+		downloaderOpts = append(downloaderOpts, downloader.WithDryRun())
+	}
+
+	downloader := downloader.NewDownloader(
+		cfg.DownloadDir,
+		cfg.MaxParallel,
+		instrumentedDC,
+		instrumentedTC,
+		arrApps,
+		downloaderOpts...,
+	)
+
+	transferOrchestrator := transfer.NewTransferOrchestrator(
+		ledger, instrumentedDC, cfg.TargetLabel, cfg.PollingInterval, transfer.WithEvents(bus),
+	)
+
+	return &services{
+		repo:         dr,
+		ledger:       ledger,
+		dc:           instrumentedDC,
+		downloader:   downloader,
+		orchestrator: transferOrchestrator,
+		events:       bus,
+		arrApps:      arrApps,
+		outbox:       outbox,
+		notifiers:    notifiers,
+	}, nil
+}
+
+// startPipeline sets the services polling, downloading and watching what they
+// download, until ctx is done.
+func startPipeline(ctx context.Context, cfg *config, svcs *services) {
+	setupNotificationForDownloader(ctx, svcs.ledger, svcs.downloader, svcs.notifiers, cfg, svcs.orchestrator.PollingInterval)
+	setupLifecycleNotifications(ctx, svcs.events, svcs.notifiers)
+
+	svcs.orchestrator.ProduceTransfers(ctx)
+	svcs.downloader.WatchDownloads(ctx, svcs.orchestrator.OnDownloadQueued)
+}
+
+// openDatabase opens the database at DB_PATH, creating any table it lacks.
+func openDatabase(ctx context.Context, cfg *config) (*sql.DB, error) {
 	logger := logctx.LoggerFromContext(ctx)
 
 	logger.InfoContext(ctx, "initializing database")
@@ -237,7 +596,7 @@ func initializeServices(ctx context.Context, cfg *config, tel *telemetry.Telemet
 			"max_idle_conns", cfg.DBMaxIdleConns,
 			"err", err)
 
-		return fmt.Errorf("failed to initialize the database: %w", err)
+		return nil, fmt.Errorf("failed to initialize the database: %w", err)
 	}
 
 	logger.InfoContext(ctx, "database ready",
@@ -246,7 +605,16 @@ func initializeServices(ctx context.Context, cfg *config, tel *telemetry.Telemet
 		"max_idle_conns", cfg.DBMaxIdleConns,
 	)
 
-	dr := sqlite.NewInstrumentedDownloadRepository(database, tel)
+	return database, nil
+}
+
+// connectDownloadClient builds the download client and authenticates with it.
+// It is returned twice over, instrumented: for reading transfers, and for adding
+// and removing them.
+func connectDownloadClient(
+	ctx context.Context, cfg *config, tel *telemetry.Telemetry,
+) (*transfer.InstrumentedDownloadClient, *transfer.InstrumentedTransferClient, error) {
+	logger := logctx.LoggerFromContext(ctx)
 
 	logger.InfoContext(ctx, "initializing download client")
 
@@ -257,7 +625,7 @@ func initializeServices(ctx context.Context, cfg *config, tel *telemetry.Telemet
 			"client_type", cfg.DownloadClient,
 			"err", err)
 
-		return fmt.Errorf("failed to build download client: %w", err)
+		return nil, nil, fmt.Errorf("failed to build download client: %w", err)
 	}
 
 	instrumentedDC := transfer.NewInstrumentedDownloadClient(dc, tel, cfg.DownloadClient)
@@ -267,41 +635,20 @@ func initializeServices(ctx context.Context, cfg *config, tel *telemetry.Telemet
 			"client_type", cfg.DownloadClient,
 			"err", err)
 
-		return fmt.Errorf("failed to authenticate with the download client: %w", err)
+		return nil, nil, fmt.Errorf("failed to authenticate with the download client: %w", err)
 	}
 
 	logger.InfoContext(ctx, "download client ready", "client_type", cfg.DownloadClient)
 
-	arrServices := []*arr.Client{
-		arr.NewClient(cfg.Sonarr.APIKey, cfg.Sonarr.BaseURL),
-		arr.NewClient(cfg.Radarr.APIKey, cfg.Radarr.BaseURL),
-	}
-
-	instrumentedTC := transfer.NewInstrumentedTransferClient(dc.(transfer.TransferClient), tel, cfg.DownloadClient)
-
-	downloader := downloader.NewDownloader(
-		cfg.DownloadDir,
-		cfg.MaxParallel,
-		instrumentedDC,
-		instrumentedTC,
-		arrServices,
-	)
-
-	setupNotificationForDownloader(ctx, dr, downloader, cfg, cfg.PutioSeedRatio)
-
-	transferOrchestrator := transfer.NewTransferOrchestrator(dr, instrumentedDC, cfg.TargetLabel, cfg.PollingInterval)
-	transferOrchestrator.ProduceTransfers(ctx)
-	downloader.WatchDownloads(ctx, transferOrchestrator.OnDownloadQueued)
-
-	return nil
+	return instrumentedDC, transfer.NewInstrumentedTransferClient(dc.(transfer.TransferClient), tel, cfg.DownloadClient), nil
 }
 
-func startServers(ctx context.Context, cfg *config, tel *telemetry.Telemetry) (*servers, error) {
+func startServers(ctx context.Context, cfg *config, tel *telemetry.Telemetry, svcs *services, reload *reloader) (*servers, error) {
 	logger := logctx.LoggerFromContext(ctx)
 
-	serverErrors := make(chan error, 1)
+	serverErrors := make(chan error, 2)
 
-	server, err := setupServer(ctx, cfg, tel)
+	server, err := setupServer(ctx, cfg, tel, svcs, reload)
 	if err != nil {
 		logger.ErrorContext(ctx, "server setup failed",
 			"component", "http_server",
@@ -316,19 +663,64 @@ func startServers(ctx context.Context, cfg *config, tel *telemetry.Telemetry) (*
 		serverErrors <- server.ListenAndServe()
 	}()
 
+	metrics := setupMetricsServer(ctx, cfg, tel)
+	if metrics != nil {
+		go func() {
+			logger.InfoContext(ctx, "serving Prometheus metrics",
+				"host", cfg.Telemetry.Prometheus.BindAddress,
+				"path", cfg.Telemetry.Prometheus.Path)
+			serverErrors <- metrics.ListenAndServe()
+		}()
+	}
+
 	return &servers{
-		api:    server,
-		errors: serverErrors,
+		api:     server,
+		metrics: metrics,
+		errors:  serverErrors,
 	}, nil
 }
 
-func runMainLoop(ctx context.Context, cfg *config, servers *servers) error {
+// setupMetricsServer creates the listener for Prometheus scrapes, unless the
+// exporter is off or shares the main listener.
+func setupMetricsServer(ctx context.Context, cfg *config, tel *telemetry.Telemetry) *http.Server {
+	handler := tel.MetricsHandler()
+	if handler == nil || cfg.Telemetry.Prometheus.BindAddress == cfg.Web.BindAddress {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Telemetry.Prometheus.Path, handler)
+
+	return &http.Server{
+		Addr:         cfg.Telemetry.Prometheus.BindAddress,
+		ReadTimeout:  cfg.Web.ReadTimeout,
+		WriteTimeout: cfg.Web.WriteTimeout,
+		IdleTimeout:  cfg.Web.IdleTimeout,
+		Handler:      mux,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+}
+
+func runMainLoop(ctx context.Context, cfg *config, svcs *services, servers *servers, reload *reloader) error {
 	logger := logctx.LoggerFromContext(ctx)
 
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	defer signal.Stop(hangup)
+
 	for {
 		select {
 		case err := <-servers.errors:
 			return fmt.Errorf("server error: %w", err)
+		case <-hangup:
+			logger.InfoContext(ctx, "reloading configuration", "trigger", "SIGHUP")
+
+			if _, err := reload.Reload(ctx); err != nil {
+				logger.ErrorContext(ctx, "configuration reload failed, keeping the running configuration", "err", err)
+			}
 		case <-ctx.Done():
 			shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
 			defer cancel()
@@ -347,12 +739,24 @@ func runMainLoop(ctx context.Context, cfg *config, servers *servers) error {
 				}
 			}
 
+			if servers.metrics != nil {
+				if err := servers.metrics.Shutdown(shutdownCtx); err != nil {
+					logger.ErrorContext(shutdownCtx, "failed to gracefully shutdown the metrics server", "err", err)
+				}
+			}
+
 			logger.InfoContext(shutdownCtx, "HTTP server stopped")
 
 			// Phase 2: the background services stop on their own, since every one of
 			// them selects on this context. Nothing closes their event channels --
 			// several goroutines send on each, so there is no correct closer.
 
+			// Phase 3: what notification targets are holding for a digest or the end
+			// of quiet hours goes into the outbox, to be sent on the next start.
+			if err := svcs.notifiers.Flush(); err != nil {
+				logger.WarnContext(shutdownCtx, "failed to queue held notifications", "err", err)
+			}
+
 			logger.InfoContext(shutdownCtx, "graceful shutdown complete")
 
 			return ctx.Err()
@@ -364,16 +768,12 @@ func setupNotificationForDownloader(
 	ctx context.Context,
 	repo storage.DownloadRepository,
 	downloader *downloader.Downloader,
+	notif notifier.Notifier,
 	cfg *config,
-	seedRatio float64,
+	pollingInterval func() time.Duration,
 ) {
 	logger := logctx.LoggerFromContext(ctx).WithGroup("notification")
 
-	var notif notifier.Notifier
-	if cfg.DiscordWebhookURL != "" {
-		notif = &notifier.DiscordNotifier{WebhookURL: cfg.DiscordWebhookURL}
-	}
-
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
@@ -387,7 +787,7 @@ func setupNotificationForDownloader(
 					logger.InfoContext(ctx, "restarting notification loop after panic",
 						"operation", "notification_loop")
 					time.Sleep(time.Second) // Brief backoff before restart
-					setupNotificationForDownloader(ctx, repo, downloader, cfg, seedRatio)
+					setupNotificationForDownloader(ctx, repo, downloader, notif, cfg, pollingInterval)
 				}
 			}
 		}()
@@ -401,16 +801,142 @@ func setupNotificationForDownloader(
 
 				return
 			case t := <-downloader.OnTransferDownloadError:
-				handleDownloadError(ctx, logger, repo, notif, t)
+				handleDownloadError(ctx, logger, repo, notif, downloader, t)
 			case t := <-downloader.OnTransferDownloadFinished:
-				handleDownloadFinished(ctx, logger, repo, notif, downloader, t, cfg.PollingInterval)
+				handleDownloadFinished(ctx, logger, repo, notif, downloader, t, pollingInterval())
 			case t := <-downloader.OnTransferImported:
-				handleTransferImported(ctx, logger, notif, downloader, t, cfg.PollingInterval, seedRatio)
+				handleTransferImported(ctx, logger, notif, downloader, t, pollingInterval(), cfg.PutioSeedRatio)
+			case event := <-downloader.OnTransferImportFailed:
+				handleTransferImportFailed(ctx, logger, repo, notif, downloader, event, cfg.ImportFailedCleanup)
 			case event := <-downloader.OnTransferMissing:
-				handleTransferMissing(ctx, logger, repo, notif, event)
+				handleTransferMissing(ctx, logger, repo, notif, downloader, event)
+			case result := <-downloader.OnImportScanFinished:
+				handleImportScanFinished(ctx, logger, repo, result)
+			}
+		}
+	}()
+}
+
+// setupLifecycleNotifications tells notif of the events the pipeline drives
+// without waiting on anyone: transfers claimed, seeded and cleaned up. They are
+// read off the bus, where nothing holds the pipeline up for them. It returns a
+// function that waits, until the context it is given is done, for every event
+// published so far to have been told of.
+func setupLifecycleNotifications(ctx context.Context, bus *events.Bus, notif notifier.Notifier) func(context.Context) {
+	if notif == nil {
+		return func(context.Context) {}
+	}
+
+	var forwarded atomic.Uint64
+
+	// Subscribed before returning, so that nothing published from here on is
+	// missed while the goroutine starts.
+	stream, cancel := bus.Subscribe(0)
+	go forwardLifecycleEvents(ctx, bus, notif, stream, cancel, &forwarded)
+
+	return func(waitCtx context.Context) {
+		last := bus.LastID()
+
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+
+		for forwarded.Load() < last {
+			select {
+			case <-waitCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// forwardLifecycleEvents forwards from stream until ctx is done, subscribing
+// again whenever the bus drops it, and keeps the ID of the last event read in
+// forwarded.
+func forwardLifecycleEvents(
+	ctx context.Context, bus *events.Bus, notif notifier.Notifier, stream <-chan events.Event, cancel func(), forwarded *atomic.Uint64,
+) {
+	logger := logctx.LoggerFromContext(ctx).WithGroup("notification")
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.ErrorContext(ctx, "lifecycle notification loop panic",
+				"operation", "lifecycle_notification_loop",
+				"panic", r,
+				"stack", string(debug.Stack()))
+
+			cancel()
+
+			// The event that panicked is skipped, rather than replayed into the
+			// same panic.
+			forwarded.Add(1)
+
+			if ctx.Err() == nil {
+				time.Sleep(time.Second)
+
+				stream, cancel := bus.Subscribe(forwarded.Load())
+				go forwardLifecycleEvents(ctx, bus, notif, stream, cancel, forwarded)
 			}
 		}
 	}()
+
+	for {
+		// The bus closes the subscription of one that falls behind; the next
+		// picks up from the last event seen.
+		forwardLifecycle(ctx, logger, stream, notif, forwarded)
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		stream, cancel = bus.Subscribe(forwarded.Load())
+	}
+}
+
+// forwardLifecycle notifies of the lifecycle events on stream until it is
+// closed or ctx is done, storing the ID of each event read in forwarded once it
+// is dealt with.
+func forwardLifecycle(ctx context.Context, logger *slog.Logger, stream <-chan events.Event, notif notifier.Notifier, forwarded *atomic.Uint64) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-stream:
+			if !ok {
+				return
+			}
+
+			forwardLifecycleEvent(ctx, logger, e, notif)
+			forwarded.Store(e.ID)
+		}
+	}
+}
+
+// forwardLifecycleEvent notifies of e if it is a lifecycle event.
+func forwardLifecycleEvent(ctx context.Context, logger *slog.Logger, e events.Event, notif notifier.Notifier) {
+	t, ok := e.Data.(events.Transfer)
+	if !ok {
+		return
+	}
+
+	event := notifier.Event{Time: e.At, TransferID: t.ID, TransferName: t.Name}
+
+	switch e.Type {
+	case events.TransferClaimed:
+		event.Type, event.Label, event.Size, event.FileCount = notifier.EventClaimed, t.Label, t.Size, t.Files
+		event.Message = "📥 Transfer claimed: " + t.Name + " (" + t.ID + ")"
+	case events.TransferSeedingComplete:
+		event.Type, event.Ratio = notifier.EventSeedingComplete, t.Ratio
+		event.Message = "🌱 Seeding complete for transfer: " + t.Name + " (" + t.ID + ")"
+	case events.TransferCleanedUp:
+		event.Type = notifier.EventCleanupDone
+		event.Message = "🧹 Transfer cleaned up: " + t.Name + " (" + t.ID + ")"
+	default:
+		return
+	}
+
+	notify(ctx, logger, notif, event)
 }
 
 func handleDownloadError(
@@ -418,8 +944,11 @@ func handleDownloadError(
 	logger *slog.Logger,
 	repo storage.DownloadRepository,
 	notif notifier.Notifier,
+	dl *downloader.Downloader,
 	t *transfer.Transfer,
 ) {
+	defer dl.ForgetReport(t.ID)
+
 	err := repo.UpdateTransferStatus(t.ID, "failed")
 	if err != nil {
 		logger.ErrorContext(ctx, "failed to update transfer status", "transfer_id", t.ID, "err", err)
@@ -429,13 +958,9 @@ func handleDownloadError(
 
 	logger.WarnContext(ctx, "transfer download error", "transfer_id", t.ID, "transfer_name", t.Name)
 
-	if notif != nil {
-		if notifyErr := notif.Notify(
-			"❌ Download failed for transfer: " + t.Name + " (" + t.ID + ")",
-		); notifyErr != nil {
-			logger.ErrorContext(ctx, "failed to send notification", "err", notifyErr)
-		}
-	}
+	event := transferEvent(dl, t, notifier.EventDownloadFailed)
+	event.Message = "❌ Download failed for transfer: " + t.Name + " (" + t.ID + ")"
+	notify(ctx, logger, notif, event)
 }
 
 func handleDownloadFinished(
@@ -456,15 +981,22 @@ func handleDownloadFinished(
 
 	dl.WatchForImported(ctx, t, pollingInterval)
 
+	notifyDownloaded(ctx, logger, notif, dl, t)
+}
+
+func notifyDownloaded(ctx context.Context, logger *slog.Logger, notif notifier.Notifier, dl *downloader.Downloader, t *transfer.Transfer) {
 	logger.InfoContext(ctx, "transfer download finished", "transfer_id", t.ID, "transfer_name", t.Name)
 
-	if notif != nil {
-		if notifyErr := notif.Notify(
-			"✅ Download finished for transfer: " + t.Name + " (" + t.ID + ")",
-		); notifyErr != nil {
-			logger.ErrorContext(ctx, "failed to send notification", "err", notifyErr)
-		}
-	}
+	event := transferEvent(dl, t, notifier.EventDownloaded)
+	event.Message = "✅ Download finished for transfer: " + t.Name + " (" + t.ID + ")"
+	notify(ctx, logger, notif, event)
+
+	// A download is only reported finished once VerifyTransfer has found every
+	// file on disk the size the seedbox reported; one that fails it is reported
+	// failed instead.
+	event.Type = notifier.EventVerified
+	event.Message = "🔍 Download verified for transfer: " + t.Name + " (" + t.ID + ")"
+	notify(ctx, logger, notif, event)
 }
 
 func handleTransferImported(
@@ -476,18 +1008,78 @@ func handleTransferImported(
 	pollingInterval time.Duration,
 	seedRatio float64,
 ) {
+	// Cleaning up forgets the transfer's report, and tells of itself, so the
+	// import is told of first.
+	notifyImported(ctx, logger, notif, dl, t)
+
 	if seedRatio > 0 {
 		dl.WatchForSeeding(ctx, t, pollingInterval, seedRatio)
 	} else {
 		dl.CleanupTransfer(ctx, t)
 	}
+}
 
-	if notif != nil {
-		if notifyErr := notif.Notify(
-			"📪 Transfer imported: " + t.Name + " (" + t.ID + ")",
-		); notifyErr != nil {
-			logger.ErrorContext(ctx, "failed to send notification", "err", notifyErr)
-		}
+func notifyImported(ctx context.Context, logger *slog.Logger, notif notifier.Notifier, dl *downloader.Downloader, t *transfer.Transfer) {
+	event := transferEvent(dl, t, notifier.EventImported)
+	event.Message = "📪 Transfer imported: " + t.Name + " (" + t.ID + ")"
+	notify(ctx, logger, notif, event)
+}
+
+func handleTransferImportFailed(
+	ctx context.Context,
+	logger *slog.Logger,
+	repo storage.DownloadRepository,
+	notif notifier.Notifier,
+	dl *downloader.Downloader,
+	event downloader.ImportFailedEvent,
+	cleanup bool,
+) {
+	t := event.Transfer
+	defer dl.ForgetReport(t.ID)
+
+	notification := transferEvent(dl, t, notifier.EventImportFailed)
+	notification.Instance, notification.Error, notification.Reason = event.Instance, event.Message, event.Reason
+
+	if err := repo.UpdateTransferStatus(t.ID, "import_failed"); err != nil {
+		logger.ErrorContext(ctx, "failed to update transfer status to import_failed", "transfer_id", t.ID, "err", err)
+	}
+
+	if cleanup {
+		dl.RemoveLocal(ctx, t)
+		dl.CleanupTransfer(ctx, t)
+	}
+
+	embed := notifier.EventEmbed(notification)
+	if cleanup {
+		embed.Description += " Its files have been removed locally and from the seedbox."
+	}
+
+	notification.Embed = &embed
+	notify(ctx, logger, notif, notification)
+}
+
+// handleImportScanFinished records how a pushed import went in the transfer's
+// history, as "<instance>_scan_<outcome>". The transfer's status is left alone: only
+// the import watch decides it has been imported.
+func handleImportScanFinished(
+	ctx context.Context,
+	logger *slog.Logger,
+	repo storage.DownloadRepository,
+	result downloader.ImportScanResult,
+) {
+	event := result.Instance + "_scan_" + result.Outcome()
+	if err := repo.RecordTransferEvent(result.Transfer.ID, event); err != nil {
+		logger.ErrorContext(ctx, "failed to record import scan", "transfer_id", result.Transfer.ID, "err", err)
+	}
+
+	if result.Err != nil || !result.Command.Succeeded() {
+		logger.WarnContext(ctx, "pushed import did not succeed",
+			"transfer_id", result.Transfer.ID,
+			"instance", result.Instance,
+			"command_id", result.Command.ID,
+			"outcome", result.Outcome(),
+			"message", result.Command.Message,
+			"err", result.Err)
 	}
 }
 
@@ -496,8 +1088,11 @@ func handleTransferMissing(
 	logger *slog.Logger,
 	repo storage.DownloadRepository,
 	notif notifier.Notifier,
+	dl *downloader.Downloader,
 	event downloader.MissingTransferEvent,
 ) {
+	defer dl.ForgetReport(event.Transfer.ID)
+
 	if err := repo.UpdateTransferStatus(event.Transfer.ID, "missing"); err != nil {
 		logger.ErrorContext(ctx, "failed to update transfer status to missing", "transfer_id", event.Transfer.ID, "err", err)
 	}
@@ -507,32 +1102,55 @@ func handleTransferMissing(
 		"transfer_name", event.Transfer.Name,
 		"missing_type", event.MissingType)
 
-	if notif != nil {
-		title := "Transfer Removed"
-		description := "This transfer was removed from Put.io before download could complete."
-		statusLabel := "Transfer Removed"
+	notification := transferEvent(dl, event.Transfer, notifier.EventMissing)
+	notification.Reason, notification.Error = event.MissingType, "Transfer Removed"
 
-		if event.MissingType == "files_missing" {
-			title = "Transfer Files Missing"
-			description = "The files for this transfer were deleted from Put.io while download was in progress."
-			statusLabel = "Files Missing"
-		}
+	if event.MissingType == "files_missing" {
+		notification.Error = "Files Missing"
+	}
 
-		embed := notifier.Embed{
-			Title:       title,
-			Description: description,
-			Color:       15158332, // 0xE74C3C red
-			Fields: []notifier.EmbedField{
-				{Name: "Transfer Name", Value: event.Transfer.Name, Inline: true},
-				{Name: "Transfer ID", Value: event.Transfer.ID, Inline: true},
-				{Name: "Status", Value: statusLabel, Inline: true},
-			},
-			Timestamp: time.Now().UTC().Format(time.RFC3339),
-		}
+	notify(ctx, logger, notif, notification)
+}
 
-		if notifyErr := notif.NotifyEmbed(embed); notifyErr != nil {
-			logger.WarnContext(ctx, "failed to send missing transfer notification", "transfer_id", event.Transfer.ID, "err", notifyErr)
-		}
+// transferEvent describes t for notifications, with what the downloader
+// learned of it along the way.
+func transferEvent(dl *downloader.Downloader, t *transfer.Transfer, eventType string) notifier.Event {
+	report := dl.Report(t.ID)
+
+	event := notifier.Event{
+		Type:         eventType,
+		Time:         time.Now().UTC(),
+		TransferID:   t.ID,
+		TransferName: t.Name,
+		Label:        t.Label,
+		Size:         t.TotalSize(),
+		FileCount:    len(t.Files),
+		LocalPath:    dl.LocalPath(t),
+		Duration:     report.Duration,
+		Instance:     report.ImportedBy,
+	}
+
+	if report.Err != nil {
+		event.Error = report.Err.Error()
+	}
+
+	return event
+}
+
+// notify sends event to notif, if there is anywhere to send it, with the embed
+// every event of its type has unless it was given another.
+func notify(ctx context.Context, logger *slog.Logger, notif notifier.Notifier, event notifier.Event) {
+	if notif == nil {
+		return
+	}
+
+	if event.Embed == nil {
+		embed := notifier.EventEmbed(event)
+		event.Embed = &embed
+	}
+
+	if err := notifier.Send(notif, event); err != nil {
+		logger.WarnContext(ctx, "failed to queue notification", "transfer_id", event.TransferID, "event", event.Type, "err", err)
 	}
 }
 
@@ -548,8 +1166,211 @@ func buildDownloadClient(cfg *config) (transfer.DownloadClient, error) {
 	return nil, fmt.Errorf("invalid download client: %s", cfg.DownloadClient)
 }
 
+// newHealthHandler assembles the probes. Liveness looks only at whether the
+// orchestrator's loop is turning; readiness at every dependency a download needs.
+func newHealthHandler(cfg *config, svcs *services) (*health.Handler, error) {
+	minFree, err := humanize.ParseBytes(cfg.Health.MinFreeSpace)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HEALTH_MIN_FREE_SPACE %q: %w", cfg.Health.MinFreeSpace, err)
+	}
+
+	// Handing a transfer to the downloader waits as long as the download before
+	// it takes, so the orchestrator is as alive as the downloader meanwhile, and
+	// the downloader's check is what fails if it has hung.
+	orchestratorTick := func() time.Time {
+		if svcs.orchestrator.HandingOff() {
+			return time.Now()
+		}
+
+		return svcs.orchestrator.LastTick()
+	}
+
+	liveness := []health.Check{
+		health.Heartbeat("orchestrator", orchestratorTick, 2*cfg.PollingInterval),
+		health.Heartbeat("downloader", svcs.downloader.LastProgress, 2*cfg.PollingInterval),
+	}
+
+	readiness := []health.Check{
+		{Name: "database", Run: svcs.repo.Ping},
+		{Name: "download_client", Run: svcs.dc.Authenticate},
+		health.Writable("download_dir_writable", cfg.DownloadDir),
+		health.FreeSpace("download_dir_free_space", cfg.DownloadDir, minFree),
+	}
+
+	secrets := []string{cfg.PutioToken, cfg.DelugePassword, cfg.Transmission.Password, cfg.API.Key, cfg.DiscordWebhookURL}
+
+	for _, client := range svcs.arrApps {
+		readiness = append(readiness, health.Check{Name: client.Name(), Run: client.Ping})
+	}
+
+	for _, instance := range cfg.instances() {
+		secrets = append(secrets, instance.APIKey)
+	}
+
+	for _, target := range cfg.targets() {
+		secrets = append(secrets, target.Secrets()...)
+	}
+
+	return health.NewHandler(liveness, readiness,
+		health.WithCacheTTL(cfg.Health.CacheTTL),
+		health.WithRedaction(secrets...),
+	), nil
+}
+
+// parsePathMappings parses and validates a set of path mappings, including that
+// DOWNLOAD_DIR itself makes the round trip: it is the path every other one is
+// built on.
+func parsePathMappings(downloadDir string, specs []string) (pathmap.Mappings, error) {
+	mappings, err := pathmap.Parse(specs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mappings.Validate(); err != nil {
+		return nil, err
+	}
+
+	if remote := mappings.ToRemote(downloadDir); mappings.ToLocal(remote) != filepath.Clean(downloadDir) {
+		return nil, fmt.Errorf("DOWNLOAD_DIR %s maps to %s, which maps back to %s", downloadDir, remote, mappings.ToLocal(remote))
+	}
+
+	return mappings, nil
+}
+
+// buildNotifier builds a notifier for every configured target, sending through
+// outbox what its rules let through. A target of unknown type, missing what its
+// type needs or with rules that do not parse is a configuration error, caught
+// before any target is queued in the outbox. The outbox keys of the targets
+// are returned with them.
+func buildNotifier(ctx context.Context, cfg *config, outbox *notifier.Outbox) (notifier.Multi, []string, error) {
+	logger := logctx.LoggerFromContext(ctx)
+
+	type built struct {
+		target   notifier.Target
+		notifier notifier.Notifier
+		rules    notifier.Rules
+	}
+
+	targets := cfg.targets()
+	all := make([]built, 0, len(targets))
+
+	for _, target := range targets {
+		n, err := notifier.New(target)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rules, err := target.Rules()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		all = append(all, built{target: target, notifier: n, rules: rules})
+	}
+
+	var (
+		notifiers notifier.Multi
+		keys      []string
+	)
+
+	seen := map[string]int{}
+
+	for _, b := range all {
+		target := b.target
+
+		// The outbox keeps each target's notifications under its label, so two
+		// targets sharing one are told apart by their order.
+		key := target.Label()
+		if seen[key]++; seen[key] > 1 {
+			key = fmt.Sprintf("%s-%d", key, seen[key])
+		}
+
+		// A dry run tells no one, and leaves the outbox alone: what each target
+		// would be sent is logged.
+		var n notifier.Notifier = &notifier.LogNotifier{Target: key, Logger: logger}
+		if !cfg.DryRun {
+			n = outbox.Queue(key, b.notifier)
+		}
+
+		if !b.rules.Empty() {
+			n = notifier.NewRouted(n, b.rules, func(err error) {
+				logger.WarnContext(ctx, "failed to queue held notifications",
+					"component", "notifier", "target", key, "err", err)
+			})
+		}
+
+		notifiers = append(notifiers, n)
+		keys = append(keys, key)
+
+		logger.InfoContext(ctx, "notification target configured",
+			"component", "notifier", "target", key, "type", target.Type,
+			"events", target.Events, "labels", target.Labels, "quiet_hours", target.QuietHours, "digest", target.Digest)
+	}
+
+	return notifiers, keys, nil
+}
+
+// buildArrClients builds a client for every configured *arr instance. One with
+// no URL or key is skipped with a warning rather than queried at a blank URL; an
+// unknown type, a repeated name or a path mapping that does not round-trip is a
+// configuration error, caught here at startup.
+func buildArrClients(ctx context.Context, cfg *config) ([]*arr.Client, error) {
+	logger := logctx.LoggerFromContext(ctx)
+
+	if _, err := parsePathMappings(cfg.DownloadDir, cfg.PathMappings); err != nil {
+		return nil, fmt.Errorf("invalid PATH_MAPPINGS: %w", err)
+	}
+
+	var clients []*arr.Client
+
+	seen := map[string]bool{}
+
+	for _, instance := range cfg.instances() {
+		app, err := arr.ParseApp(instance.Type)
+		if err != nil {
+			return nil, fmt.Errorf("invalid *arr instance %q: %w", instance.Name, err)
+		}
+
+		name := instance.Name
+		if name == "" {
+			name = string(app)
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("*arr instance name %q is used more than once", name)
+		}
+
+		seen[name] = true
+
+		if instance.URL == "" || instance.APIKey == "" {
+			logger.WarnContext(ctx, "skipping *arr instance without a URL and API key",
+				"component", "arr", "instance", name, "type", app)
+
+			continue
+		}
+
+		specs := instance.PathMappings
+		if len(specs) == 0 {
+			specs = cfg.PathMappings
+		}
+
+		mappings, err := parsePathMappings(cfg.DownloadDir, specs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path mappings for *arr instance %q: %w", name, err)
+		}
+
+		clients = append(clients, arr.NewClient(instance.APIKey, instance.URL,
+			arr.WithApp(app), arr.WithName(name), arr.WithPathMappings(mappings),
+			arr.WithHistoryCache(cfg.ArrHistory.MaxAge, cfg.ArrHistory.Size)))
+
+		logger.InfoContext(ctx, "*arr instance configured", "component", "arr", "instance", name, "type", app)
+	}
+
+	return clients, nil
+}
+
 // setupServer prepares the handlers and services to create the http rest server.
-func setupServer(ctx context.Context, cfg *config, tel *telemetry.Telemetry) (*http.Server, error) {
+func setupServer(ctx context.Context, cfg *config, tel *telemetry.Telemetry, svcs *services, reload *reloader) (*http.Server, error) {
 	r := chi.NewRouter()
 
 	// Middleware order is critical:
@@ -562,6 +1383,50 @@ func setupServer(ctx context.Context, cfg *config, tel *telemetry.Telemetry) (*h
 	// 3. HTTPLogging - logs after handler completes with request_id, trace_id, span_id
 	r.Use(telemetry.HTTPLogging)
 
+	logger := logctx.LoggerFromContext(ctx)
+
+	healthHandler, err := newHealthHandler(cfg, svcs)
+	if err != nil {
+		return nil, err
+	}
+
+	reload.health.Store(healthHandler)
+
+	// Unauthenticated: probes carry no credentials. Reports never include them.
+	// The handler is rebuilt when the configuration is reloaded.
+	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		reload.health.Load().HandleLiveness(w, r)
+	})
+	r.Get("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		reload.health.Load().HandleReadiness(w, r)
+	})
+
+	if metrics := tel.MetricsHandler(); metrics != nil && cfg.Telemetry.Prometheus.BindAddress == cfg.Web.BindAddress {
+		r.Handle(cfg.Telemetry.Prometheus.Path, metrics)
+	}
+
+	if cfg.API.Key != "" {
+		apiOpts := []rest.APIOption{rest.WithReloader(reload)}
+		if cfg.DryRun {
+			apiOpts = append(apiOpts, rest.WithAPIDryRun())
+		}
+
+		apiHandler := rest.NewAPIHandler(cfg.API.Key, svcs.repo, svcs.orchestrator, svcs.dc, svcs.downloader, svcs.events, cfg.TargetLabel,
+			apiOpts...)
+		r.Mount("/api/v1", apiHandler.Routes())
+
+		// The dashboard's links are relative, so it has to be reached with the
+		// trailing slash.
+		r.Get("/ui", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/ui/", http.StatusMovedPermanently)
+		})
+		r.With(rest.APIKeyAuth(cfg.API.Key)).Handle("/ui/*", http.StripPrefix("/ui", web.Handler()))
+	} else {
+		logger.InfoContext(ctx, "native API and dashboard disabled",
+			"component", "http_server",
+			"remedy", "set API_KEY to enable /api/v1 and /ui/")
+	}
+
 	var tHandler *rest.TransmissionHandler
 
 	// Get the original client for the transmission handler
@@ -573,10 +1438,23 @@ func setupServer(ctx context.Context, cfg *config, tel *telemetry.Telemetry) (*h
 	if putioClient, ok := originalClient.(*putio.Client); ok {
 		// DownloadDir, not a Put.io path: this is advertised to the *arr apps, and
 		// the only path meaningful to them is the one we actually wrote to.
-		tHandler = rest.NewTransmissionHandler(cfg.Transmission.Username, cfg.Transmission.Password, putioClient, cfg.TargetLabel, cfg.DownloadDir, tel)
+		opts, err := transmissionOptions(cfg, svcs.repo, svcs.arrApps)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.DryRun {
+			opts = append(opts, rest.WithDryRun())
+		}
+
+		tHandler = rest.NewTransmissionHandler(
+			cfg.Transmission.Username, cfg.Transmission.Password, putioClient, cfg.TargetLabel, cfg.DownloadDir, tel,
+			opts...,
+		)
+		reload.transmission = tHandler
+
 		r.Mount("/", tHandler.Routes())
 	} else {
-		logger := logctx.LoggerFromContext(ctx)
 		logger.ErrorContext(ctx, "invalid download client type",
 			"component", "http_server",
 			"expected", "putio",
@@ -597,3 +1475,25 @@ func setupServer(ctx context.Context, cfg *config, tel *telemetry.Telemetry) (*h
 		},
 	}, nil
 }
+
+// transmissionOptions tells the Transmission emulation of the *arr instances:
+// the names they may connect as, and the paths each sees the local root under.
+func transmissionOptions(cfg *config, owners rest.OwnerRecorder, clients []*arr.Client) ([]rest.TransmissionOption, error) {
+	instances := make([]string, 0, len(clients))
+	paths := make(map[string]pathmap.Mappings, len(clients))
+
+	for _, client := range clients {
+		instances = append(instances, client.Name())
+		paths[client.Name()] = client.PathMappings()
+	}
+
+	defaultPaths, err := parsePathMappings(cfg.DownloadDir, cfg.PathMappings)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PATH_MAPPINGS: %w", err)
+	}
+
+	return []rest.TransmissionOption{
+		rest.WithOwners(owners, instances...),
+		rest.WithPathMappings(paths, defaultPaths),
+	}, nil
+}
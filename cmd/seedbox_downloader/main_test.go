@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/downloader"
+	"github.com/italolelis/seedbox_downloader/internal/events"
+	"github.com/italolelis/seedbox_downloader/internal/http/rest"
+	"github.com/italolelis/seedbox_downloader/internal/logctx"
+	"github.com/italolelis/seedbox_downloader/internal/notifier"
+	"github.com/italolelis/seedbox_downloader/internal/storage/sqlite"
+	"github.com/italolelis/seedbox_downloader/internal/telemetry"
+	"github.com/italolelis/seedbox_downloader/internal/transfer"
+	"github.com/italolelis/seedbox_downloader/test/seedbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAPIKey = "test-api-key"
+
+// newTestServices builds the running services as initializeServices does, but
+// reaching nothing: the Put.io client is never authenticated.
+func newTestServices(t *testing.T) (*config, *telemetry.Telemetry, *services) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DOWNLOAD_CLIENT", "putio")
+	t.Setenv("PUTIO_TOKEN", "token")
+	t.Setenv("TARGET_LABEL", "tv")
+	t.Setenv("DOWNLOAD_DIR", dir)
+	t.Setenv("DB_PATH", filepath.Join(dir, "downloads.db"))
+	t.Setenv("API_KEY", testAPIKey)
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	tel, err := telemetry.New(ctx, telemetry.Config{ServiceName: "seedbox_downloader_test"})
+	require.NoError(t, err)
+
+	database, err := openDatabase(ctx, cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+
+	client, err := buildDownloadClient(cfg)
+	require.NoError(t, err)
+
+	dc := transfer.NewInstrumentedDownloadClient(client, tel, cfg.DownloadClient)
+	tc := transfer.NewInstrumentedTransferClient(client.(transfer.TransferClient), tel, cfg.DownloadClient)
+	repo := sqlite.NewInstrumentedDownloadRepository(database, tel)
+	bus := events.NewBus(events.DefaultHistory)
+
+	svcs := &services{
+		repo:         repo,
+		ledger:       repo,
+		dc:           dc,
+		downloader:   downloader.NewDownloader(cfg.DownloadDir, cfg.MaxParallel, dc, tc, nil, downloader.WithEvents(bus)),
+		orchestrator: transfer.NewTransferOrchestrator(repo, dc, cfg.TargetLabel, time.Hour, transfer.WithEvents(bus)),
+		events:       bus,
+	}
+
+	return cfg, tel, svcs
+}
+
+// newTestServer builds the service's router as setupServer does.
+func newTestServer(t *testing.T) http.Handler {
+	t.Helper()
+
+	cfg, tel, svcs := newTestServices(t)
+
+	server, err := setupServer(context.Background(), cfg, tel, svcs, newReloader(cfg, svcs))
+	require.NoError(t, err)
+
+	return server.Handler
+}
+
+// The API, the dashboard and the Transmission RPC are all reached through the
+// one router.
+func TestSetupServer_RoutesEveryHandler(t *testing.T) {
+	handler := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    bool
+		want   int
+	}{
+		{name: "openapi", method: http.MethodGet, path: "/api/v1/openapi.yaml", want: http.StatusOK},
+		{name: "api with key", method: http.MethodGet, path: "/api/v1/orchestrator", key: true, want: http.StatusOK},
+		{name: "api without key", method: http.MethodGet, path: "/api/v1/orchestrator", want: http.StatusUnauthorized},
+		{name: "dashboard", method: http.MethodGet, path: "/ui/", key: true, want: http.StatusOK},
+		{name: "transmission without credentials", method: http.MethodPost, path: "/transmission/rpc", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.key {
+				req.Header.Set(rest.APIKeyHeader, testAPIKey)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+// A history cache that keeps nothing, or is never fresh, parses but makes no
+// sense, and is refused when the configuration loads.
+func TestLoadConfig_RefusesANonPositiveHistoryCache(t *testing.T) {
+	tests := []struct {
+		name, key, value string
+	}{
+		{name: "zero max age", key: "ARR_HISTORY_MAX_AGE", value: "0s"},
+		{name: "negative max age", key: "ARR_HISTORY_MAX_AGE", value: "-1m"},
+		{name: "zero size", key: "ARR_HISTORY_SIZE", value: "0"},
+		{name: "negative size", key: "ARR_HISTORY_SIZE", value: "-5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			t.Setenv("DOWNLOAD_DIR", t.TempDir())
+			t.Setenv(tt.key, tt.value)
+
+			_, err := loadConfig()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.key)
+		})
+	}
+}
+
+// A polling interval or a parallelism the pipeline cannot run with is refused
+// at startup, and by a reload, which leaves the running values as they were.
+func TestReload_RefusesAnUnusablePollingIntervalOrParallelism(t *testing.T) {
+	tests := []struct {
+		name, key, value string
+	}{
+		{name: "zero polling interval", key: "POLLING_INTERVAL", value: "0s"},
+		{name: "negative polling interval", key: "POLLING_INTERVAL", value: "-1m"},
+		{name: "zero parallelism", key: "MAX_PARALLEL", value: "0"},
+		{name: "negative parallelism", key: "MAX_PARALLEL", value: "-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, svcs := newTestServices(t)
+			running := *cfg
+			reload := newReloader(cfg, svcs)
+
+			t.Setenv(tt.key, tt.value)
+
+			_, err := loadConfig()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.key)
+
+			_, err = reload.Reload(context.Background())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.key)
+
+			assert.Equal(t, running.PollingInterval, cfg.PollingInterval)
+			assert.Equal(t, running.MaxParallel, cfg.MaxParallel)
+		})
+	}
+}
+
+// eventRecorder keeps the notifications sent to it.
+type eventRecorder struct {
+	events []notifier.Event
+}
+
+func (r *eventRecorder) Notify(string) error              { return nil }
+func (r *eventRecorder) NotifyEmbed(notifier.Embed) error { return nil }
+
+func (r *eventRecorder) NotifyEvent(event notifier.Event) error {
+	r.events = append(r.events, event)
+
+	return nil
+}
+
+// A failed download is its transfer's end until someone retries it: the
+// notification tells of the error from its report, and the report goes.
+func TestHandleDownloadError_ForgetsTheReport(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Server.Error",
+		Root: seedbox.Entry{Name: "Server.Error", Children: []seedbox.Entry{
+			{Name: "episode.mkv", Content: "never reaches disk", Status: http.StatusInternalServerError},
+		}},
+	})
+
+	cfg, _, svcs := newTestServices(t)
+	ctx := context.Background()
+	logger := logctx.LoggerFromContext(ctx)
+
+	client := sb.Client()
+	dl := downloader.NewDownloader(cfg.DownloadDir, 1, client, client, nil)
+
+	transfers, err := client.GetTaggedTorrents(ctx, sb.Label())
+	require.NoError(t, err)
+	require.Len(t, transfers, 1)
+
+	tr := transfers[0]
+
+	_, err = dl.Download(ctx, tr)
+	require.Error(t, err)
+	require.Error(t, dl.Report(tr.ID).Err)
+
+	sent := &eventRecorder{}
+	handleDownloadError(ctx, logger, svcs.repo, sent, dl, tr)
+
+	require.Len(t, sent.events, 1)
+	assert.Equal(t, notifier.EventDownloadFailed, sent.events[0].Type)
+	assert.NotEmpty(t, sent.events[0].Error, "the notification tells of the error")
+	assert.Equal(t, downloader.Report{}, dl.Report(tr.ID), "the report is forgotten")
+}
+
+// The db commands write what they export, and what they did, to the writer
+// they are given, and an import reads from the reader it is given.
+func TestDBCommands_WriteToTheirOutput(t *testing.T) {
+	_, _, svcs := newTestServices(t)
+	ctx := context.Background()
+
+	claimed, err := svcs.repo.ClaimTransfer("42")
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	var export bytes.Buffer
+	require.NoError(t, dbExport(ctx, nil, &export))
+	assert.Contains(t, export.String(), `"transfer_id": "42"`)
+
+	file := filepath.Join(t.TempDir(), "export.json")
+
+	var out bytes.Buffer
+	require.NoError(t, dbExport(ctx, []string{file}, &out))
+	assert.Equal(t, "exported 1 transfers and 0 notifications to "+file+"\n", out.String())
+
+	restored := filepath.Join(t.TempDir(), "restored.db")
+	t.Setenv("DB_PATH", restored)
+
+	out.Reset()
+	require.NoError(t, dbImport(ctx, []string{"-"}, &export, &out))
+	assert.Equal(t, "imported 1 transfers and 0 notifications into "+restored+"\n", out.String())
+}
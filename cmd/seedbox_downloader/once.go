@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/downloader"
+	"github.com/italolelis/seedbox_downloader/internal/logctx"
+	"github.com/italolelis/seedbox_downloader/internal/transfer"
+)
+
+// importedEvent is recorded in the history of a transfer found imported on a
+// run of its own, so that later runs, which cannot tell that from its status,
+// go straight to its seed ratio.
+const importedEvent = "imported"
+
+// partlyImportedEvent is recorded in the history of a transfer of which a run
+// found more files imported, with more still to go, so that later runs time
+// its import out from then rather than from its download.
+const partlyImportedEvent = "partly_imported"
+
+// awaiting is a downloaded transfer still on the seedbox: awaiting import or,
+// once imported, its seed ratio.
+type awaiting struct {
+	transfer     *transfer.Transfer
+	downloadedAt time.Time
+	// progressedAt is when a run last found more of its files imported.
+	progressedAt time.Time
+	imported     bool
+	// fresh is set for a transfer downloaded on this run, whose import is pushed.
+	fresh bool
+}
+
+// runOnce does one round of the service's work and returns, for cron on hosts
+// that should not keep a daemon running. It polls the seedbox straight away and
+// downloads every transfer there is to download. It then asks the *arr apps
+// once about each transfer awaiting import, downloaded on this run or an earlier
+// one, and cleans up those imported, or checks their seed ratio. It waits for
+// the notifications to go out, and fails if any transfer did, so that the exit
+// status tells.
+//
+// Nothing is served between runs, the Transmission RPC included, so the *arr
+// apps need the Deluge client or pushed imports to learn of downloads.
+func runOnce(ctx context.Context, cfg *config, svcs *services) error {
+	logger := logctx.LoggerFromContext(ctx).WithGroup("once")
+
+	caughtUp := setupLifecycleNotifications(ctx, svcs.events, svcs.notifiers)
+
+	logger.InfoContext(ctx, "polling once", "label", cfg.TargetLabel)
+
+	claimed, err := svcs.orchestrator.PollOnce(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to poll for transfers: %w", err)
+	}
+
+	downloaded, failed := downloadClaimed(ctx, logger, svcs, claimed)
+
+	earlier, err := awaitingFromEarlierRuns(ctx, cfg, svcs, downloaded)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range append(downloaded, earlier...) {
+		if !checkAwaiting(ctx, logger, cfg, svcs, a) {
+			failed++
+		}
+	}
+
+	logger.InfoContext(ctx, "run finished",
+		"claimed", len(claimed), "downloaded", len(downloaded), "checked", len(downloaded)+len(earlier), "failed", failed)
+
+	// Notifications are sent in the background, so wait for them to go out, as
+	// long as a shutdown is given to.
+	waitCtx, cancel := context.WithTimeout(ctx, cfg.Web.ShutdownTimeout)
+	defer cancel()
+
+	caughtUp(waitCtx)
+
+	if !svcs.outbox.Wait(waitCtx) {
+		logger.WarnContext(ctx, "notifications not yet sent are left for the next run")
+	}
+
+	// What notification targets are holding for a digest or the end of quiet
+	// hours goes into the outbox, for the next run to send.
+	if err := svcs.notifiers.Flush(); err != nil {
+		logger.WarnContext(ctx, "failed to queue held notifications", "err", err)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d transfers failed", failed)
+	}
+
+	return nil
+}
+
+// downloadClaimed downloads each transfer claimed, one after another as the
+// daemon does, and returns those downloaded and how many failed.
+func downloadClaimed(ctx context.Context, logger *slog.Logger, svcs *services, claimed []*transfer.Transfer) ([]awaiting, int) {
+	var (
+		downloaded []awaiting
+		failed     int
+	)
+
+	for _, t := range claimed {
+		ok, err := svcs.downloader.Download(ctx, t)
+
+		switch missingType := downloader.MissingType(err); {
+		case missingType != "":
+			handleTransferMissing(ctx, logger, svcs.ledger, svcs.notifiers, svcs.downloader,
+				downloader.MissingTransferEvent{Transfer: t, MissingType: missingType})
+
+			failed++
+		case err != nil:
+			handleDownloadError(ctx, logger, svcs.ledger, svcs.notifiers, svcs.downloader, t)
+
+			failed++
+		case ok:
+			if err := svcs.ledger.UpdateTransferStatus(t.ID, "downloaded"); err != nil {
+				logger.ErrorContext(ctx, "failed to update transfer status", "transfer_id", t.ID, "err", err)
+
+				failed++
+
+				continue
+			}
+
+			notifyDownloaded(ctx, logger, svcs.notifiers, svcs.downloader, t)
+
+			downloaded = append(downloaded, awaiting{transfer: t, downloadedAt: time.Now(), fresh: true})
+		}
+	}
+
+	return downloaded, failed
+}
+
+// awaitingFromEarlierRuns returns the transfers downloaded before this run that
+// are still on the seedbox, other than those in downloaded.
+func awaitingFromEarlierRuns(ctx context.Context, cfg *config, svcs *services, downloaded []awaiting) ([]awaiting, error) {
+	records, err := svcs.repo.GetDownloads()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list downloads: %w", err)
+	}
+
+	skip := make(map[string]bool, len(downloaded))
+	for _, a := range downloaded {
+		skip[a.transfer.ID] = true
+	}
+
+	wanted := map[string]bool{}
+
+	for _, record := range records {
+		if record.Status == "downloaded" && !skip[record.DownloadID] {
+			wanted[record.DownloadID] = true
+		}
+	}
+
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+
+	transfers, err := svcs.dc.GetTaggedTorrents(ctx, cfg.TargetLabel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tagged torrents: %w", err)
+	}
+
+	var earlier []awaiting
+
+	for _, t := range transfers {
+		if !wanted[t.ID] {
+			continue
+		}
+
+		history, err := svcs.repo.GetTransferHistory(t.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the history of transfer %s: %w", t.ID, err)
+		}
+
+		a := awaiting{transfer: t}
+
+		// A transfer retried since it was imported is downloaded again, and
+		// awaits import again.
+		for _, e := range history {
+			switch e.Status {
+			case "downloaded":
+				a.downloadedAt, _ = time.Parse(time.RFC3339, e.At)
+				a.progressedAt, a.imported = time.Time{}, false
+			case partlyImportedEvent:
+				a.progressedAt, _ = time.Parse(time.RFC3339, e.At)
+			case importedEvent:
+				a.imported = true
+			}
+		}
+
+		earlier = append(earlier, a)
+	}
+
+	return earlier, nil
+}
+
+// checkAwaiting checks once on a transfer awaiting import or its seed ratio,
+// and does what the daemon's watches would on finding it imported, refused, or
+// seeded. It returns false if the transfer failed, or could not be checked.
+func checkAwaiting(ctx context.Context, logger *slog.Logger, cfg *config, svcs *services, a awaiting) bool {
+	t := a.transfer
+
+	if !a.imported {
+		if a.fresh {
+			for _, result := range svcs.downloader.PushImports(ctx, t) {
+				handleImportScanFinished(ctx, logger, svcs.ledger, result)
+			}
+		}
+
+		since := a.downloadedAt
+		if a.progressedAt.After(since) {
+			since = a.progressedAt
+		}
+
+		imported, refused, err := svcs.downloader.CheckImport(ctx, t, since)
+
+		switch {
+		case err != nil:
+			logger.ErrorContext(ctx, "failed to check for imported transfer", "transfer_id", t.ID, "err", err)
+
+			return false
+		case refused != nil:
+			handleTransferImportFailed(ctx, logger, svcs.ledger, svcs.notifiers, svcs.downloader, *refused, cfg.ImportFailedCleanup)
+
+			return false
+		case !imported:
+			logger.InfoContext(ctx, "transfer awaiting import", "transfer_id", t.ID, "transfer_name", t.Name)
+
+			if !svcs.downloader.Report(t.ID).ImportProgressAt.IsZero() {
+				if err := svcs.ledger.RecordTransferEvent(t.ID, partlyImportedEvent); err != nil {
+					logger.ErrorContext(ctx, "failed to record import progress", "transfer_id", t.ID, "err", err)
+				}
+			}
+
+			return true
+		}
+
+		notifyImported(ctx, logger, svcs.notifiers, svcs.downloader, t)
+
+		if err := svcs.ledger.RecordTransferEvent(t.ID, importedEvent); err != nil {
+			logger.ErrorContext(ctx, "failed to record import", "transfer_id", t.ID, "err", err)
+		}
+	}
+
+	if cfg.PutioSeedRatio > 0 {
+		if !svcs.downloader.CheckSeeding(ctx, t, cfg.PutioSeedRatio) {
+			logger.InfoContext(ctx, "transfer seeding", "transfer_id", t.ID, "transfer_name", t.Name, "seed_ratio", cfg.PutioSeedRatio)
+		}
+
+		return true
+	}
+
+	svcs.downloader.CleanupTransfer(ctx, t)
+
+	return true
+}
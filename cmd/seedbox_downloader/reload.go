@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	configfile "github.com/italolelis/seedbox_downloader/internal/config"
+	"github.com/italolelis/seedbox_downloader/internal/health"
+	"github.com/italolelis/seedbox_downloader/internal/http/rest"
+	"github.com/italolelis/seedbox_downloader/internal/logctx"
+	"github.com/italolelis/seedbox_downloader/internal/notifier"
+	"github.com/italolelis/seedbox_downloader/internal/svc/arr"
+)
+
+// Groups of settings a reload applies to the running service. The settings of
+// a group are applied together, since they build one thing between them.
+const (
+	reloadLogLevel        = "log_level"
+	reloadPollingInterval = "polling_interval"
+	reloadMaxParallel     = "max_parallel"
+	reloadNotifiers       = "notifiers"
+	reloadArr             = "arr"
+)
+
+// reloadGroup returns the group the setting key belongs to, or false if it
+// takes a restart.
+func reloadGroup(key string) (string, bool) {
+	switch {
+	case key == "LOG_LEVEL":
+		return reloadLogLevel, true
+	case key == "POLLING_INTERVAL":
+		return reloadPollingInterval, true
+	case key == "MAX_PARALLEL":
+		return reloadMaxParallel, true
+	case key == "NOTIFY_TARGETS", key == "DISCORD_WEBHOOK_URL":
+		return reloadNotifiers, true
+	case key == "ARR_INSTANCES", key == "PATH_MAPPINGS",
+		strings.HasPrefix(key, "SONARR_"), strings.HasPrefix(key, "RADARR_"), strings.HasPrefix(key, "ARR_HISTORY_"):
+		return reloadArr, true
+	}
+
+	return "", false
+}
+
+// restartReason explains why a change to the setting key waits for a restart.
+func restartReason(key string) string {
+	switch {
+	case strings.HasPrefix(key, "DB_"):
+		return "the database is opened once, at startup"
+	case strings.HasPrefix(key, "WEB_"), strings.HasPrefix(key, "TELEMETRY_PROMETHEUS_"):
+		return "the listeners are bound at startup"
+	case strings.HasPrefix(key, "TELEMETRY_"):
+		return "telemetry is set up at startup"
+	case key == "DOWNLOAD_CLIENT", strings.HasPrefix(key, "DELUGE_"), key == "PUTIO_TOKEN":
+		return "the download client is built and authenticated at startup"
+	case key == "TRANSMISSION_USERNAME", key == "TRANSMISSION_PASSWORD", key == "API_KEY":
+		return "credentials are only read at startup"
+	case key == "DRY_RUN":
+		return "a dry run is only entered or left on a restart"
+	case key == "DOWNLOAD_DIR", key == "TARGET_LABEL":
+		return "transfers in flight are tracked under it"
+	default:
+		return "it is only read at startup"
+	}
+}
+
+// reloader reloads the configuration on SIGHUP or through the API, applying to
+// the running service what can change without a restart: the log level, the
+// polling interval, parallelism, notification targets and the *arr instances.
+// Any other setting that changed is refused, and keeps its running value.
+type reloader struct {
+	svcs *services
+	// transmission and health are set once the server is.
+	transmission *rest.TransmissionHandler
+	health       atomic.Pointer[health.Handler]
+
+	mu sync.Mutex
+	// cfg is the running configuration: as loaded at startup, with the changes
+	// reloads applied since.
+	cfg *config
+}
+
+func newReloader(cfg *config, svcs *services) *reloader {
+	return &reloader{svcs: svcs, cfg: cfg}
+}
+
+// Reload loads the configuration again and applies what changed. An invalid
+// configuration is refused whole, and nothing of it applied.
+func (r *reloader) Reload(ctx context.Context) (rest.APIReload, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	logger := logctx.LoggerFromContext(ctx).With("component", "config", "operation", "reload")
+
+	next, err := loadConfig()
+	if err != nil {
+		return rest.APIReload{}, err
+	}
+
+	// serve --dry-run is not in what is loaded again, and a reload never ends a
+	// dry run.
+	next.DryRun = next.DryRun || r.cfg.DryRun
+
+	result := rest.APIReload{Applied: []string{}, Refused: []string{}}
+	groups := map[string]bool{}
+
+	for _, key := range configfile.Changed(r.cfg, next) {
+		group, ok := reloadGroup(key)
+		if !ok {
+			logger.WarnContext(ctx, "setting changed, restart to apply it", "setting", key, "reason", restartReason(key))
+
+			result.Refused = append(result.Refused, key)
+
+			continue
+		}
+
+		groups[group] = true
+		result.Applied = append(result.Applied, key)
+	}
+
+	// Nor does one start: notification targets built again stay as the
+	// running ones are, logged or sent.
+	next.DryRun = r.cfg.DryRun
+
+	if err := r.apply(ctx, next, groups); err != nil {
+		return rest.APIReload{}, err
+	}
+
+	logger.InfoContext(ctx, "configuration reloaded", "applied", result.Applied, "refused", result.Refused)
+
+	return result, nil
+}
+
+// apply applies the groups of settings of next to the running service.
+// Everything built from the settings is built first, so that an invalid one
+// fails the reload before anything has changed.
+func (r *reloader) apply(ctx context.Context, next *config, groups map[string]bool) error {
+	logger := logctx.LoggerFromContext(ctx)
+	running := *r.cfg
+	svcs := r.svcs
+
+	var (
+		clients   []*arr.Client
+		opts      []rest.TransmissionOption
+		notifiers notifier.Multi
+		keys      []string
+		err       error
+	)
+
+	if groups[reloadArr] {
+		if clients, err = buildArrClients(ctx, next); err != nil {
+			return err
+		}
+
+		if opts, err = transmissionOptions(next, svcs.repo, clients); err != nil {
+			return err
+		}
+	}
+
+	// Building the targets queues them in the outbox, which is why it comes
+	// after everything that can still fail.
+	if groups[reloadNotifiers] {
+		if notifiers, keys, err = buildNotifier(ctx, next, svcs.outbox); err != nil {
+			return err
+		}
+	}
+
+	if groups[reloadArr] {
+		running.ArrInstances, running.Sonarr, running.Radarr = next.ArrInstances, next.Sonarr, next.Radarr
+		running.PathMappings, running.ArrHistory = next.PathMappings, next.ArrHistory
+
+		svcs.arrApps = clients
+		svcs.downloader.SetArrServices(clients)
+
+		if r.transmission != nil {
+			r.transmission.Reconfigure(opts...)
+		}
+	}
+
+	if groups[reloadNotifiers] {
+		running.NotifyTargets, running.DiscordWebhookURL = next.NotifyTargets, next.DiscordWebhookURL
+
+		// What the replaced targets hold for a digest or the end of quiet hours
+		// is sent now, as it would be on shutdown.
+		if err := svcs.notifiers.Set(notifiers).Flush(); err != nil {
+			logger.WarnContext(ctx, "failed to queue held notifications", "err", err)
+		}
+
+		// A target no longer configured is sent nothing more, neither what waits
+		// for it nor what is still being retried.
+		if err := svcs.outbox.Retain(ctx, keys...); err != nil {
+			logger.WarnContext(ctx, "failed to drop the notifications of removed targets", "err", err)
+		}
+	}
+
+	if groups[reloadLogLevel] {
+		running.LogLevel.Set(next.LogLevel.Level())
+	}
+
+	if groups[reloadPollingInterval] {
+		running.PollingInterval = next.PollingInterval
+		svcs.orchestrator.SetPollingInterval(next.PollingInterval)
+	}
+
+	if groups[reloadMaxParallel] {
+		running.MaxParallel = next.MaxParallel
+		svcs.downloader.SetMaxParallel(next.MaxParallel)
+	}
+
+	r.cfg = &running
+
+	// The probes check the *arr instances, and the orchestrator's heartbeat by
+	// the polling interval. Their own settings are never reloaded, so building
+	// them again cannot fail where it did not at startup.
+	if r.health.Load() != nil {
+		healthHandler, err := newHealthHandler(r.cfg, svcs)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to rebuild the health probes", "err", err)
+
+			return nil
+		}
+
+		r.health.Store(healthHandler)
+	}
+
+	return nil
+}
@@ -0,0 +1,457 @@
+// Package config loads a configuration struct tagged for envconfig from a YAML
+// file as well as from the environment.
+//
+// The file mirrors the environment variables: each is a key, lower-cased, and
+// the variables of a nested struct are a section of their own, so
+// WEB_BIND_ADDRESS is bind_address under web. Lists are YAML sequences, and a
+// field decoded from JSON takes its value as YAML. A key the struct does not
+// have is an error, never ignored.
+//
+// Values are taken in order of precedence from the environment, from a file an
+// environment variable with the suffix _FILE names (a Docker secret, say), from
+// the YAML file, and from the struct's defaults.
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kelseyhightower/envconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// FileSuffix marks a variable holding the path of a file to read a value from.
+const FileSuffix = "_FILE"
+
+// Load fills spec, a pointer to a struct envconfig can process, from the YAML
+// file at path, from secret files and from the environment. An empty path reads
+// no file.
+//
+// The values found are handed to envconfig through the environment for the
+// length of the call, and removed again before it returns; Load must not be
+// called while anything else is reading or writing those variables.
+func Load(path string, spec any) error {
+	t := reflect.TypeOf(spec)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return envconfig.ErrInvalidSpecification
+	}
+
+	root := schema(t.Elem(), "")
+
+	values := map[string]string{}
+
+	if path != "" {
+		if err := readFile(path, root, values); err != nil {
+			return err
+		}
+	}
+
+	if err := readSecrets(root, values); err != nil {
+		return err
+	}
+
+	var set []string
+
+	defer func() {
+		for _, key := range set {
+			os.Unsetenv(key)
+		}
+	}()
+
+	for key, value := range values {
+		if _, ok := os.LookupEnv(key); ok {
+			continue
+		}
+
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+
+		set = append(set, key)
+	}
+
+	if err := envconfig.Process("", spec); err != nil {
+		// envconfig's own message repeats the value, which may well be a secret.
+		var parseErr *envconfig.ParseError
+		if errors.As(err, &parseErr) {
+			return fmt.Errorf("invalid %s: %w", parseErr.KeyName, parseErr.Err)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Changed lists the environment variables whose values differ between a and b,
+// in sorted order.
+func Changed[T any](a, b *T) []string {
+	var changed []string
+
+	changes(reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem(), "", &changed)
+	sort.Strings(changed)
+
+	return changed
+}
+
+func changes(a, b reflect.Value, prefix string, changed *[]string) {
+	t := a.Type()
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() || f.Tag.Get("ignored") == "true" {
+			continue
+		}
+
+		env := envName(f)
+		if prefix != "" {
+			env = prefix + "_" + env
+		}
+
+		fa, fb := a.Field(i), b.Field(i)
+
+		ft := f.Type
+		if ft.Kind() == reflect.Struct && !decodes(ft) {
+			inner := env
+			if f.Anonymous {
+				inner = prefix
+			}
+
+			changes(fa, fb, inner, changed)
+
+			continue
+		}
+
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			*changed = append(*changed, env)
+		}
+	}
+}
+
+// field is a key of the configuration: a value, or a section of further keys.
+type field struct {
+	// env is the environment variable of a value.
+	env  string
+	kind kind
+	// keys are a section's fields by their key in the file.
+	keys map[string]*field
+}
+
+type kind int
+
+const (
+	section kind = iota
+	scalar
+	list
+	// object is a field that decodes itself from JSON.
+	object
+)
+
+var (
+	decoderType           = reflect.TypeOf((*envconfig.Decoder)(nil)).Elem()
+	setterType            = reflect.TypeOf((*envconfig.Setter)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+
+	// As envconfig splits a field name into words.
+	gatherRegexp  = regexp.MustCompile("([^A-Z]+|[A-Z]+[^A-Z]+|[A-Z]+)")
+	acronymRegexp = regexp.MustCompile("([A-Z]+)([A-Z][^A-Z]+)")
+)
+
+// schema describes the struct t as envconfig reads it, its variables prefixed
+// with prefix.
+func schema(t reflect.Type, prefix string) *field {
+	s := &field{kind: section, keys: map[string]*field{}}
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() || f.Tag.Get("ignored") == "true" {
+			continue
+		}
+
+		name := envName(f)
+
+		env := name
+		if prefix != "" {
+			env = prefix + "_" + name
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		switch {
+		case decodes(ft) && ft.Kind() == reflect.Slice:
+			s.keys[strings.ToLower(name)] = &field{env: env, kind: object}
+		case decodes(ft):
+			s.keys[strings.ToLower(name)] = &field{env: env, kind: scalar}
+		case ft.Kind() == reflect.Struct && f.Anonymous:
+			for key, inner := range schema(ft, prefix).keys {
+				s.keys[key] = inner
+			}
+		case ft.Kind() == reflect.Struct:
+			s.keys[strings.ToLower(name)] = schema(ft, env)
+		case ft.Kind() == reflect.Slice:
+			s.keys[strings.ToLower(name)] = &field{env: env, kind: list}
+		default:
+			s.keys[strings.ToLower(name)] = &field{env: env, kind: scalar}
+		}
+	}
+
+	return s
+}
+
+// envName is the variable name of f, without its prefix.
+func envName(f reflect.StructField) string {
+	if tag := f.Tag.Get("envconfig"); tag != "" {
+		return strings.ToUpper(tag)
+	}
+
+	if f.Tag.Get("split_words") != "true" {
+		return strings.ToUpper(f.Name)
+	}
+
+	var words []string
+
+	for _, match := range gatherRegexp.FindAllString(f.Name, -1) {
+		if m := acronymRegexp.FindStringSubmatch(match); len(m) == 3 {
+			words = append(words, m[1], m[2])
+		} else {
+			words = append(words, match)
+		}
+	}
+
+	return strings.ToUpper(strings.Join(words, "_"))
+}
+
+// decodes reports whether envconfig has t decode itself.
+func decodes(t reflect.Type) bool {
+	p := reflect.PointerTo(t)
+
+	return p.Implements(decoderType) || p.Implements(setterType) ||
+		p.Implements(textUnmarshalerType) || p.Implements(binaryUnmarshalerType)
+}
+
+// readFile reads the YAML file at path into values, by variable.
+func readFile(path string, root *field, values map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	// An empty file is no configuration at all.
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	r := reader{path: path, values: values}
+
+	return r.section(doc.Content[0], root, "")
+}
+
+// reader walks a YAML document alongside the schema.
+type reader struct {
+	path   string
+	values map[string]string
+}
+
+func (r reader) errorf(node *yaml.Node, format string, args ...any) error {
+	return fmt.Errorf("%s:%d:%d: %s", r.path, node.Line, node.Column, fmt.Sprintf(format, args...))
+}
+
+func (r reader) section(node *yaml.Node, s *field, at string) error {
+	if node.Kind != yaml.MappingNode {
+		if at == "" {
+			return r.errorf(node, "the configuration must be a mapping of keys to values")
+		}
+
+		return r.errorf(node, "%s is a section of keys, not a value", at)
+	}
+
+	seen := map[string]bool{}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		key := keyNode.Value
+
+		path := key
+		if at != "" {
+			path = at + "." + key
+		}
+
+		if seen[key] {
+			return r.errorf(keyNode, "%s is set more than once", path)
+		}
+
+		seen[key] = true
+
+		f, ok := s.keys[key]
+		if !ok {
+			return r.errorf(keyNode, "unknown key %q%s", path, suggest(key, s))
+		}
+
+		if err := r.value(valueNode, f, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r reader) value(node *yaml.Node, f *field, at string) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	// A key with nothing after it is as good as left out.
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+
+	switch f.kind {
+	case section:
+		return r.section(node, f, at)
+	case scalar:
+		if node.Kind != yaml.ScalarNode {
+			return r.errorf(node, "%s takes a single value", at)
+		}
+
+		r.values[f.env] = node.Value
+	case list:
+		if node.Kind == yaml.ScalarNode {
+			r.values[f.env] = node.Value
+
+			return nil
+		}
+
+		if node.Kind != yaml.SequenceNode {
+			return r.errorf(node, "%s takes a list of values", at)
+		}
+
+		items := make([]string, 0, len(node.Content))
+
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return r.errorf(item, "%s takes a list of single values", at)
+			}
+
+			// The environment separates items with commas, and so cannot carry one in an item.
+			if strings.Contains(item.Value, ",") {
+				return r.errorf(item, "items of %s cannot contain a comma", at)
+			}
+
+			items = append(items, item.Value)
+		}
+
+		r.values[f.env] = strings.Join(items, ",")
+	case object:
+		var v any
+		if err := node.Decode(&v); err != nil {
+			return r.errorf(node, "invalid %s: %v", at, err)
+		}
+
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return r.errorf(node, "invalid %s: %v", at, err)
+		}
+
+		r.values[f.env] = string(encoded)
+	}
+
+	return nil
+}
+
+// suggest names the key in s that key is most likely a misspelling of, or else
+// lists them all.
+func suggest(key string, s *field) string {
+	known := make([]string, 0, len(s.keys))
+	for k := range s.keys {
+		known = append(known, k)
+	}
+
+	sort.Strings(known)
+
+	best, bestDistance := "", len(key)/3+1
+	for _, k := range known {
+		if d := distance(key, k); d < bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+
+	if best != "" {
+		return fmt.Sprintf(", did you mean %q?", best)
+	}
+
+	return "; expected one of " + strings.Join(known, ", ")
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
+
+// readSecrets reads the value of every variable set with FileSuffix, and not
+// itself, from the file it names.
+func readSecrets(s *field, values map[string]string) error {
+	for _, f := range s.keys {
+		if f.kind == section {
+			if err := readSecrets(f, values); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		path, ok := os.LookupEnv(f.env + FileSuffix)
+		if !ok {
+			continue
+		}
+
+		if _, ok := os.LookupEnv(f.env); ok {
+			return fmt.Errorf("both %s and %s%s are set", f.env, f.env, FileSuffix)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s%s: %w", f.env, FileSuffix, err)
+		}
+
+		values[f.env] = strings.TrimRight(string(data), "\r\n")
+	}
+
+	return nil
+}
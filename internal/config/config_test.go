@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type target struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type targets []target
+
+func (t *targets) Decode(value string) error {
+	return json.Unmarshal([]byte(value), (*[]target)(t))
+}
+
+// spec has a field of every shape the service's configuration has.
+type spec struct {
+	DownloadDir     string         `envconfig:"DOWNLOAD_DIR" required:"true"`
+	PollingInterval time.Duration  `envconfig:"POLLING_INTERVAL" default:"10m"`
+	LogLevel        *slog.LevelVar `envconfig:"LOG_LEVEL" default:"INFO"`
+	Token           string         `envconfig:"TOKEN"`
+	Patterns        []string       `envconfig:"PATTERNS" default:"*.nfo"`
+	Targets         targets        `envconfig:"TARGETS"`
+
+	Web struct {
+		BindAddress string `split_words:"true" default:"0.0.0.0:9091"`
+	}
+
+	Outbox struct {
+		MaxAttempts int `split_words:"true" default:"10"`
+	} `envconfig:"NOTIFY_OUTBOX"`
+}
+
+func write(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestLoad_File(t *testing.T) {
+	path := write(t, "config.yaml", `
+download_dir: /downloads
+log_level: debug
+patterns: ["*.nfo", "*sample*"]
+targets:
+  - type: slack
+    url: https://hooks.slack.com/x
+web:
+  bind_address: 127.0.0.1:9091
+notify_outbox:
+  max_attempts: 3
+`)
+
+	var cfg spec
+	require.NoError(t, Load(path, &cfg))
+
+	assert.Equal(t, "/downloads", cfg.DownloadDir)
+	assert.Equal(t, 10*time.Minute, cfg.PollingInterval, "defaults fill what the file leaves out")
+	assert.Equal(t, slog.LevelDebug, cfg.LogLevel.Level())
+	assert.Equal(t, []string{"*.nfo", "*sample*"}, cfg.Patterns)
+	assert.Equal(t, targets{{Type: "slack", URL: "https://hooks.slack.com/x"}}, cfg.Targets)
+	assert.Equal(t, "127.0.0.1:9091", cfg.Web.BindAddress)
+	assert.Equal(t, 3, cfg.Outbox.MaxAttempts)
+
+	_, set := os.LookupEnv("DOWNLOAD_DIR")
+	assert.False(t, set, "the environment is left as it was")
+}
+
+func TestLoad_EnvironmentOverridesFile(t *testing.T) {
+	path := write(t, "config.yaml", "download_dir: /downloads\nweb:\n  bind_address: 127.0.0.1:9091\n")
+
+	t.Setenv("WEB_BIND_ADDRESS", "0.0.0.0:8080")
+
+	var cfg spec
+	require.NoError(t, Load(path, &cfg))
+
+	assert.Equal(t, "/downloads", cfg.DownloadDir)
+	assert.Equal(t, "0.0.0.0:8080", cfg.Web.BindAddress)
+}
+
+func TestLoad_Secrets(t *testing.T) {
+	t.Setenv("DOWNLOAD_DIR", "/downloads")
+	t.Setenv("TOKEN_FILE", write(t, "token", "s3cret\n"))
+
+	var cfg spec
+	require.NoError(t, Load("", &cfg))
+	assert.Equal(t, "s3cret", cfg.Token, "read without the trailing newline")
+
+	t.Setenv("TOKEN", "other")
+	require.ErrorContains(t, Load("", &cfg), "both TOKEN and TOKEN_FILE are set")
+}
+
+func TestLoad_RejectsWhatTheSchemaDoesNot(t *testing.T) {
+	for _, tc := range []struct {
+		name, file, err string
+	}{
+		{"misspelt", "download_dir: /d\npolling_intervall: 1m\n", `config.yaml:2:1: unknown key "polling_intervall", did you mean "polling_interval"?`},
+		{"unknown in section", "web:\n  port: 80\n", `config.yaml:2:3: unknown key "web.port"; expected one of bind_address`},
+		{"value for section", "web: 80\n", "web is a section of keys, not a value"},
+		{"list for value", "download_dir: [a, b]\n", "download_dir takes a single value"},
+		{"twice", "download_dir: a\ndownload_dir: b\n", "download_dir is set more than once"},
+		{"comma", "patterns: ['a,b']\n", "items of patterns cannot contain a comma"},
+		{"not a mapping", "- a\n", "must be a mapping"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var cfg spec
+
+			err := Load(write(t, "config.yaml", tc.file), &cfg)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	var cfg spec
+	require.ErrorContains(t, Load(write(t, "config.yaml", "token: x\n"), &cfg), "DOWNLOAD_DIR")
+
+	err := Load(write(t, "config.yaml", "download_dir: /d\ntargets: {url: https://hooks.slack.com/s3cret}\n"), &cfg)
+	require.ErrorContains(t, err, "invalid TARGETS")
+	assert.NotContains(t, err.Error(), "s3cret", "values are left out of errors, lest they be secrets")
+}
+
+func TestChanged(t *testing.T) {
+	path := write(t, "config.yaml", "download_dir: /downloads\nweb:\n  bind_address: 127.0.0.1:9091\n")
+
+	var before, after spec
+	require.NoError(t, Load(path, &before))
+	require.NoError(t, Load(path, &after))
+	assert.Empty(t, Changed(&before, &after))
+
+	after.Web.BindAddress = "0.0.0.0:80"
+	after.LogLevel.Set(slog.LevelDebug)
+	after.Targets = targets{{Type: "slack"}}
+	assert.Equal(t, []string{"LOG_LEVEL", "TARGETS", "WEB_BIND_ADDRESS"}, Changed(&before, &after))
+}
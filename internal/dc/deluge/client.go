@@ -292,6 +292,67 @@ func (c *Client) getTaggedTorrentsRaw(ctx context.Context, tag string) ([]*Torre
 	return torrents, nil
 }
 
+// CheckLabel checks that label is one of Deluge's labels, which takes the Label
+// plugin to be enabled.
+func (c *Client) CheckLabel(ctx context.Context, label string) error {
+	logger := logctx.LoggerFromContext(ctx).With("tag", label, "method", "label.get_labels")
+
+	url := fmt.Sprintf("%s%s", c.BaseURL, c.APIPath)
+	payload := map[string]any{
+		"id":     3,
+		"method": "label.get_labels",
+		"params": []any{},
+	}
+	body, _ := json.Marshal(payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if c.cookie != "" {
+		req.AddCookie(&http.Cookie{Name: "_session_id", Value: c.cookie})
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+
+		return fmt.Errorf("request failed: %s", string(b))
+	}
+
+	var rpcResp struct {
+		Result []string `json:"result"`
+		Error  any      `json:"error"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return err
+	}
+
+	// Without the Label plugin the method does not exist.
+	if rpcResp.Error != nil {
+		return fmt.Errorf("failed to list labels, is the Label plugin enabled? %v", rpcResp.Error)
+	}
+
+	for _, l := range rpcResp.Result {
+		if l == label {
+			return nil
+		}
+	}
+
+	logger.DebugContext(ctx, "label not found", "labels", rpcResp.Result)
+
+	return fmt.Errorf("label not found: %s", label)
+}
+
 func (c *Client) buildDownloadRequest(ctx context.Context, file *transfer.File) (*http.Request, string, error) {
 	url := fmt.Sprintf("%s%s/%s", strings.TrimRight(c.BaseURL, "/"), strings.TrimRight(c.CompletedDir, "/"), file.Path)
 
@@ -124,3 +124,22 @@ func TestGetTaggedTorrents(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckLabel(t *testing.T) {
+	serve := func(response string) *deluge.Client {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, response)
+		}))
+		t.Cleanup(ts.Close)
+
+		return deluge.NewClient(ts.URL, "", "", "user", "pass")
+	}
+
+	client := serve(`{"result": ["sonarr", "radarr"], "error": null, "id": 3}`)
+	assert.NoError(t, client.CheckLabel(context.Background(), "sonarr"))
+	assert.ErrorContains(t, client.CheckLabel(context.Background(), "lidarr"), "label not found")
+
+	client = serve(`{"result": null, "error": {"message": "Unknown method"}, "id": 3}`)
+	assert.ErrorContains(t, client.CheckLabel(context.Background(), "sonarr"), "is the Label plugin enabled?")
+}
@@ -439,6 +439,14 @@ func (c *Client) filterMatchingTransferIds(transfers []putio.Transfer, transferI
 	return matchingTransfers
 }
 
+// CheckLabel checks that the folder named label exists, which is where the
+// *arr apps' transfers are added and where they are looked for.
+func (c *Client) CheckLabel(ctx context.Context, label string) error {
+	_, err := c.findDirectoryID(ctx, label)
+
+	return err
+}
+
 func (c *Client) findDirectoryID(ctx context.Context, downloadDir string) (int64, error) {
 	search, err := c.putioClient.Files.Search(ctx, downloadDir, 1)
 	if err != nil {
@@ -380,3 +380,28 @@ func TestGetTaggedTorrents_SaveParentIDMatching(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckLabel(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/files/search/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/v2/files/search/sonarr/page/1":
+			fmt.Fprint(w, `{"files":[{"id":200,"name":"sonarr","file_type":"FOLDER","content_type":"application/x-directory"}]}`)
+		case "/v2/files/search/show.mkv/page/1":
+			fmt.Fprint(w, `{"files":[{"id":100,"name":"show.mkv","file_type":"VIDEO","content_type":"video/x-matroska"}]}`)
+		default:
+			fmt.Fprint(w, `{"files":[]}`)
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+
+	require.NoError(t, client.CheckLabel(context.Background(), "sonarr"))
+	require.ErrorContains(t, client.CheckLabel(context.Background(), "radarr"), "directory not found")
+	require.ErrorContains(t, client.CheckLabel(context.Background(), "show.mkv"), "not a directory")
+}
@@ -0,0 +1,171 @@
+// Package doctor checks that everything the service depends on is reachable
+// and configured as it needs to be, and reports each finding with a hint at
+// how to put it right. The same checks back the doctor command and the
+// validation done at startup, so a misconfiguration is caught before it turns
+// up as an error deep in polling.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// checkTimeout bounds each check, so one hung dependency cannot hold up the
+// rest of the report.
+const checkTimeout = 30 * time.Second
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	Pass Status = "pass"
+	// Warn is an optional check failing: worth fixing, but no reason to refuse
+	// to start.
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// Check is one thing the service needs.
+type Check struct {
+	Name string
+	// Run checks it, and returns what it found when all is well.
+	Run func(ctx context.Context) (string, error)
+	// Remedy tells how to fix a failure, unless the error carries its own.
+	Remedy string
+	// Optional checks fail with Warn rather than Fail.
+	Optional bool
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name   string
+	Status Status
+	// Detail is what the check found, or why it failed.
+	Detail   string
+	Remedy   string
+	Duration time.Duration
+}
+
+// Report is the outcome of every check, in the order they were run.
+type Report struct {
+	Results []Result
+}
+
+// remedied is an error carrying the remedy for itself.
+type remedied struct {
+	err    error
+	remedy string
+}
+
+func (r *remedied) Error() string { return r.err.Error() }
+func (r *remedied) Unwrap() error { return r.err }
+
+// WithRemedy attaches remedy to err, for a check whose failures call for
+// different fixes. It takes the place of the check's own Remedy.
+func WithRemedy(err error, remedy string) error {
+	return &remedied{err: err, remedy: remedy}
+}
+
+// Run runs checks one after another, and reports on each.
+func Run(ctx context.Context, checks []Check) Report {
+	report := Report{Results: make([]Result, 0, len(checks))}
+
+	for _, c := range checks {
+		report.Results = append(report.Results, runOne(ctx, c))
+	}
+
+	return report
+}
+
+func runOne(ctx context.Context, c Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := c.Run(ctx)
+	result := Result{Name: c.Name, Status: Pass, Detail: detail, Duration: time.Since(start)}
+
+	if err == nil {
+		return result
+	}
+
+	result.Status, result.Detail, result.Remedy = Fail, err.Error(), c.Remedy
+	if c.Optional {
+		result.Status = Warn
+	}
+
+	var r *remedied
+	if errors.As(err, &r) {
+		result.Remedy = r.remedy
+	}
+
+	return result
+}
+
+// Failed reports whether a check that is not optional failed.
+func (r Report) Failed() bool {
+	return r.count(Fail) > 0
+}
+
+// Passed reports whether every check passed, optional or not.
+func (r Report) Passed() bool {
+	return r.count(Pass) == len(r.Results)
+}
+
+func (r Report) count(status Status) int {
+	var n int
+
+	for _, result := range r.Results {
+		if result.Status == status {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Print writes the report for a person to read: a line for each check, the
+// remedy under each that did not pass, and a summary.
+func (r Report) Print(w io.Writer) error {
+	width := 0
+	for _, result := range r.Results {
+		width = max(width, len(result.Name))
+	}
+
+	var b strings.Builder
+
+	for _, result := range r.Results {
+		fmt.Fprintf(&b, "%-4s  %-*s  %s\n", strings.ToUpper(string(result.Status)), width, result.Name, result.Detail)
+
+		if result.Status != Pass && result.Remedy != "" {
+			fmt.Fprintf(&b, "%-4s  %-*s  -> %s\n", "", width, "", result.Remedy)
+		}
+	}
+
+	fmt.Fprintf(&b, "\n%d passed, %d warnings, %d failed\n", r.count(Pass), r.count(Warn), r.count(Fail))
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// Log logs each check that did not pass, with its remedy, and a summary.
+func (r Report) Log(ctx context.Context, logger *slog.Logger) {
+	for _, result := range r.Results {
+		switch result.Status {
+		case Pass:
+			logger.DebugContext(ctx, "check passed", "check", result.Name, "detail", result.Detail)
+		case Warn:
+			logger.WarnContext(ctx, "check failed", "check", result.Name, "err", result.Detail, "remedy", result.Remedy)
+		case Fail:
+			logger.ErrorContext(ctx, "check failed", "check", result.Name, "err", result.Detail, "remedy", result.Remedy)
+		}
+	}
+
+	logger.InfoContext(ctx, "checks finished", "passed", r.count(Pass), "warnings", r.count(Warn), "failed", r.count(Fail))
+}
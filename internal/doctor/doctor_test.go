@@ -0,0 +1,64 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func passing(name, detail string) Check {
+	return Check{Name: name, Run: func(context.Context) (string, error) { return detail, nil }}
+}
+
+func failing(name string, err error, remedy string, optional bool) Check {
+	return Check{Name: name, Run: func(context.Context) (string, error) { return "", err }, Remedy: remedy, Optional: optional}
+}
+
+func TestRun(t *testing.T) {
+	report := Run(context.Background(), []Check{
+		passing("database", "downloads.db"),
+		failing("label", errors.New("folder not found"), "create the folder", true),
+		failing("download_client", WithRemedy(errors.New("401"), "renew the token"), "check the URL", false),
+	})
+
+	require.Len(t, report.Results, 3)
+	assert.Equal(t, Result{Name: "database", Status: Pass, Detail: "downloads.db"}, withoutDuration(report.Results[0]))
+	assert.Equal(t, Result{Name: "label", Status: Warn, Detail: "folder not found", Remedy: "create the folder"},
+		withoutDuration(report.Results[1]))
+	assert.Equal(t, "renew the token", report.Results[2].Remedy, "the error's own remedy is the more specific")
+	assert.True(t, report.Failed())
+	assert.False(t, report.Passed())
+}
+
+func TestReport_OptionalFailuresDoNotFail(t *testing.T) {
+	report := Run(context.Background(), []Check{failing("arr", errors.New("refused"), "", true)})
+
+	assert.False(t, report.Failed())
+	assert.False(t, report.Passed())
+}
+
+func TestReport_Print(t *testing.T) {
+	report := Run(context.Background(), []Check{
+		passing("database", "downloads.db"),
+		failing("target_label", errors.New("TARGET_LABEL is empty"), "set TARGET_LABEL", false),
+	})
+
+	var b strings.Builder
+	require.NoError(t, report.Print(&b))
+
+	assert.Equal(t, ""+
+		"PASS  database      downloads.db\n"+
+		"FAIL  target_label  TARGET_LABEL is empty\n"+
+		"                    -> set TARGET_LABEL\n"+
+		"\n1 passed, 0 warnings, 1 failed\n", b.String())
+}
+
+func withoutDuration(r Result) Result {
+	r.Duration = 0
+
+	return r
+}
@@ -0,0 +1,159 @@
+package downloader
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/downloader/progress"
+	"github.com/italolelis/seedbox_downloader/internal/transfer"
+)
+
+// maxRecentFailures bounds how many failures are remembered. The ledger keeps
+// every status change; this is only the recent past, with the error text the
+// ledger does not hold.
+const maxRecentFailures = 50
+
+// Watcher kinds, as reported in Activity.
+const (
+	WatchImport  = "import"
+	WatchSeeding = "seeding"
+)
+
+// Activity is a point-in-time view of what the downloader is doing. It lives in
+// memory only and starts empty on every restart.
+type Activity struct {
+	Downloads []FileProgress `json:"downloads"`
+	Watchers  []Watcher      `json:"watchers"`
+	Failures  []Failure      `json:"failures"`
+}
+
+// FileProgress is one file being written right now.
+type FileProgress struct {
+	TransferID   string    `json:"transfer_id"`
+	TransferName string    `json:"transfer_name"`
+	Path         string    `json:"path"`
+	Written      int64     `json:"written"`
+	Total        int64     `json:"total"`
+	StartedAt    time.Time `json:"started_at"`
+}
+
+// Watcher is a transfer waiting on something outside our control: an *arr app to
+// import it, or the seedbox to reach the seed ratio.
+type Watcher struct {
+	TransferID   string    `json:"transfer_id"`
+	TransferName string    `json:"transfer_name"`
+	Kind         string    `json:"kind"`
+	Since        time.Time `json:"since"`
+}
+
+// Failure is a transfer that failed to download, with the reason.
+type Failure struct {
+	TransferID   string    `json:"transfer_id"`
+	TransferName string    `json:"transfer_name"`
+	Error        string    `json:"error"`
+	At           time.Time `json:"at"`
+}
+
+type activeFile struct {
+	transfer *transfer.Transfer
+	path     string
+	reader   *progress.Reader
+	started  time.Time
+}
+
+// activity tracks what Activity reports. Progress is read from each file's
+// progress.Reader when a snapshot is taken, so nothing is recorded per read.
+type activity struct {
+	mu       sync.Mutex
+	files    map[*activeFile]struct{}
+	watchers map[string]Watcher
+	failures []Failure
+}
+
+func newActivity() *activity {
+	return &activity{
+		files:    map[*activeFile]struct{}{},
+		watchers: map[string]Watcher{},
+	}
+}
+
+func (a *activity) startFile(t *transfer.Transfer, path string, reader *progress.Reader) *activeFile {
+	f := &activeFile{transfer: t, path: path, reader: reader, started: time.Now()}
+
+	a.mu.Lock()
+	a.files[f] = struct{}{}
+	a.mu.Unlock()
+
+	return f
+}
+
+func (a *activity) finishFile(f *activeFile) {
+	a.mu.Lock()
+	delete(a.files, f)
+	a.mu.Unlock()
+}
+
+func (a *activity) startWatch(t *transfer.Transfer, kind string) {
+	a.mu.Lock()
+	a.watchers[kind+"/"+t.ID] = Watcher{TransferID: t.ID, TransferName: t.Name, Kind: kind, Since: time.Now()}
+	a.mu.Unlock()
+}
+
+func (a *activity) stopWatch(t *transfer.Transfer, kind string) {
+	a.mu.Lock()
+	delete(a.watchers, kind+"/"+t.ID)
+	a.mu.Unlock()
+}
+
+func (a *activity) recordFailure(t *transfer.Transfer, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.failures = append(a.failures, Failure{TransferID: t.ID, TransferName: t.Name, Error: err.Error(), At: time.Now()})
+	if len(a.failures) > maxRecentFailures {
+		a.failures = a.failures[len(a.failures)-maxRecentFailures:]
+	}
+}
+
+func (a *activity) snapshot() Activity {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := Activity{
+		Downloads: make([]FileProgress, 0, len(a.files)),
+		Watchers:  make([]Watcher, 0, len(a.watchers)),
+		Failures:  make([]Failure, 0, len(a.failures)),
+	}
+
+	for f := range a.files {
+		out.Downloads = append(out.Downloads, FileProgress{
+			TransferID:   f.transfer.ID,
+			TransferName: f.transfer.Name,
+			Path:         f.path,
+			Written:      f.reader.Written(),
+			Total:        f.reader.Total,
+			StartedAt:    f.started,
+		})
+	}
+
+	for _, w := range a.watchers {
+		out.Watchers = append(out.Watchers, w)
+	}
+
+	// Newest first: the most recent failure is the one being looked for.
+	for i := len(a.failures) - 1; i >= 0; i-- {
+		out.Failures = append(out.Failures, a.failures[i])
+	}
+
+	sort.Slice(out.Downloads, func(i, j int) bool {
+		if out.Downloads[i].TransferID != out.Downloads[j].TransferID {
+			return out.Downloads[i].TransferID < out.Downloads[j].TransferID
+		}
+
+		return out.Downloads[i].Path < out.Downloads[j].Path
+	})
+	sort.Slice(out.Watchers, func(i, j int) bool { return out.Watchers[i].Since.Before(out.Watchers[j].Since) })
+
+	return out
+}
@@ -2,8 +2,6 @@ package downloader
 
 import (
 	"context"
-	"crypto/sha1"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -12,6 +10,7 @@ import (
 	"path/filepath"
 	"runtime/debug"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -19,6 +18,7 @@ import (
 	"github.com/dustin/go-humanize"
 	"github.com/italolelis/seedbox_downloader/internal/dc/putio"
 	"github.com/italolelis/seedbox_downloader/internal/downloader/progress"
+	"github.com/italolelis/seedbox_downloader/internal/events"
 	"github.com/italolelis/seedbox_downloader/internal/logctx"
 	"github.com/italolelis/seedbox_downloader/internal/storage"
 	"github.com/italolelis/seedbox_downloader/internal/svc/arr"
@@ -32,8 +32,48 @@ type MissingTransferEvent struct {
 	MissingType string // "files_missing" or "transfer_removed"
 }
 
+// ImportFailedEvent carries a transfer the *arr apps will not import.
+type ImportFailedEvent struct {
+	Transfer *transfer.Transfer
+	// Instance is the *arr instance that gave up on it; empty on a timeout.
+	Instance string
+	// Reason is "download_failed", "download_ignored" or "timeout".
+	Reason string
+	// Message is the app's own explanation, when it gave one.
+	Message string
+}
+
+// ImportScanResult is how an import the downloader asked an *arr app for went.
+type ImportScanResult struct {
+	Transfer *transfer.Transfer
+	// Instance is the name of the *arr instance asked.
+	Instance string
+	// Command is the command as last seen; its ID is zero when it was never queued.
+	Command arr.Command
+	// Err is why the command could not be queued or followed to the end.
+	Err error
+}
+
+// Outcome names the result for the transfer's history: the command's final
+// status, "unsuccessful" for one that completed without importing, or "error"
+// when it could not be followed.
+func (r ImportScanResult) Outcome() string {
+	switch {
+	case r.Err != nil:
+		return "error"
+	case r.Command.Status == arr.CommandCompleted && !r.Command.Succeeded():
+		return "unsuccessful"
+	default:
+		return r.Command.Status
+	}
+}
+
 const (
 	dirPerm = 0755
+
+	// commandPollInterval is how often a pushed import is checked on. Imports are
+	// a matter of seconds, and the point of pushing is not waiting minutes.
+	commandPollInterval = 2 * time.Second
 )
 
 // ErrSizeMismatch reports that a file was written whose byte count does not match
@@ -47,8 +87,33 @@ type Downloader struct {
 	downloadDir string
 	dc          transfer.DownloadClient
 	tc          transfer.TransferClient
+	// arrServices and maxParallel may be changed while downloads run, by
+	// SetArrServices and SetMaxParallel.
+	arrMu       sync.RWMutex
 	arrServices []*arr.Client
-	maxParallel int
+	maxParallel atomic.Int64
+	activity    *activity
+	events      *events.Bus
+	// lastProgress is when the download loop last made progress, in Unix
+	// nanoseconds: took a transfer, read from a file, or finished one. busy is
+	// set while it holds a transfer; a loop idle for want of one is not stuck.
+	lastProgress atomic.Int64
+	busy         atomic.Bool
+	// pushImport asks the *arr apps to import a download as soon as it lands,
+	// giving each command up to pushTimeout to finish.
+	pushImport  bool
+	pushTimeout time.Duration
+	owners      OwnerLookup
+	// importTimeout gives up on an import that has not happened in this long.
+	// Zero waits forever.
+	importTimeout time.Duration
+	// importIgnore are base name patterns for files the apps are not expected to
+	// import, so do not hold up the transfer's cleanup.
+	importIgnore []string
+	reports      reports
+	// dryRun logs what would be written to disk, deleted from it, or removed
+	// from the seedbox, and does none of it.
+	dryRun bool
 
 	// Event channels. These are deliberately never closed: several goroutines
 	// send on them, so no single goroutine can correctly own closing them.
@@ -56,7 +121,76 @@ type Downloader struct {
 	OnTransferDownloadError    chan *transfer.Transfer
 	OnTransferDownloadFinished chan *transfer.Transfer
 	OnTransferImported         chan *transfer.Transfer
+	OnTransferImportFailed     chan ImportFailedEvent
 	OnTransferMissing          chan MissingTransferEvent
+	// OnImportScanFinished reports each pushed import; only sent with WithPushImport.
+	OnImportScanFinished chan ImportScanResult
+}
+
+// Option configures a Downloader.
+type Option func(*Downloader)
+
+// WithEvents publishes what the downloader does to bus, alongside the event
+// channels. The channels drive the pipeline; the bus is for observers.
+func WithEvents(bus *events.Bus) Option {
+	return func(d *Downloader) {
+		d.events = bus
+	}
+}
+
+// OwnerLookup tells which *arr instance added a transfer.
+type OwnerLookup interface {
+	// TransferOwner returns "" for a transfer with no recorded owner.
+	TransferOwner(transferID string) (string, error)
+}
+
+// WithOwners sends each transfer's import checks and pushed imports to the *arr
+// instance that added it, rather than to every instance. Transfers of unknown
+// origin still go to all of them.
+func WithOwners(owners OwnerLookup) Option {
+	return func(d *Downloader) {
+		d.owners = owners
+	}
+}
+
+// WithImportTimeout gives up watching for a transfer's import after timeout,
+// reporting it on OnTransferImportFailed as it does an import the apps refused.
+func WithImportTimeout(timeout time.Duration) Option {
+	return func(d *Downloader) {
+		d.importTimeout = timeout
+	}
+}
+
+// WithImportIgnore replaces DefaultImportIgnore: the base name patterns, as
+// path.Match takes them, of files that need not be imported before a transfer
+// counts as imported. Patterns are matched without regard to case.
+func WithImportIgnore(patterns []string) Option {
+	return func(d *Downloader) {
+		d.importIgnore = patterns
+	}
+}
+
+// WithPushImport has WatchForImported ask each *arr app to import the transfer
+// straight away, instead of leaving it to their completed download handling,
+// which only looks every minute or so. Each command is followed for up to
+// timeout and its outcome sent on OnImportScanFinished. The watch itself still
+// decides when the transfer is imported.
+func WithPushImport(timeout time.Duration) Option {
+	return func(d *Downloader) {
+		d.pushImport = true
+		d.pushTimeout = timeout
+	}
+}
+
+// WithDryRun downloads nothing, deletes nothing and cleans nothing up from the
+// seedbox, but logs what it would have: the path and size of every file a
+// download would write, every file an import would remove, and every transfer
+// cleanup would remove from Put.io. The *arr apps are still asked about imports,
+// but not to import.
+func WithDryRun() Option {
+	return func(d *Downloader) {
+		d.dryRun = true
+	}
 }
 
 func NewDownloader(
@@ -65,18 +199,79 @@ func NewDownloader(
 	dc transfer.DownloadClient,
 	tc transfer.TransferClient,
 	arrServices []*arr.Client,
+	opts ...Option,
 ) *Downloader {
-	return &Downloader{
+	d := &Downloader{
 		downloadDir:                downloadDir,
 		dc:                         dc,
-		maxParallel:                maxParallel,
 		tc:                         tc,
 		arrServices:                arrServices,
+		activity:                   newActivity(),
+		importIgnore:               DefaultImportIgnore,
 		OnTransferDownloadError:    make(chan *transfer.Transfer),
 		OnTransferDownloadFinished: make(chan *transfer.Transfer),
 		OnTransferImported:         make(chan *transfer.Transfer),
+		OnTransferImportFailed:     make(chan ImportFailedEvent),
 		OnTransferMissing:          make(chan MissingTransferEvent),
+		OnImportScanFinished:       make(chan ImportScanResult),
+	}
+
+	d.maxParallel.Store(int64(maxParallel))
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// SetArrServices replaces the *arr instances asked about imports. Watches
+// already running ask the new ones from their next check.
+func (d *Downloader) SetArrServices(arrServices []*arr.Client) {
+	d.arrMu.Lock()
+	defer d.arrMu.Unlock()
+
+	d.arrServices = arrServices
+}
+
+func (d *Downloader) arrClients() []*arr.Client {
+	d.arrMu.RLock()
+	defer d.arrMu.RUnlock()
+
+	return d.arrServices
+}
+
+// SetMaxParallel changes how many files of a transfer are downloaded at once,
+// from the next transfer on.
+func (d *Downloader) SetMaxParallel(maxParallel int) {
+	d.maxParallel.Store(int64(maxParallel))
+}
+
+// Activity reports the files being written, the transfers being watched, and
+// the most recent failures.
+func (d *Downloader) Activity() Activity {
+	return d.activity.snapshot()
+}
+
+// LastProgress reports when the download loop last made progress: took a
+// transfer, read from one of its files, or finished with it. While it waits for
+// a transfer there is nothing to make progress on, and it reports the present.
+// Zero until WatchDownloads has started.
+func (d *Downloader) LastProgress() time.Time {
+	nanos := d.lastProgress.Load()
+
+	switch {
+	case nanos == 0:
+		return time.Time{}
+	case !d.busy.Load():
+		return time.Now()
 	}
+
+	return time.Unix(0, nanos)
+}
+
+func (d *Downloader) progressed() {
+	d.lastProgress.Store(time.Now().UnixNano())
 }
 
 // WatchDownloads watches for transfers and downloads them.
@@ -85,6 +280,8 @@ func (d *Downloader) WatchDownloads(ctx context.Context, incomingTransfers <-cha
 
 	logger.InfoContext(ctx, "watching downloads")
 
+	d.progressed()
+
 	go func() {
 		for {
 			select {
@@ -93,39 +290,115 @@ func (d *Downloader) WatchDownloads(ctx context.Context, incomingTransfers <-cha
 
 				return
 			case transfer := <-incomingTransfers:
-				logger.DebugContext(ctx, "downloading transfer", "transfer_id", transfer.ID, "transfer_name", transfer.Name)
+				d.busy.Store(true)
+				d.progressed()
 
-				downloadedFiles, err := d.DownloadTransfer(ctx, transfer)
-				if err != nil {
-					if errors.Is(err, putio.ErrTransferNotFound) {
-						logger.WarnContext(ctx, "transfer removed from Put.io", "transfer_id", transfer.ID, "transfer_name", transfer.Name)
-						d.OnTransferMissing <- MissingTransferEvent{Transfer: transfer, MissingType: "transfer_removed"}
+				downloaded, err := d.Download(ctx, transfer)
 
-						continue
-					}
+				switch missingType := MissingType(err); {
+				case missingType != "":
+					d.OnTransferMissing <- MissingTransferEvent{Transfer: transfer, MissingType: missingType}
+				case err != nil:
+					d.OnTransferDownloadError <- transfer
+				case downloaded:
+					d.OnTransferDownloadFinished <- transfer
+				}
 
-					if errors.Is(err, putio.ErrTransferFilesNotFound) {
-						// Warn log already emitted inside DownloadTransfer for the specific file
-						d.OnTransferMissing <- MissingTransferEvent{Transfer: transfer, MissingType: "files_missing"}
+				d.progressed()
+				d.busy.Store(false)
+			}
+		}
+	}()
+}
 
-						continue
-					}
+// Download downloads t, recording how it went in its report and on the bus, and
+// returns whether anything was downloaded. WatchDownloads calls it for each
+// transfer queued; runs that do not stay to watch call it directly. A transfer
+// gone from Put.io, or whose files are, fails with an error MissingType names.
+func (d *Downloader) Download(ctx context.Context, t *transfer.Transfer) (bool, error) {
+	logger := logctx.LoggerFromContext(ctx)
 
-					logger.ErrorContext(ctx, "failed to download transfer", "download_id", transfer.ID, "err", err)
+	logger.DebugContext(ctx, "downloading transfer", "transfer_id", t.ID, "transfer_name", t.Name)
 
-					d.OnTransferDownloadError <- transfer
+	started := time.Now()
+	downloadedFiles, err := d.DownloadTransfer(ctx, t)
 
-					continue
-				}
+	d.reports.update(t.ID, func(r *Report) {
+		r.Duration, r.Err = time.Since(started), err
+	})
 
-				if downloadedFiles > 0 {
-					logger.InfoContext(ctx, "downloads completed", "download_id", transfer.ID, "transfer_name", transfer.Name)
+	if err != nil {
+		d.activity.recordFailure(t, err)
+
+		switch missingType := MissingType(err); missingType {
+		case "transfer_removed":
+			logger.WarnContext(ctx, "transfer removed from Put.io", "transfer_id", t.ID, "transfer_name", t.Name)
+			d.publishMissing(t, missingType)
+		case "files_missing":
+			// Warn log already emitted inside DownloadTransfer for the specific file
+			d.publishMissing(t, missingType)
+		default:
+			logger.ErrorContext(ctx, "failed to download transfer", "download_id", t.ID, "err", err)
+			d.events.Publish(events.TransferDownloadFailed, events.Transfer{ID: t.ID, Name: t.Name, Error: err.Error()})
+		}
 
-					d.OnTransferDownloadFinished <- transfer
-				}
-			}
+		return false, err
+	}
+
+	if downloadedFiles == 0 {
+		return false, nil
+	}
+
+	logger.InfoContext(ctx, "downloads completed", "download_id", t.ID, "transfer_name", t.Name)
+
+	d.events.Publish(events.TransferDownloaded, events.Transfer{ID: t.ID, Name: t.Name})
+
+	if err := d.VerifyTransfer(t); err != nil {
+		d.reports.update(t.ID, func(r *Report) { r.Err = err })
+		d.activity.recordFailure(t, err)
+
+		logger.ErrorContext(ctx, "downloaded transfer failed verification", "download_id", t.ID, "err", err)
+		d.events.Publish(events.TransferDownloadFailed, events.Transfer{ID: t.ID, Name: t.Name, Error: err.Error()})
+
+		return false, err
+	}
+
+	d.events.Publish(events.TransferVerified, events.Transfer{ID: t.ID, Name: t.Name})
+
+	return true, nil
+}
+
+// VerifyTransfer checks every file of t on disk against the size the seedbox
+// reported for it. Each file's byte count is checked as it is written; this
+// checks what is on disk once the whole transfer is, so a file removed or
+// changed since it was written fails too.
+func (d *Downloader) VerifyTransfer(t *transfer.Transfer) error {
+	for _, file := range t.Files {
+		info, err := os.Stat(filepath.Join(d.downloadDir, file.Path))
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %w", file.Path, err)
 		}
-	}()
+
+		if file.Size > 0 && info.Size() != file.Size {
+			return fmt.Errorf("%w: %s expected %d bytes, found %d", ErrSizeMismatch, file.Path, file.Size, info.Size())
+		}
+	}
+
+	return nil
+}
+
+// MissingType classifies an error from Download: "transfer_removed" for a
+// transfer gone from Put.io, "files_missing" for one whose files are, and "" for
+// any other failure, or none.
+func MissingType(err error) string {
+	switch {
+	case errors.Is(err, putio.ErrTransferNotFound):
+		return "transfer_removed"
+	case errors.Is(err, putio.ErrTransferFilesNotFound):
+		return "files_missing"
+	default:
+		return ""
+	}
 }
 
 // DownloadTransfer downloads a transfer and returns the number of files downloaded.
@@ -140,12 +413,18 @@ func (d *Downloader) DownloadTransfer(ctx context.Context, transfer *transfer.Tr
 
 	logger := logctx.LoggerFromContext(ctx)
 
+	if d.dryRun {
+		d.logDryRunDownload(ctx, logger, transfer)
+
+		return 0, nil
+	}
+
 	logger.InfoContext(ctx, "starting download",
 		"transfer_id", transfer.ID,
 		"transfer_name", transfer.Name,
 		"file_count", len(transfer.Files))
 
-	sem := make(chan struct{}, d.maxParallel)
+	sem := make(chan struct{}, d.maxParallel.Load())
 
 	for i := range transfer.Files {
 		file := transfer.Files[i]
@@ -155,7 +434,7 @@ func (d *Downloader) DownloadTransfer(ctx context.Context, transfer *transfer.Tr
 			defer func() { <-sem }() // release the slot
 
 			targetPath := filepath.Join(d.downloadDir, file.Path)
-			if err := d.DownloadFile(ctx, transfer.ID, file, targetPath); err != nil {
+			if err := d.DownloadFile(ctx, transfer, file, targetPath); err != nil {
 				return d.classifyFileError(ctx, logger, transfer, file, err)
 			}
 
@@ -172,8 +451,8 @@ func (d *Downloader) DownloadTransfer(ctx context.Context, transfer *transfer.Tr
 	return int(downloadedFiles), nil
 }
 
-func (d *Downloader) DownloadFile(ctx context.Context, transferID string, file *transfer.File, targetPath string) error {
-	logger := logctx.LoggerFromContext(ctx).With("transfer_id", transferID)
+func (d *Downloader) DownloadFile(ctx context.Context, t *transfer.Transfer, file *transfer.File, targetPath string) error {
+	logger := logctx.LoggerFromContext(ctx).With("transfer_id", t.ID)
 
 	fileReader, err := d.dc.GrabFile(ctx, file)
 	if err != nil {
@@ -196,7 +475,7 @@ func (d *Downloader) DownloadFile(ctx context.Context, transferID string, file *
 	// forever -- taking the errgroup, the whole transfer, and every subsequent
 	// transfer down with it. The error group already carries this up to the
 	// transfer level, which is the only level anything acts on.
-	written, err := d.writeFile(ctx, out, fileReader, file.Path, targetPath, file.Size)
+	written, err := d.writeFile(ctx, t, out, fileReader, file.Path, targetPath, file.Size)
 	if err != nil {
 		out.Close()
 
@@ -242,7 +521,10 @@ func (d *Downloader) WatchForImported(ctx context.Context, t *transfer.Transfer,
 
 	logger.InfoContext(ctx, "watching for imported transfers", "transfer_id", t.ID, "polling_interval", pollingInterval)
 
+	d.activity.startWatch(t, WatchImport)
+
 	go func() {
+		defer d.activity.stopWatch(t, WatchImport)
 		defer func() {
 			if r := recover(); r != nil {
 				logger.ErrorContext(ctx, "watch imported panic",
@@ -253,6 +535,53 @@ func (d *Downloader) WatchForImported(ctx context.Context, t *transfer.Transfer,
 			}
 		}()
 
+		var (
+			deadline <-chan time.Time
+			timer    *time.Timer
+		)
+
+		if d.importTimeout > 0 {
+			timer = time.NewTimer(d.importTimeout)
+			defer timer.Stop()
+
+			deadline = timer.C
+		}
+
+		progress := d.newImportProgress(t)
+
+		// A season pack imported episode by episode is not stuck, so the timeout
+		// runs from the last file imported rather than from the download.
+		lastProgress := d.reports.get(t.ID).ImportProgressAt
+		check := func() bool {
+			done := d.checkImportOnce(ctx, t, progress)
+
+			if at := d.reports.get(t.ID).ImportProgressAt; at.After(lastProgress) {
+				lastProgress = at
+
+				if timer != nil {
+					timer.Reset(d.importTimeout)
+				}
+			}
+
+			return done
+		}
+
+		if d.pushImport {
+			d.pushImports(ctx, t, func(result ImportScanResult) bool {
+				select {
+				case d.OnImportScanFinished <- result:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+
+			// The scans have had their go, so look now rather than a polling interval on.
+			if check() {
+				return
+			}
+		}
+
 		ticker := time.NewTicker(pollingInterval)
 		defer ticker.Stop()
 
@@ -266,38 +595,244 @@ func (d *Downloader) WatchForImported(ctx context.Context, t *transfer.Transfer,
 
 				return
 			case <-ticker.C:
-				imported, err := d.checkForImported(ctx, t)
-				if err != nil {
-					logger.ErrorContext(ctx, "failed to check for imported transfer", "transfer_id", t.ID, "err", err)
-
-					continue
-				}
-
-				if imported {
-					logger.InfoContext(ctx, "transfer imported, stopping watch",
-						"operation", "watch_imported",
-						"transfer_id", t.ID,
-						"reason", "transfer_imported")
-					d.OnTransferImported <- t
-
+				if check() {
 					return
 				}
+			case <-deadline:
+				d.reportImportFailed(ctx, ImportFailedEvent{Transfer: t, Reason: "timeout"})
+
+				return
 			}
 		}
 	}()
 }
 
+// checkImportOnce checks where t stands with the *arr apps and reports it if
+// they are done with it, one way or the other. It returns whether they are.
+func (d *Downloader) checkImportOnce(ctx context.Context, t *transfer.Transfer, progress *importProgress) bool {
+	logger := logctx.LoggerFromContext(ctx)
+
+	imported, failed, err := d.checkImport(ctx, t, progress)
+
+	switch {
+	case err != nil:
+		logger.ErrorContext(ctx, "failed to check for imported transfer", "transfer_id", t.ID, "err", err)
+
+		return false
+	case imported:
+		select {
+		case d.OnTransferImported <- t:
+		case <-ctx.Done():
+		}
+
+		return true
+	case failed != nil:
+		d.sendImportFailed(ctx, *failed)
+
+		return true
+	default:
+		return false
+	}
+}
+
+// CheckImport asks the *arr apps once where t stands, for runs that do not stay
+// to watch it, and reports an import or a refusal as a watch does. An imported
+// transfer's local files are removed. With WithImportTimeout, a transfer still
+// pending that long after since -- its download, or the last time more of its
+// files were found imported -- is reported as having timed out; one of whose
+// files this check finds more imported is not. It returns whether t is
+// imported, or why it will not be; neither means pending.
+func (d *Downloader) CheckImport(
+	ctx context.Context, t *transfer.Transfer, since time.Time,
+) (bool, *ImportFailedEvent, error) {
+	imported, failed, err := d.checkImport(ctx, t, d.newImportProgress(t))
+	if err != nil || imported || failed != nil {
+		return imported, failed, err
+	}
+
+	if at := d.reports.get(t.ID).ImportProgressAt; at.After(since) {
+		since = at
+	}
+
+	if d.importTimeout > 0 && !since.IsZero() && time.Since(since) > d.importTimeout {
+		event := ImportFailedEvent{Transfer: t, Reason: "timeout"}
+		d.importFailed(ctx, event)
+
+		return false, &event, nil
+	}
+
+	return false, nil, nil
+}
+
+// checkImport checks where t stands with the *arr apps, and tells of it on the
+// bus if they are done with it.
+func (d *Downloader) checkImport(
+	ctx context.Context, t *transfer.Transfer, progress *importProgress,
+) (bool, *ImportFailedEvent, error) {
+	logger := logctx.LoggerFromContext(ctx)
+
+	status, instance, err := d.checkForImported(ctx, t, progress)
+	if err != nil {
+		return false, nil, err
+	}
+
+	switch status.State {
+	case arr.Imported:
+		d.reports.update(t.ID, func(r *Report) { r.ImportedBy = instance })
+		logger.InfoContext(ctx, "transfer imported, stopping watch",
+			"operation", "watch_imported",
+			"transfer_id", t.ID,
+			"reason", "transfer_imported")
+		d.events.Publish(events.TransferImported, events.Transfer{ID: t.ID, Name: t.Name})
+
+		return true, nil, nil
+	case arr.ImportFailed, arr.ImportIgnored:
+		event := ImportFailedEvent{
+			Transfer: t,
+			Instance: instance,
+			Reason:   "download_" + string(status.State),
+			Message:  status.Message,
+		}
+		d.importFailed(ctx, event)
+
+		return false, &event, nil
+	default:
+		return false, nil, nil
+	}
+}
+
+func (d *Downloader) reportImportFailed(ctx context.Context, event ImportFailedEvent) {
+	d.importFailed(ctx, event)
+	d.sendImportFailed(ctx, event)
+}
+
+// importFailed logs a transfer that will not be imported and tells of it on the
+// bus.
+func (d *Downloader) importFailed(ctx context.Context, event ImportFailedEvent) {
+	logger := logctx.LoggerFromContext(ctx)
+
+	logger.WarnContext(ctx, "transfer will not be imported, stopping watch",
+		"operation", "watch_imported",
+		"transfer_id", event.Transfer.ID,
+		"instance", event.Instance,
+		"reason", event.Reason,
+		"message", event.Message)
+	d.events.Publish(events.TransferImportFailed, events.Transfer{
+		ID: event.Transfer.ID, Name: event.Transfer.Name, Reason: event.Reason, Error: event.Message,
+	})
+}
+
+func (d *Downloader) sendImportFailed(ctx context.Context, event ImportFailedEvent) {
+	select {
+	case d.OnTransferImportFailed <- event:
+	case <-ctx.Done():
+	}
+}
+
+// RemoveLocal deletes the transfer's Local Layout.
+func (d *Downloader) RemoveLocal(ctx context.Context, t *transfer.Transfer) {
+	d.removeLocalOutput(ctx, logctx.LoggerFromContext(ctx), t)
+}
+
+// PushImports asks the *arr apps to import t straight away, as WatchForImported
+// does with WithPushImport, and returns how each import went. Without
+// WithPushImport it asks nothing.
+func (d *Downloader) PushImports(ctx context.Context, t *transfer.Transfer) []ImportScanResult {
+	if !d.pushImport {
+		return nil
+	}
+
+	var results []ImportScanResult
+
+	d.pushImports(ctx, t, func(result ImportScanResult) bool {
+		results = append(results, result)
+
+		return true
+	})
+
+	return results
+}
+
+// pushImports asks every *arr app that knows its scan command to import t from
+// its Local Layout, and follows each command to the end. Apps are asked in turn:
+// each imports what is its own and leaves the rest. Each result is passed to
+// report, which returns false to stop.
+func (d *Downloader) pushImports(ctx context.Context, t *transfer.Transfer, report func(ImportScanResult) bool) {
+	logger := logctx.LoggerFromContext(ctx)
+
+	name, derived := t.LocalName()
+	if !derived {
+		logger.WarnContext(ctx, "local name not derived from file paths, falling back to transfer name",
+			"operation", "push_import", "transfer_id", t.ID, "name", name)
+	}
+
+	path := filepath.Join(d.downloadDir, name)
+
+	for _, arrService := range d.arrServicesFor(ctx, t) {
+		if arrService.App() == "" {
+			continue
+		}
+
+		if d.dryRun {
+			logger.InfoContext(ctx, "dry run: would ask for an import",
+				"operation", "push_import", "transfer_id", t.ID, "instance", arrService.Name(), "path", path)
+
+			continue
+		}
+
+		result := ImportScanResult{Transfer: t, Instance: arrService.Name()}
+		result.Command, result.Err = d.runImportScan(ctx, arrService, t, path)
+
+		logger.InfoContext(ctx, "pushed import finished",
+			"operation", "push_import",
+			"transfer_id", t.ID,
+			"instance", result.Instance,
+			"command_id", result.Command.ID,
+			"outcome", result.Outcome())
+
+		if !report(result) {
+			return
+		}
+	}
+}
+
+func (d *Downloader) runImportScan(ctx context.Context, arrService *arr.Client, t *transfer.Transfer, path string) (arr.Command, error) {
+	command, err := arrService.ScanDownload(ctx, t.HashString(), path)
+	if err != nil {
+		return arr.Command{}, fmt.Errorf("failed to queue import: %w", err)
+	}
+
+	if command.Finished() {
+		return command, nil
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, d.pushTimeout)
+	defer cancel()
+
+	finished, err := arrService.WaitForCommand(waitCtx, command.ID, commandPollInterval)
+	if err != nil {
+		return command, fmt.Errorf("failed to follow import: %w", err)
+	}
+
+	return finished, nil
+}
+
 // CleanupTransfer removes the Put.io transfer and its file data with exponential backoff retry.
 // If the transfer is not found on Put.io (already deleted), this is treated as success.
 // Cleanup failures after retries are logged but do not crash or stall the pipeline.
 func (d *Downloader) CleanupTransfer(ctx context.Context, t *transfer.Transfer) {
 	logger := logctx.LoggerFromContext(ctx)
 
-	hash := sha1.Sum([]byte(t.ID))
-	hashStr := hex.EncodeToString(hash[:])
+	if d.dryRun {
+		logger.InfoContext(ctx, "dry run: would remove the Put.io transfer and its files",
+			"transfer_id", t.ID, "transfer_name", t.Name, "size", humanize.Bytes(uint64(max(t.TotalSize(), 0))))
+		d.reports.forget(t.ID)
+
+		return
+	}
 
 	_, err := backoff.Retry[struct{}](ctx, func() (struct{}, error) {
-		if err := d.tc.RemoveTransfers(ctx, []string{hashStr}, true); err != nil {
+		if err := d.tc.RemoveTransfers(ctx, []string{t.HashString()}, true); err != nil {
 			if strings.Contains(err.Error(), "transfer not found") {
 				logger.InfoContext(ctx, "Put.io transfer already removed, treating as success",
 					"transfer_id", t.ID, "transfer_name", t.Name)
@@ -311,6 +846,8 @@ func (d *Downloader) CleanupTransfer(ctx context.Context, t *transfer.Transfer)
 		return struct{}{}, nil
 	}, backoff.WithMaxTries(3))
 
+	d.reports.forget(t.ID)
+
 	if err != nil {
 		logger.ErrorContext(ctx, "failed to clean up Put.io transfer after retries, continuing",
 			"transfer_id", t.ID, "err", err)
@@ -320,6 +857,8 @@ func (d *Downloader) CleanupTransfer(ctx context.Context, t *transfer.Transfer)
 
 	logger.InfoContext(ctx, "Put.io transfer and files cleaned up",
 		"transfer_id", t.ID, "transfer_name", t.Name)
+
+	d.events.Publish(events.TransferCleanedUp, events.Transfer{ID: t.ID, Name: t.Name})
 }
 
 // WatchForSeeding watches until the Put.io transfer reaches the target seed ratio, then cleans it up.
@@ -329,7 +868,10 @@ func (d *Downloader) WatchForSeeding(ctx context.Context, t *transfer.Transfer,
 	logger.InfoContext(ctx, "watching for seeding transfers",
 		"transfer_id", t.ID, "polling_interval", pollingInterval, "seed_ratio", seedRatio)
 
+	d.activity.startWatch(t, WatchSeeding)
+
 	go func() {
+		defer d.activity.stopWatch(t, WatchSeeding)
 		defer func() {
 			if r := recover(); r != nil {
 				logger.ErrorContext(ctx, "watch seeding panic",
@@ -353,83 +895,220 @@ func (d *Downloader) WatchForSeeding(ctx context.Context, t *transfer.Transfer,
 
 				return
 			case <-ticker.C:
-				infoer, ok := d.dc.(transfer.TransferInfoer)
-				if !ok {
-					logger.ErrorContext(ctx, "download client does not support transfer info, cannot watch seeding",
-						"operation", "watch_seeding",
-						"transfer_id", t.ID)
-
+				if d.CheckSeeding(ctx, t, seedRatio) {
 					return
 				}
+			}
+		}
+	}()
+}
 
-				uploadRatio, found, err := infoer.GetTransferInfo(ctx, t.ID)
-				if err != nil {
-					logger.ErrorContext(ctx, "failed to get transfer info, retrying next tick",
-						"operation", "watch_seeding",
-						"transfer_id", t.ID,
-						"err", err)
+// CheckSeeding checks once whether the Put.io transfer has reached seedRatio,
+// and cleans it up if so. It returns whether there is nothing left to wait for:
+// the transfer was cleaned up, is gone already, or cannot be checked.
+func (d *Downloader) CheckSeeding(ctx context.Context, t *transfer.Transfer, seedRatio float64) bool {
+	logger := logctx.LoggerFromContext(ctx)
 
-					continue
-				}
+	infoer, ok := d.dc.(transfer.TransferInfoer)
+	if !ok {
+		logger.ErrorContext(ctx, "download client does not support transfer info, cannot watch seeding",
+			"operation", "watch_seeding",
+			"transfer_id", t.ID)
 
-				if !found {
-					logger.InfoContext(ctx, "transfer no longer exists on Put.io, cleanup already done",
-						"operation", "watch_seeding",
-						"transfer_id", t.ID)
+		return true
+	}
 
-					return
-				}
+	uploadRatio, found, err := infoer.GetTransferInfo(ctx, t.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get transfer info, retrying next tick",
+			"operation", "watch_seeding",
+			"transfer_id", t.ID,
+			"err", err)
 
-				if uploadRatio >= seedRatio {
-					logger.InfoContext(ctx, "seed ratio reached, cleaning up transfer",
-						"operation", "watch_seeding",
-						"transfer_id", t.ID,
-						"upload_ratio", uploadRatio,
-						"target_ratio", seedRatio)
+		return false
+	}
 
-					d.CleanupTransfer(ctx, t)
+	if !found {
+		logger.InfoContext(ctx, "transfer no longer exists on Put.io, cleanup already done",
+			"operation", "watch_seeding",
+			"transfer_id", t.ID)
 
-					return
-				}
+		return true
+	}
 
-				logger.DebugContext(ctx, "seed ratio not yet reached",
-					"operation", "watch_seeding",
-					"transfer_id", t.ID,
-					"upload_ratio", uploadRatio,
-					"target_ratio", seedRatio)
-			}
-		}
-	}()
+	if uploadRatio >= seedRatio {
+		logger.InfoContext(ctx, "seed ratio reached, cleaning up transfer",
+			"operation", "watch_seeding",
+			"transfer_id", t.ID,
+			"upload_ratio", uploadRatio,
+			"target_ratio", seedRatio)
+
+		d.events.Publish(events.TransferSeedingComplete, events.Transfer{ID: t.ID, Name: t.Name, Ratio: uploadRatio})
+		d.CleanupTransfer(ctx, t)
+
+		return true
+	}
+
+	logger.DebugContext(ctx, "seed ratio not yet reached",
+		"operation", "watch_seeding",
+		"transfer_id", t.ID,
+		"upload_ratio", uploadRatio,
+		"target_ratio", seedRatio)
+
+	return false
 }
 
-func (d *Downloader) checkForImported(ctx context.Context, transfer *transfer.Transfer) (bool, error) {
+// checkForImported asks the *arr apps where t stands and removes each media file
+// as it is imported. It reports t imported once every media file is, and then
+// removes the whole Local Layout, ignored files and all. A refusal from one app
+// is only reported when nothing of t has been imported, along with the instance
+// that refused.
+func (d *Downloader) checkForImported(
+	ctx context.Context, transfer *transfer.Transfer, progress *importProgress,
+) (arr.ImportStatus, string, error) {
 	logger := logctx.LoggerFromContext(ctx)
 	logger.DebugContext(ctx, "checking if transfer has been imported", "transfer_id", transfer.ID, "transfer_name", transfer.Name)
 
+	paths := make([]string, 0, len(transfer.Files))
 	for _, file := range transfer.Files {
-		for _, arrService := range d.arrServices {
-			filePath := filepath.Join(d.downloadDir, file.Path)
+		paths = append(paths, filepath.Join(d.downloadDir, file.Path))
+	}
+
+	result, refusedBy := arr.ImportStatus{State: arr.ImportPending}, ""
+	imported, importedBy := false, ""
 
-			imported, err := arrService.CheckImported(ctx, filePath)
-			if err != nil {
-				return false, fmt.Errorf("failed to check if transfer has been imported: %w", err)
+	for _, arrService := range d.arrServicesFor(ctx, transfer) {
+		status, err := arrService.CheckImport(ctx, transfer.HashString(), paths)
+		if err != nil {
+			return arr.ImportStatus{}, "", fmt.Errorf("failed to check if transfer has been imported: %w", err)
+		}
+
+		if status.State != arr.Imported {
+			if status.State != arr.ImportPending && refusedBy == "" {
+				result, refusedBy = status, arrService.Name()
 			}
 
-			if imported {
-				logger.InfoContext(ctx, "transfer has been imported", "transfer_id", transfer.ID, "transfer_name", transfer.Name)
+			continue
+		}
 
-				if err := os.RemoveAll(filePath); err != nil {
-					return false, fmt.Errorf("failed to remove file: %w", err)
-				}
+		imported, importedBy = true, arrService.Name()
 
-				logger.InfoContext(ctx, "transfer removed", "transfer_id", transfer.ID, "transfer_name", transfer.Name)
+		// Without paths to go on, or with none of them ours -- the app imported
+		// from an archive it unpacked, say -- there is no telling which files
+		// went. Only a transfer of one media file is certainly imported whole; a
+		// season pack waits until the paths say which of its episodes have.
+		files := status.Paths
+		if !progress.knows(files) {
+			if !progress.single() {
+				logger.InfoContext(ctx, "import reported without paths to the transfer's files, waiting for them",
+					"transfer_id", transfer.ID, "transfer_name", transfer.Name, "instance", arrService.Name(), "paths", files)
 
-				return true, nil
+				continue
 			}
+
+			files = paths
 		}
+
+		removed, err := d.removeImported(ctx, transfer, progress.take(files))
+		if err != nil {
+			return arr.ImportStatus{}, "", err
+		}
+
+		if removed > 0 {
+			d.reports.update(transfer.ID, func(r *Report) { r.ImportProgressAt = time.Now() })
+		}
+	}
+
+	if !imported {
+		return result, refusedBy, nil
+	}
+
+	if !progress.done() {
+		logger.InfoContext(ctx, "transfer partly imported, waiting for the rest",
+			"transfer_id", transfer.ID, "transfer_name", transfer.Name, "remaining_files", len(progress.remaining))
+
+		return arr.ImportStatus{State: arr.ImportPending}, "", nil
+	}
+
+	logger.InfoContext(ctx, "transfer has been imported", "transfer_id", transfer.ID, "transfer_name", transfer.Name)
+
+	if name, derived := transfer.LocalName(); derived {
+		paths = []string{filepath.Join(d.downloadDir, name)}
 	}
 
-	return false, nil
+	for _, path := range paths {
+		if err := d.remove(ctx, transfer, path, os.RemoveAll); err != nil {
+			return arr.ImportStatus{}, "", fmt.Errorf("failed to remove file: %w", err)
+		}
+	}
+
+	logger.InfoContext(ctx, "transfer removed", "transfer_id", transfer.ID, "transfer_name", transfer.Name)
+
+	return arr.ImportStatus{State: arr.Imported}, importedBy, nil
+}
+
+// removeImported deletes files an app has imported, leaving the rest of the
+// transfer where the apps expect it. It returns how many were still there to
+// delete: those gone already were imported, and deleted, by an earlier check.
+func (d *Downloader) removeImported(ctx context.Context, t *transfer.Transfer, paths []string) (int, error) {
+	logger := logctx.LoggerFromContext(ctx)
+
+	removed := 0
+
+	for _, path := range paths {
+		err := d.remove(ctx, t, path, os.Remove)
+
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			continue
+		case err != nil:
+			return removed, fmt.Errorf("failed to remove imported file: %w", err)
+		}
+
+		removed++
+
+		logger.DebugContext(ctx, "imported file removed", "transfer_id", t.ID, "path", path)
+	}
+
+	return removed, nil
+}
+
+// arrServicesFor returns the *arr instances that may import t: the one that added
+// it when that is known and still configured, otherwise all of them.
+func (d *Downloader) arrServicesFor(ctx context.Context, t *transfer.Transfer) []*arr.Client {
+	arrServices := d.arrClients()
+
+	if d.owners == nil {
+		return arrServices
+	}
+
+	logger := logctx.LoggerFromContext(ctx)
+
+	owner, err := d.owners.TransferOwner(t.ID)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to look up transfer owner, asking every *arr instance", "transfer_id", t.ID, "err", err)
+
+		return arrServices
+	}
+
+	if owner == "" {
+		return arrServices
+	}
+
+	for _, arrService := range arrServices {
+		if arrService.Name() == owner {
+			return []*arr.Client{arrService}
+		}
+	}
+
+	logger.WarnContext(ctx, "transfer owner is not a configured *arr instance, asking every instance",
+		"transfer_id", t.ID, "owner", owner)
+
+	return arrServices
+}
+
+func (d *Downloader) publishMissing(t *transfer.Transfer, missingType string) {
+	d.events.Publish(events.TransferMissing, events.Transfer{ID: t.ID, Name: t.Name, MissingType: missingType})
 }
 
 // classifyFileError turns a per-file failure into the error the transfer level
@@ -472,12 +1151,62 @@ func (d *Downloader) removeLocalOutput(ctx context.Context, logger *slog.Logger,
 		return
 	}
 
-	if err := os.RemoveAll(filepath.Join(d.downloadDir, name)); err != nil {
-		logger.WarnContext(ctx, "failed to remove partial transfer output",
+	if err := d.remove(ctx, t, filepath.Join(d.downloadDir, name), os.RemoveAll); err != nil {
+		logger.WarnContext(ctx, "failed to remove transfer output",
 			"transfer_id", t.ID, "local_name", name, "err", err)
 	}
 }
 
+// remove deletes path with removeFunc, os.Remove or os.RemoveAll, or in a dry
+// run logs that it would.
+func (d *Downloader) remove(ctx context.Context, t *transfer.Transfer, path string, removeFunc func(string) error) error {
+	if d.dryRun {
+		logctx.LoggerFromContext(ctx).InfoContext(ctx, "dry run: would delete", "transfer_id", t.ID, "path", path)
+
+		return nil
+	}
+
+	return removeFunc(path)
+}
+
+// logDryRunDownload logs where each of t's files would be written, and how
+// large it is, in place of downloading them.
+func (d *Downloader) logDryRunDownload(ctx context.Context, logger *slog.Logger, t *transfer.Transfer) {
+	name, derived := t.LocalName()
+
+	logger.InfoContext(ctx, "dry run: would download transfer",
+		"transfer_id", t.ID,
+		"transfer_name", t.Name,
+		"local_path", filepath.Join(d.downloadDir, name),
+		"local_name_derived", derived,
+		"file_count", len(t.Files),
+		"size", humanize.Bytes(uint64(max(t.TotalSize(), 0))))
+
+	for _, file := range t.Files {
+		logger.InfoContext(ctx, "dry run: would write file",
+			"transfer_id", t.ID,
+			"path", filepath.Join(d.downloadDir, file.Path),
+			"size", humanize.Bytes(uint64(max(file.Size, 0))))
+	}
+}
+
+// progressedReader calls progressed on every read that returns data, which is
+// what tells a slow download from a stuck one.
+type progressedReader struct {
+	io.Reader
+
+	progressed func()
+}
+
+func (r progressedReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.progressed()
+	}
+
+	return n, err
+}
+
 func (d *Downloader) ensureTargetDir(ctx context.Context, targetPath string, logger *slog.Logger) error {
 	dir := filepath.Dir(targetPath)
 	if err := os.MkdirAll(dir, dirPerm); err != nil {
@@ -491,13 +1220,19 @@ func (d *Downloader) ensureTargetDir(ctx context.Context, targetPath string, log
 
 // writeFile copies reader into out, reporting how many bytes were written so the
 // caller can check that against the size the seedbox promised.
-func (d *Downloader) writeFile(ctx context.Context, out *os.File, reader io.Reader, url, targetPath string, totalBytes int64) (int64, error) {
+func (d *Downloader) writeFile(
+	ctx context.Context, t *transfer.Transfer, out *os.File, reader io.Reader, url, targetPath string, totalBytes int64,
+) (int64, error) {
 	logger := logctx.LoggerFromContext(ctx)
 
 	logger.DebugContext(ctx, "downloading file", "file_path", targetPath, "file_size", humanize.Bytes(uint64(totalBytes)))
 
 	progressInterval := int64(100 * 1024 * 1024) // 100MB
 	progressCb := func(written int64, total int64) {
+		d.events.Publish(events.FileProgress, events.Progress{
+			TransferID: t.ID, TransferName: t.Name, Path: url, Written: written, Total: total,
+		})
+
 		if total > 0 {
 			logger.DebugContext(ctx, "download progress",
 				"url", url,
@@ -510,7 +1245,10 @@ func (d *Downloader) writeFile(ctx context.Context, out *os.File, reader io.Read
 	}
 	pr := progress.NewReader(reader, totalBytes, progressInterval, progressCb)
 
-	written, err := io.Copy(out, pr)
+	active := d.activity.startFile(t, url, pr)
+	defer d.activity.finishFile(active)
+
+	written, err := io.Copy(out, progressedReader{pr, d.progressed})
 	if err != nil {
 		return written, fmt.Errorf("failed to copy file: %w", err)
 	}
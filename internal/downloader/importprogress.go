@@ -0,0 +1,112 @@
+package downloader
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/italolelis/seedbox_downloader/internal/transfer"
+)
+
+// DefaultImportIgnore are the files an import is not expected to take: release
+// notes, text files and samples. They stay on disk until the rest is imported
+// and the Local Layout goes with them.
+var DefaultImportIgnore = []string{"*.nfo", "*.txt", "*sample*"}
+
+// importProgress follows which of a transfer's media files the *arr apps have
+// imported. A season pack is imported episode by episode, over as long as it
+// takes the app to match each one, and only what has been imported may go.
+type importProgress struct {
+	// files maps the local path of every file in the transfer to its path within
+	// the transfer.
+	files map[string]string
+	// remaining are the local paths of the media files not yet imported.
+	remaining map[string]bool
+	// media is how many media files the transfer has.
+	media int
+}
+
+func (d *Downloader) newImportProgress(t *transfer.Transfer) *importProgress {
+	p := &importProgress{
+		files:     make(map[string]string, len(t.Files)),
+		remaining: make(map[string]bool, len(t.Files)),
+	}
+
+	for _, file := range t.Files {
+		local := filepath.Join(d.downloadDir, file.Path)
+		p.files[local] = filepath.ToSlash(file.Path)
+
+		if !d.ignoredForImport(file.Path) {
+			p.remaining[local] = true
+			p.media++
+		}
+	}
+
+	return p
+}
+
+// ignoredForImport reports whether a file, by its base name, is one the *arr apps
+// are not expected to import. Patterns are matched without regard to case.
+func (d *Downloader) ignoredForImport(filePath string) bool {
+	base := strings.ToLower(filepath.Base(filePath))
+
+	for _, pattern := range d.importIgnore {
+		if ok, _ := path.Match(strings.ToLower(pattern), base); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// take marks the media files among imported as imported and returns the local
+// paths of those not seen before.
+func (p *importProgress) take(imported []string) []string {
+	var taken []string
+
+	for local := range p.remaining {
+		if p.matches(local, imported) {
+			taken = append(taken, local)
+			delete(p.remaining, local)
+		}
+	}
+
+	return taken
+}
+
+// knows reports whether any of paths is one of the transfer's files, media or
+// not, imported or not.
+func (p *importProgress) knows(paths []string) bool {
+	for local := range p.files {
+		if p.matches(local, paths) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches reports whether any of paths is the file at local. An app that mounts
+// the download volume elsewhere without a path mapping reports paths under its
+// own root, so a path also matches the file its end names.
+func (p *importProgress) matches(local string, paths []string) bool {
+	for _, got := range paths {
+		got = filepath.ToSlash(got)
+		if got == filepath.ToSlash(local) || strings.HasSuffix(got, "/"+p.files[local]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// single reports whether the transfer has one media file, which an import
+// can only be of, whatever paths the app gives.
+func (p *importProgress) single() bool {
+	return p.media == 1
+}
+
+// done reports whether every media file has been imported.
+func (p *importProgress) done() bool {
+	return len(p.remaining) == 0
+}
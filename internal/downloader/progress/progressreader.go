@@ -1,15 +1,18 @@
 package progress
 
-import "io"
+import (
+	"io"
+	"sync/atomic"
+)
 
 // Reader wraps an io.Reader and reports progress via a callback.
 type Reader struct {
 	Reader         io.Reader
 	Total          int64
 	OnProgress     func(written int64, total int64)
-	totalRead      int64 // cumulative total
-	lastReport     int64 // bytes since last report
-	reportInterval int64 // bytes
+	totalRead      atomic.Int64 // cumulative total; read concurrently by Written
+	lastReport     int64        // bytes since last report
+	reportInterval int64        // bytes
 }
 
 func NewReader(r io.Reader, total int64, interval int64, cb func(written int64, total int64)) *Reader {
@@ -21,14 +24,21 @@ func NewReader(r io.Reader, total int64, interval int64, cb func(written int64,
 	}
 }
 
+// Written returns how many bytes have been read so far. Unlike OnProgress, which
+// fires at coarse intervals, it is current to the last Read and safe to call from
+// another goroutine.
+func (pr *Reader) Written() int64 {
+	return pr.totalRead.Load()
+}
+
 func (pr *Reader) Read(p []byte) (int, error) {
 	n, err := pr.Reader.Read(p)
 	if n > 0 {
-		pr.totalRead += int64(n)
+		totalRead := pr.totalRead.Add(int64(n))
 		pr.lastReport += int64(n)
 
-		if pr.lastReport >= pr.reportInterval || (pr.Total > 0 && pr.totalRead*100/pr.Total >= 5 && (pr.totalRead-int64(n))*100/pr.Total < 5) {
-			pr.OnProgress(pr.totalRead, pr.Total)
+		if pr.lastReport >= pr.reportInterval || (pr.Total > 0 && totalRead*100/pr.Total >= 5 && (totalRead-int64(n))*100/pr.Total < 5) {
+			pr.OnProgress(totalRead, pr.Total)
 			pr.lastReport = 0
 		}
 	}
@@ -0,0 +1,78 @@
+package downloader
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/transfer"
+)
+
+// Report is what the downloader has learned about a transfer along the way, for
+// those telling others about it.
+type Report struct {
+	// Duration is how long the last download of the transfer took.
+	Duration time.Duration
+	// Err is why the last download failed.
+	Err error
+	// ImportedBy is the *arr instance that imported the transfer.
+	ImportedBy string
+	// ImportProgressAt is when more of the transfer's files were last found
+	// imported, while the rest were still to go.
+	ImportProgressAt time.Time
+}
+
+// reports holds a Report per transfer id.
+type reports struct {
+	mu      sync.Mutex
+	reports map[string]Report
+}
+
+func (r *reports) update(transferID string, update func(*Report)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.reports == nil {
+		r.reports = make(map[string]Report)
+	}
+
+	report := r.reports[transferID]
+	update(&report)
+	r.reports[transferID] = report
+}
+
+func (r *reports) get(transferID string) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.reports[transferID]
+}
+
+func (r *reports) forget(transferID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.reports, transferID)
+}
+
+// Report returns what is known about the transfer. It is kept until the
+// transfer is cleaned up, or ForgetReport is called.
+func (d *Downloader) Report(transferID string) Report {
+	return d.reports.get(transferID)
+}
+
+// ForgetReport drops what is known about a transfer that has reached an end
+// other than cleanup -- its download or its import failed, or it went missing --
+// once whatever tells of that end has read its report.
+func (d *Downloader) ForgetReport(transferID string) {
+	d.reports.forget(transferID)
+}
+
+// LocalPath is where the transfer's Local Layout is beneath the download
+// directory: its folder or only file, or a path named after the transfer when
+// its files share neither.
+func (d *Downloader) LocalPath(t *transfer.Transfer) string {
+	name, _ := t.LocalName()
+
+	return filepath.Join(d.downloadDir, name)
+}
@@ -0,0 +1,167 @@
+// Package events is an in-process fan-out bus for pipeline events. Publishers
+// never block on subscribers: a subscriber that falls behind is dropped, and is
+// expected to reconnect and replay from the last event it saw.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	TransferClaimed         = "transfer.claimed"
+	TransferDownloadFailed  = "transfer.download_failed"
+	TransferDownloaded      = "transfer.downloaded"
+	TransferVerified        = "transfer.verified"
+	TransferImported        = "transfer.imported"
+	TransferImportFailed    = "transfer.import_failed"
+	TransferSeedingComplete = "transfer.seeding_complete"
+	TransferCleanedUp       = "transfer.cleaned_up"
+	TransferMissing         = "transfer.missing"
+	FileProgress            = "file.progress"
+)
+
+const (
+	// DefaultHistory is how many events a bus keeps for replay.
+	DefaultHistory = 1024
+
+	// subscriberBuffer is how far a subscriber may fall behind before it is
+	// dropped. Progress events arrive in bursts, so this is not tiny.
+	subscriberBuffer = 256
+)
+
+// Event is one thing that happened. IDs increase by one per event and are only
+// meaningful within a single process: they restart at 1 on every restart.
+type Event struct {
+	ID   uint64    `json:"id"`
+	Type string    `json:"type"`
+	At   time.Time `json:"at"`
+	Data any       `json:"data"`
+}
+
+// Transfer is the payload of every transfer.* event.
+type Transfer struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Label, Size and Files are set on transfer.claimed.
+	Label string `json:"label,omitempty"`
+	Size  int64  `json:"size,omitempty"`
+	Files int    `json:"files,omitempty"`
+	// Error is set on transfer.download_failed.
+	Error string `json:"error,omitempty"`
+	// MissingType is set on transfer.missing: "files_missing" or "transfer_removed".
+	MissingType string `json:"missing_type,omitempty"`
+	// Reason is set on transfer.import_failed: "download_failed",
+	// "download_ignored" or "timeout". Error then carries the app's message.
+	Reason string `json:"reason,omitempty"`
+	// Ratio is set on transfer.seeding_complete: the upload ratio reached.
+	Ratio float64 `json:"ratio,omitempty"`
+}
+
+// Progress is the payload of file.progress.
+type Progress struct {
+	TransferID   string `json:"transfer_id"`
+	TransferName string `json:"transfer_name"`
+	Path         string `json:"path"`
+	Written      int64  `json:"written"`
+	Total        int64  `json:"total"`
+}
+
+// Bus fans events out to every subscriber and keeps the most recent ones so a
+// reconnecting subscriber can resume where it left off.
+type Bus struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []Event
+	limit   int
+	subs    map[chan Event]struct{}
+	now     func() time.Time
+}
+
+// NewBus creates a bus that remembers the last history events for replay.
+func NewBus(history int) *Bus {
+	return &Bus{
+		nextID: 1,
+		limit:  history,
+		subs:   map[chan Event]struct{}{},
+		now:    time.Now,
+	}
+}
+
+// Publish sends an event to every subscriber without waiting for any of them.
+// Publishing to a nil *Bus discards the event, so a component built without a
+// bus needs no checks at its call sites.
+func (b *Bus) Publish(eventType string, data any) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := Event{ID: b.nextID, Type: eventType, At: b.now().UTC(), Data: data}
+	b.nextID++
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.limit {
+		b.history = b.history[len(b.history)-b.limit:]
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+			// Too far behind. Closing tells the subscriber so; it reconnects with
+			// the last ID it saw and the gap is replayed from history.
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// LastID is the ID of the last event published, or 0 before the first. A
+// subscriber that has read up to it is caught up.
+func (b *Bus) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.nextID - 1
+}
+
+// Subscribe returns a channel of events published after lastID, starting with
+// any still held in history. Pass 0 to receive only new events. The channel is
+// closed if the subscriber falls behind; cancel must be called when done.
+func (b *Bus) Subscribe(lastID uint64) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+
+	if lastID > 0 {
+		for _, e := range b.history {
+			if e.ID > lastID {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	ch := make(chan Event, subscriberBuffer+len(replay))
+	for _, e := range replay {
+		ch <- e
+	}
+
+	b.subs[ch] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+
+	return ch, cancel
+}
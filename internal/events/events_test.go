@@ -0,0 +1,134 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+
+	select {
+	case e, ok := <-ch:
+		require.True(t, ok, "the subscription was closed")
+
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event arrived")
+
+		return Event{}
+	}
+}
+
+func TestBus_FansOutToEverySubscriber(t *testing.T) {
+	bus := NewBus(DefaultHistory)
+
+	a, cancelA := bus.Subscribe(0)
+	defer cancelA()
+
+	b, cancelB := bus.Subscribe(0)
+	defer cancelB()
+
+	bus.Publish(TransferDownloaded, Transfer{ID: "1"})
+
+	assert.Equal(t, uint64(1), receive(t, a).ID)
+	assert.Equal(t, uint64(1), receive(t, b).ID)
+}
+
+// Last-Event-ID resumption: a subscriber that reconnects after missing events
+// gets them first, in order, and then carries on with live ones.
+func TestBus_ReplaysEverythingAfterLastID(t *testing.T) {
+	bus := NewBus(DefaultHistory)
+
+	for i := 0; i < 5; i++ {
+		bus.Publish(FileProgress, Progress{Written: int64(i)})
+	}
+
+	ch, cancel := bus.Subscribe(3)
+	defer cancel()
+
+	assert.Equal(t, uint64(4), receive(t, ch).ID)
+	assert.Equal(t, uint64(5), receive(t, ch).ID)
+
+	bus.Publish(TransferImported, Transfer{ID: "1"})
+	assert.Equal(t, uint64(6), receive(t, ch).ID)
+}
+
+func TestBus_ZeroLastIDReplaysNothing(t *testing.T) {
+	bus := NewBus(DefaultHistory)
+	bus.Publish(TransferClaimed, Transfer{ID: "1"})
+
+	ch, cancel := bus.Subscribe(0)
+	defer cancel()
+
+	select {
+	case e := <-ch:
+		t.Fatalf("unexpected replay of event %d", e.ID)
+	default:
+	}
+}
+
+func TestBus_LastID(t *testing.T) {
+	bus := NewBus(2)
+	assert.Equal(t, uint64(0), bus.LastID())
+
+	for range 3 {
+		bus.Publish(TransferClaimed, Transfer{ID: "1"})
+	}
+
+	assert.Equal(t, uint64(3), bus.LastID(), "counts events no longer in history")
+}
+
+func TestBus_HistoryIsBounded(t *testing.T) {
+	bus := NewBus(2)
+
+	for i := 0; i < 5; i++ {
+		bus.Publish(FileProgress, nil)
+	}
+
+	ch, cancel := bus.Subscribe(1)
+	defer cancel()
+
+	assert.Equal(t, uint64(4), receive(t, ch).ID, "events older than the history are gone")
+	assert.Equal(t, uint64(5), receive(t, ch).ID)
+}
+
+// A subscriber that stops reading must never stall publishers; it is dropped
+// instead, and finds out because its channel closes.
+func TestBus_SlowSubscriberIsDroppedNotWaitedFor(t *testing.T) {
+	bus := NewBus(DefaultHistory)
+
+	ch, cancel := bus.Subscribe(0)
+	defer cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		for i := 0; i < subscriberBuffer*2; i++ {
+			bus.Publish(FileProgress, nil)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publishing blocked on a subscriber that was not reading")
+	}
+
+	n := 0
+	for range ch {
+		n++
+	}
+
+	assert.Equal(t, subscriberBuffer, n, "the subscription closes once its buffer is full")
+}
+
+func TestBus_NilBusDiscards(t *testing.T) {
+	var bus *Bus
+
+	assert.NotPanics(t, func() { bus.Publish(TransferClaimed, nil) })
+}
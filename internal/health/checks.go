@@ -0,0 +1,77 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// ErrDiskSpaceUnsupported reports that free space cannot be measured on this
+// platform.
+var ErrDiskSpaceUnsupported = errors.New("free space cannot be measured on this platform")
+
+// Heartbeat checks that a loop has run within maxAge. last reports when it last
+// did.
+func Heartbeat(name string, last func() time.Time, maxAge time.Duration) Check {
+	return Check{
+		Name: name,
+		Run: func(context.Context) error {
+			at := last()
+			if at.IsZero() {
+				return errors.New("has not started")
+			}
+
+			if age := time.Since(at); age > maxAge {
+				return fmt.Errorf("last ran %s ago, more than the allowed %s", age.Round(time.Second), maxAge)
+			}
+
+			return nil
+		},
+	}
+}
+
+// Writable checks that a file can be created in dir, which is the first thing
+// every download does.
+func Writable(name, dir string) Check {
+	return Check{
+		Name: name,
+		Run: func(context.Context) error {
+			f, err := os.CreateTemp(dir, ".healthcheck-*")
+			if err != nil {
+				return fmt.Errorf("cannot create a file: %w", err)
+			}
+
+			f.Close()
+
+			if err := os.Remove(f.Name()); err != nil {
+				return fmt.Errorf("cannot remove a file: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+// FreeSpace checks that the filesystem holding dir has at least minBytes free
+// for an unprivileged user.
+func FreeSpace(name, dir string, minBytes uint64) Check {
+	return Check{
+		Name: name,
+		Run: func(context.Context) error {
+			free, err := freeBytes(dir)
+			if err != nil {
+				return err
+			}
+
+			if free < minBytes {
+				return fmt.Errorf("%s free, below the minimum of %s", humanize.Bytes(free), humanize.Bytes(minBytes))
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package health
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+func freeBytes(dir string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, fmt.Errorf("failed to stat filesystem: %w", err)
+	}
+
+	// The conversions look redundant on Linux; on the BSDs the fields are other
+	// integer types.
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
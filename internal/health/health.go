@@ -0,0 +1,206 @@
+// Package health answers the two questions a supervisor asks: is the process
+// alive (liveness), and can it do its job right now (readiness). Each is a set
+// of named checks whose results are reported individually, so a failing probe
+// says which dependency is at fault.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/logctx"
+)
+
+const (
+	// StatusOK and StatusFail are the values of every status in a report.
+	StatusOK   = "ok"
+	StatusFail = "fail"
+
+	// checkTimeout bounds each check, so one hung dependency cannot hold the
+	// probe past the supervisor's own timeout.
+	checkTimeout = 5 * time.Second
+
+	// defaultCacheTTL is how long a readiness report is reused. Probes arrive
+	// every few seconds; the seedbox and the *arr apps need not hear about each.
+	defaultCacheTTL = 30 * time.Second
+
+	redacted = "[redacted]"
+)
+
+// Check is one named condition. Run returns nil when it holds.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report is the body of /healthz and /readyz.
+type Report struct {
+	Status    string            `json:"status"`
+	CheckedAt time.Time         `json:"checked_at"`
+	Checks    map[string]Result `json:"checks"`
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithCacheTTL changes how long a readiness report is reused. Zero runs the
+// checks on every request.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(h *Handler) {
+		h.cacheTTL = ttl
+	}
+}
+
+// WithRedaction replaces every occurrence of secrets in reported errors. The
+// endpoints are unauthenticated, and an error from a client library is free to
+// quote whatever it was given.
+func WithRedaction(secrets ...string) Option {
+	return func(h *Handler) {
+		for _, s := range secrets {
+			if s != "" {
+				h.secrets = append(h.secrets, s)
+			}
+		}
+	}
+}
+
+// Handler serves /healthz and /readyz.
+type Handler struct {
+	liveness  []Check
+	readiness []Check
+	cacheTTL  time.Duration
+	secrets   []string
+	now       func() time.Time
+
+	mu     sync.Mutex
+	cached *Report
+}
+
+// NewHandler creates a handler reporting on the given liveness and readiness
+// checks.
+func NewHandler(liveness, readiness []Check, opts ...Option) *Handler {
+	h := &Handler{
+		liveness:  liveness,
+		readiness: readiness,
+		cacheTTL:  defaultCacheTTL,
+		now:       time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// HandleLiveness reports whether the process's loops are still turning. It
+// runs no I/O: a dependency being down is not a reason to restart the process.
+func (h *Handler) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	h.write(w, r, h.run(r.Context(), h.liveness))
+}
+
+// HandleReadiness reports whether every dependency is usable.
+func (h *Handler) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cached == nil || h.now().Sub(h.cached.CheckedAt) >= h.cacheTTL {
+		report := h.run(r.Context(), h.readiness)
+		h.cached = &report
+	}
+
+	h.write(w, r, *h.cached)
+}
+
+// run performs checks concurrently and collects their results.
+func (h *Handler) run(ctx context.Context, checks []Check) Report {
+	report := Report{Status: StatusOK, CheckedAt: h.now().UTC(), Checks: make(map[string]Result, len(checks))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for _, c := range checks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			result := h.runOne(ctx, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[c.Name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+func (h *Handler) runOne(ctx context.Context, c Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := h.now()
+	err := c.Run(ctx)
+	result := Result{Status: StatusOK, DurationMS: h.now().Sub(start).Milliseconds()}
+
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = h.redact(err.Error())
+	}
+
+	return result
+}
+
+func (h *Handler) redact(s string) string {
+	for _, secret := range h.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+
+	return s
+}
+
+func (h *Handler) write(w http.ResponseWriter, r *http.Request, report Report) {
+	status := http.StatusOK
+
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+
+		failed := make([]string, 0, len(report.Checks))
+		for name, result := range report.Checks {
+			if result.Status != StatusOK {
+				failed = append(failed, name)
+			}
+		}
+
+		sort.Strings(failed)
+
+		logctx.LoggerFromContext(r.Context()).WarnContext(r.Context(), "health check failed",
+			"component", "health", "path", r.URL.Path, "failed_checks", failed)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,128 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func passing(name string) Check {
+	return Check{Name: name, Run: func(context.Context) error { return nil }}
+}
+
+func failing(name string, err error) Check {
+	return Check{Name: name, Run: func(context.Context) error { return err }}
+}
+
+func probe(t *testing.T, handler http.HandlerFunc) (int, Report) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var report Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+
+	return rec.Code, report
+}
+
+func TestReadiness_ReportsEachCheck(t *testing.T) {
+	h := NewHandler(nil, []Check{passing("database"), failing("sonarr", errors.New("connection refused"))})
+
+	code, report := probe(t, h.HandleReadiness)
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, StatusOK, report.Checks["database"].Status)
+	assert.Equal(t, StatusFail, report.Checks["sonarr"].Status)
+	assert.Equal(t, "connection refused", report.Checks["sonarr"].Error)
+}
+
+func TestReadiness_AllPassing(t *testing.T) {
+	h := NewHandler(nil, []Check{passing("database"), passing("download_client")})
+
+	code, report := probe(t, h.HandleReadiness)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Len(t, report.Checks, 2)
+}
+
+// The endpoints are unauthenticated, so a client library that quotes its
+// credentials in an error must not publish them.
+func TestReadiness_RedactsSecrets(t *testing.T) {
+	h := NewHandler(nil,
+		[]Check{failing("download_client", errors.New(`401 for token "s3cret"`))},
+		WithRedaction("s3cret", ""),
+	)
+
+	_, report := probe(t, h.HandleReadiness)
+
+	assert.Equal(t, `401 for token "[redacted]"`, report.Checks["download_client"].Error)
+}
+
+func TestReadiness_ReusesAReportWithinTheTTL(t *testing.T) {
+	calls := 0
+	counting := Check{Name: "download_client", Run: func(context.Context) error {
+		calls++
+
+		return nil
+	}}
+
+	now := time.Now()
+	h := NewHandler(nil, []Check{counting}, WithCacheTTL(time.Minute))
+	h.now = func() time.Time { return now }
+
+	probe(t, h.HandleReadiness)
+	probe(t, h.HandleReadiness)
+	assert.Equal(t, 1, calls)
+
+	now = now.Add(time.Minute)
+
+	probe(t, h.HandleReadiness)
+	assert.Equal(t, 2, calls)
+}
+
+func TestLiveness_Heartbeat(t *testing.T) {
+	for name, tc := range map[string]struct {
+		last time.Time
+		want string
+	}{
+		"recent":      {last: time.Now().Add(-time.Minute), want: StatusOK},
+		"stale":       {last: time.Now().Add(-time.Hour), want: StatusFail},
+		"not started": {want: StatusFail},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := NewHandler([]Check{Heartbeat("orchestrator", func() time.Time { return tc.last }, 20*time.Minute)}, nil)
+
+			_, report := probe(t, h.HandleLiveness)
+
+			assert.Equal(t, tc.want, report.Checks["orchestrator"].Status)
+		})
+	}
+}
+
+func TestWritable(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, Writable("download_dir", dir).Run(context.Background()))
+	require.Error(t, Writable("download_dir", dir+"/missing").Run(context.Background()))
+}
+
+func TestFreeSpace(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := freeBytes(dir); errors.Is(err, ErrDiskSpaceUnsupported) {
+		t.Skip(err)
+	}
+
+	require.NoError(t, FreeSpace("free", dir, 1).Run(context.Background()))
+	require.Error(t, FreeSpace("free", dir, 1<<62).Run(context.Background()))
+}
@@ -0,0 +1,568 @@
+package rest
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/italolelis/seedbox_downloader/internal/downloader"
+	"github.com/italolelis/seedbox_downloader/internal/events"
+	"github.com/italolelis/seedbox_downloader/internal/logctx"
+	"github.com/italolelis/seedbox_downloader/internal/storage"
+	"github.com/italolelis/seedbox_downloader/internal/transfer"
+)
+
+// APIKeyHeader carries the key for the native API. A bearer token in
+// Authorization is accepted as well, for clients that only know that form, and
+// so is basic auth with the key as the password, which is what a browser can
+// be made to send.
+const APIKeyHeader = "X-Api-Key"
+
+// authRealm names the basic-auth realm. The dashboard and the API share it, so a
+// browser that has authenticated for one reuses the credentials for the other.
+const authRealm = "seedbox_downloader"
+
+//go:embed openapi.yaml
+var openAPIDocument []byte
+
+// untrackedStatus is reported for a seedbox transfer that has no ledger row yet:
+// it has not been claimed, either because it is not complete or because no poll
+// has happened since it appeared.
+const untrackedStatus = "untracked"
+
+// TransferStore is the ledger as the API uses it.
+type TransferStore interface {
+	GetDownloads() ([]storage.DownloadRecord, error)
+	storage.TransferAdmin
+}
+
+// Orchestrator is the polling loop as the API controls it.
+type Orchestrator interface {
+	Poll()
+	Pause()
+	Resume()
+	Paused() bool
+}
+
+// TransferLister lists the seedbox transfers filed under a label.
+type TransferLister interface {
+	GetTaggedTorrents(ctx context.Context, label string) ([]*transfer.Transfer, error)
+}
+
+// ActivitySource reports what the downloader is doing right now.
+type ActivitySource interface {
+	Activity() downloader.Activity
+}
+
+// EventSource is the event bus as the API streams it.
+type EventSource interface {
+	Subscribe(lastID uint64) (<-chan events.Event, func())
+}
+
+// Reloader reloads the service's configuration, applying what can change while
+// running. It fails, applying nothing, when the configuration is invalid.
+type Reloader interface {
+	Reload(ctx context.Context) (APIReload, error)
+}
+
+// APITransfer is one transfer as the native API reports it: what the seedbox
+// says about it, joined to where it stands in our pipeline.
+type APITransfer struct {
+	ID string `json:"id"`
+	// Name is the Transfer Name. Cosmetic: see LocalName on the detail view for
+	// where the content was written.
+	Name          string `json:"name,omitempty"`
+	SeedboxStatus string `json:"seedbox_status,omitempty"`
+	OnSeedbox     bool   `json:"on_seedbox"`
+	// PipelineStatus is the ledger status, or "untracked" when there is no row.
+	PipelineStatus string `json:"pipeline_status"`
+	// ClaimedAt is when the transfer was first claimed.
+	ClaimedAt string `json:"claimed_at,omitempty"`
+	LockedBy  string `json:"locked_by,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+}
+
+// APITransferDetail is a single transfer with its files and history.
+type APITransferDetail struct {
+	APITransfer
+
+	LocalName string             `json:"local_name,omitempty"`
+	Files     []APIFile          `json:"files"`
+	History   []APITransferEvent `json:"history"`
+}
+
+// APIFile is one file of a transfer, by its path relative to the Local Root.
+type APIFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// APITransferEvent is one status change.
+type APITransferEvent struct {
+	Status string `json:"status"`
+	At     string `json:"at"`
+}
+
+// APIOrchestratorState reports whether polling is paused.
+type APIOrchestratorState struct {
+	Paused bool `json:"paused"`
+}
+
+// APIReload reports a configuration reload by the settings it changed: those
+// applied, and those refused because they take a restart.
+type APIReload struct {
+	Applied []string `json:"applied"`
+	Refused []string `json:"refused"`
+}
+
+// APIError is the body of every non-2xx response.
+type APIError struct {
+	Error string `json:"error"`
+}
+
+// APIHandler serves the native JSON API under /api/v1. It shares nothing with
+// the Transmission emulation, including its credentials: that surface is shaped
+// by what the *arr apps send, this one by what an operator needs.
+type APIHandler struct {
+	apiKey       string
+	store        TransferStore
+	orchestrator Orchestrator
+	seedbox      TransferLister
+	activity     ActivitySource
+	events       EventSource
+	label        string
+	reloader     Reloader
+	dryRun       bool
+}
+
+// APIOption configures an APIHandler.
+type APIOption func(*APIHandler)
+
+// WithReloader serves POST /config/reload, reloading the configuration through
+// reloader.
+func WithReloader(reloader Reloader) APIOption {
+	return func(h *APIHandler) {
+		h.reloader = reloader
+	}
+}
+
+// WithAPIDryRun refuses the requests that would write the ledger: retry,
+// redownload and forget.
+func WithAPIDryRun() APIOption {
+	return func(h *APIHandler) {
+		h.dryRun = true
+	}
+}
+
+// NewAPIHandler creates the native API handler. An empty apiKey rejects every
+// authenticated request rather than allowing them all.
+func NewAPIHandler(
+	apiKey string,
+	store TransferStore,
+	orchestrator Orchestrator,
+	seedbox TransferLister,
+	activity ActivitySource,
+	events EventSource,
+	label string,
+	opts ...APIOption,
+) *APIHandler {
+	h := &APIHandler{
+		apiKey:       apiKey,
+		store:        store,
+		orchestrator: orchestrator,
+		seedbox:      seedbox,
+		activity:     activity,
+		events:       events,
+		label:        label,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Routes returns the API routes, relative to wherever they are mounted.
+func (h *APIHandler) Routes() http.Handler {
+	r := chi.NewRouter()
+
+	// The document describes the API; it reveals nothing the source doesn't, so
+	// it is readable without a key.
+	r.Get("/openapi.yaml", h.HandleOpenAPI)
+
+	r.Group(func(r chi.Router) {
+		r.Use(APIKeyAuth(h.apiKey))
+
+		r.Get("/transfers", h.HandleListTransfers)
+		r.Get("/transfers/{id}", h.HandleGetTransfer)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.refuseInDryRun)
+
+			r.Delete("/transfers/{id}", h.HandleForgetTransfer)
+			r.Post("/transfers/{id}/retry", h.HandleRetryTransfer)
+			r.Post("/transfers/{id}/redownload", h.HandleRedownloadTransfer)
+		})
+
+		r.Get("/activity", h.HandleActivity)
+		r.Get("/events", h.HandleEvents)
+
+		r.Post("/poll", h.HandlePoll)
+
+		r.Get("/orchestrator", h.HandleOrchestratorState)
+		r.Post("/orchestrator/pause", h.HandlePause)
+		r.Post("/orchestrator/resume", h.HandleResume)
+
+		if h.reloader != nil {
+			r.Post("/config/reload", h.HandleReload)
+		}
+	})
+
+	return r
+}
+
+// refuseInDryRun refuses, in a dry run, the requests it wraps, which write the
+// ledger.
+func (h *APIHandler) refuseInDryRun(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.dryRun {
+			writeAPIError(w, http.StatusConflict, ErrDryRun.Error())
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandleOpenAPI serves the OpenAPI document embedded in the binary.
+func (h *APIHandler) HandleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPIDocument)
+}
+
+// HandleListTransfers lists every transfer known to the ledger or present on the
+// seedbox under the label.
+func (h *APIHandler) HandleListTransfers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	transfers, err := ListTransfers(ctx, h.store, h.seedbox, h.label)
+	if err != nil {
+		logctx.LoggerFromContext(ctx).ErrorContext(ctx, "failed to list downloads", "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "failed to read the ledger")
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string][]APITransfer{"transfers": transfers})
+}
+
+// ListTransfers joins the ledger to the seedbox's transfers under label, sorted
+// by ID. A seedbox that cannot be reached, or a nil one, degrades the listing to
+// the ledger alone rather than failing it: the ledger is what the operator most
+// often came to read.
+func ListTransfers(ctx context.Context, store TransferStore, seedbox TransferLister, label string) ([]APITransfer, error) {
+	records, err := store.GetDownloads()
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*APITransfer, len(records))
+
+	for _, record := range records {
+		byID[record.DownloadID] = &APITransfer{
+			ID:             record.DownloadID,
+			PipelineStatus: record.Status,
+			ClaimedAt:      record.DownloadedAt,
+			LockedBy:       record.LockedBy,
+		}
+	}
+
+	var transfers []*transfer.Transfer
+
+	if seedbox != nil {
+		transfers, err = seedbox.GetTaggedTorrents(ctx, label)
+		if err != nil {
+			logctx.LoggerFromContext(ctx).WarnContext(ctx, "failed to list seedbox transfers, listing the ledger only",
+				"label", label, "err", err)
+		}
+	}
+
+	for _, t := range transfers {
+		entry, ok := byID[t.ID]
+		if !ok {
+			entry = &APITransfer{ID: t.ID, PipelineStatus: untrackedStatus}
+			byID[t.ID] = entry
+		}
+
+		applySeedbox(entry, t)
+	}
+
+	out := make([]APITransfer, 0, len(byID))
+	for _, entry := range byID {
+		out = append(out, *entry)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+
+	return out, nil
+}
+
+// HandleGetTransfer reports one transfer with its files and history.
+func (h *APIHandler) HandleGetTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := logctx.LoggerFromContext(ctx)
+	id := chi.URLParam(r, "id")
+
+	detail := APITransferDetail{
+		APITransfer: APITransfer{ID: id, PipelineStatus: untrackedStatus},
+		Files:       []APIFile{},
+		History:     []APITransferEvent{},
+	}
+
+	record, err := h.store.GetDownload(id)
+
+	switch {
+	case err == nil:
+		detail.PipelineStatus = record.Status
+		detail.ClaimedAt = record.DownloadedAt
+		detail.LockedBy = record.LockedBy
+	case errors.Is(err, storage.ErrTransferNotFound):
+	default:
+		logger.ErrorContext(ctx, "failed to read transfer", "transfer_id", id, "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "failed to read the ledger")
+
+		return
+	}
+
+	history, err := h.store.GetTransferHistory(id)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to read transfer history", "transfer_id", id, "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "failed to read the ledger")
+
+		return
+	}
+
+	for _, event := range history {
+		detail.History = append(detail.History, APITransferEvent(event))
+	}
+
+	seedboxTransfer, err := h.findOnSeedbox(ctx, id)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to look the transfer up on the seedbox", "transfer_id", id, "err", err)
+	}
+
+	if seedboxTransfer != nil {
+		applySeedbox(&detail.APITransfer, seedboxTransfer)
+
+		if name, derived := seedboxTransfer.LocalName(); derived {
+			detail.LocalName = name
+		}
+
+		for _, f := range seedboxTransfer.Files {
+			detail.Files = append(detail.Files, APIFile{Path: f.Path, Size: f.Size})
+		}
+	}
+
+	if !detail.OnSeedbox && detail.PipelineStatus == untrackedStatus {
+		writeAPIError(w, http.StatusNotFound, "transfer not found")
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, detail)
+}
+
+// HandleRetryTransfer releases a failed or missing transfer so the next poll
+// claims it again.
+func (h *APIHandler) HandleRetryTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := logctx.LoggerFromContext(ctx)
+	id := chi.URLParam(r, "id")
+
+	previous, err := storage.RetryTransfer(h.store, id)
+	if errors.Is(err, storage.ErrNotRetryable) {
+		writeAPIError(w, http.StatusConflict, err.Error())
+
+		return
+	}
+
+	if err != nil {
+		h.writeStoreError(ctx, w, id, err)
+
+		return
+	}
+
+	logger.InfoContext(ctx, "transfer queued for retry", "transfer_id", id, "previous_status", previous)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// HandleRedownloadTransfer releases a transfer whatever its state, including
+// one already downloaded or one left claimed by an instance that died.
+func (h *APIHandler) HandleRedownloadTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := logctx.LoggerFromContext(ctx)
+	id := chi.URLParam(r, "id")
+
+	if err := h.store.ResetTransfer(id); err != nil {
+		h.writeStoreError(ctx, w, id, err)
+
+		return
+	}
+
+	logger.InfoContext(ctx, "transfer queued for re-download", "transfer_id", id)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// HandleForgetTransfer deletes a transfer from the ledger.
+func (h *APIHandler) HandleForgetTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := logctx.LoggerFromContext(ctx)
+	id := chi.URLParam(r, "id")
+
+	if err := h.store.ForgetTransfer(id); err != nil {
+		h.writeStoreError(ctx, w, id, err)
+
+		return
+	}
+
+	logger.InfoContext(ctx, "transfer forgotten", "transfer_id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleActivity reports the downloader's live activity: files being written,
+// transfers waiting on import or seeding, and recent failures.
+func (h *APIHandler) HandleActivity(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, h.activity.Activity())
+}
+
+// HandlePoll asks the orchestrator to poll now.
+func (h *APIHandler) HandlePoll(w http.ResponseWriter, _ *http.Request) {
+	h.orchestrator.Poll()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// HandleOrchestratorState reports whether polling is paused.
+func (h *APIHandler) HandleOrchestratorState(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, APIOrchestratorState{Paused: h.orchestrator.Paused()})
+}
+
+// HandlePause stops the orchestrator claiming transfers.
+func (h *APIHandler) HandlePause(w http.ResponseWriter, r *http.Request) {
+	h.orchestrator.Pause()
+
+	logctx.LoggerFromContext(r.Context()).InfoContext(r.Context(), "orchestrator paused")
+
+	writeJSON(w, http.StatusOK, APIOrchestratorState{Paused: true})
+}
+
+// HandleResume lets the orchestrator claim transfers again.
+func (h *APIHandler) HandleResume(w http.ResponseWriter, r *http.Request) {
+	h.orchestrator.Resume()
+
+	logctx.LoggerFromContext(r.Context()).InfoContext(r.Context(), "orchestrator resumed")
+
+	writeJSON(w, http.StatusOK, APIOrchestratorState{Paused: false})
+}
+
+// HandleReload reloads the configuration, as SIGHUP does. An invalid one is
+// refused whole, and the running configuration kept.
+func (h *APIHandler) HandleReload(w http.ResponseWriter, r *http.Request) {
+	reload, err := h.reloader.Reload(r.Context())
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reload)
+}
+
+// APIKeyAuth guards a handler with the native API key. An empty apiKey rejects
+// every request.
+func APIKeyAuth(apiKey string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKey == "" || subtle.ConstantTimeCompare([]byte(requestAPIKey(r)), []byte(apiKey)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="`+authRealm+`"`)
+				writeAPIError(w, http.StatusUnauthorized, "invalid or missing API key")
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestAPIKey extracts the key from whichever form the client sent it in.
+// Under basic auth the username is ignored.
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return key
+	}
+
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+
+	return ""
+}
+
+func (h *APIHandler) findOnSeedbox(ctx context.Context, id string) (*transfer.Transfer, error) {
+	transfers, err := h.seedbox.GetTaggedTorrents(ctx, h.label)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range transfers {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (h *APIHandler) writeStoreError(ctx context.Context, w http.ResponseWriter, id string, err error) {
+	if errors.Is(err, storage.ErrTransferNotFound) {
+		writeAPIError(w, http.StatusNotFound, "transfer not found")
+
+		return
+	}
+
+	logctx.LoggerFromContext(ctx).ErrorContext(ctx, "ledger operation failed", "transfer_id", id, "err", err)
+	writeAPIError(w, http.StatusInternalServerError, "ledger operation failed")
+}
+
+func applySeedbox(entry *APITransfer, t *transfer.Transfer) {
+	entry.Name = t.Name
+	entry.SeedboxStatus = t.Status
+	entry.OnSeedbox = true
+	entry.Size = t.Size
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, APIError{Error: message})
+}
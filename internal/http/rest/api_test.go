@@ -0,0 +1,400 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/italolelis/seedbox_downloader/internal/downloader"
+	"github.com/italolelis/seedbox_downloader/internal/storage"
+	"github.com/italolelis/seedbox_downloader/internal/transfer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAPIKey = "api-key"
+
+// fakeStore is an in-memory ledger.
+type fakeStore struct {
+	records map[string]storage.DownloadRecord
+	history map[string][]storage.TransferEvent
+}
+
+func newFakeStore(records ...storage.DownloadRecord) *fakeStore {
+	s := &fakeStore{records: map[string]storage.DownloadRecord{}, history: map[string][]storage.TransferEvent{}}
+	for _, r := range records {
+		s.records[r.DownloadID] = r
+	}
+
+	return s
+}
+
+func (s *fakeStore) GetDownloads() ([]storage.DownloadRecord, error) {
+	out := make([]storage.DownloadRecord, 0, len(s.records))
+	for _, r := range s.records {
+		out = append(out, r)
+	}
+
+	return out, nil
+}
+
+func (s *fakeStore) GetDownload(id string) (storage.DownloadRecord, error) {
+	r, ok := s.records[id]
+	if !ok {
+		return storage.DownloadRecord{}, storage.ErrTransferNotFound
+	}
+
+	return r, nil
+}
+
+func (s *fakeStore) GetTransferHistory(id string) ([]storage.TransferEvent, error) {
+	return s.history[id], nil
+}
+
+func (s *fakeStore) ResetTransfer(id string) error {
+	r, ok := s.records[id]
+	if !ok {
+		return storage.ErrTransferNotFound
+	}
+
+	r.Status, r.LockedBy = "pending", ""
+	s.records[id] = r
+
+	return nil
+}
+
+func (s *fakeStore) ForgetTransfer(id string) error {
+	if _, ok := s.records[id]; !ok {
+		return storage.ErrTransferNotFound
+	}
+
+	delete(s.records, id)
+
+	return nil
+}
+
+type fakeOrchestrator struct {
+	polls  int
+	paused bool
+}
+
+func (o *fakeOrchestrator) Poll()        { o.polls++ }
+func (o *fakeOrchestrator) Pause()       { o.paused = true }
+func (o *fakeOrchestrator) Resume()      { o.paused = false }
+func (o *fakeOrchestrator) Paused() bool { return o.paused }
+
+type fakeLister struct {
+	transfers []*transfer.Transfer
+	err       error
+}
+
+func (l *fakeLister) GetTaggedTorrents(context.Context, string) ([]*transfer.Transfer, error) {
+	return l.transfers, l.err
+}
+
+type fakeActivity struct {
+	activity downloader.Activity
+}
+
+func (a fakeActivity) Activity() downloader.Activity { return a.activity }
+
+type fakeReloader struct {
+	reload APIReload
+	err    error
+}
+
+func (r fakeReloader) Reload(context.Context) (APIReload, error) { return r.reload, r.err }
+
+func apiRequest(t *testing.T, h http.Handler, method, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set(APIKeyHeader, testAPIKey)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestAPI_RejectsMissingAndWrongKeys(t *testing.T) {
+	h := NewAPIHandler(testAPIKey, newFakeStore(), &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv").Routes()
+
+	for name, setKey := range map[string]func(*http.Request){
+		"missing":             func(*http.Request) {},
+		"wrong":               func(r *http.Request) { r.Header.Set(APIKeyHeader, "nope") },
+		"wrong basic":         func(r *http.Request) { r.SetBasicAuth("admin", "transmission-password") },
+		"wrong bearer prefix": func(r *http.Request) { r.Header.Set("Authorization", "Token "+testAPIKey) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/transfers", nil)
+			setKey(req)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		})
+	}
+}
+
+func TestAPI_AcceptsBearerToken(t *testing.T) {
+	h := NewAPIHandler(testAPIKey, newFakeStore(), &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv").Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/transfers", nil)
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+// Basic auth is what a browser sends once challenged, which is how the dashboard
+// reaches the API without the key ever being written into the page.
+func TestAPI_AcceptsKeyAsBasicAuthPassword(t *testing.T) {
+	h := NewAPIHandler(testAPIKey, newFakeStore(), &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv").Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/transfers", nil)
+	req.SetBasicAuth("anyone", testAPIKey)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAPI_UnauthorizedChallengesForBasicAuth(t *testing.T) {
+	h := NewAPIHandler(testAPIKey, newFakeStore(), &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv").Routes()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/transfers", nil))
+
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `Basic realm="seedbox_downloader"`, rec.Header().Get("WWW-Authenticate"))
+}
+
+// No key configured must mean nothing gets in, not everything does.
+func TestAPI_EmptyKeyRejectsEverything(t *testing.T) {
+	h := NewAPIHandler("", newFakeStore(), &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv").Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/transfers", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAPI_OpenAPIDocumentNeedsNoKey(t *testing.T) {
+	h := NewAPIHandler(testAPIKey, newFakeStore(), &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv").Routes()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.yaml", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "openapi: 3.0.3")
+}
+
+func TestAPI_ListJoinsLedgerAndSeedbox(t *testing.T) {
+	store := newFakeStore(
+		storage.DownloadRecord{DownloadID: "1", Status: "downloaded"},
+		storage.DownloadRecord{DownloadID: "2", Status: "failed"},
+	)
+	lister := &fakeLister{transfers: []*transfer.Transfer{
+		{ID: "1", Name: "Show.S01E01", Status: "COMPLETED", Size: 10},
+		{ID: "3", Name: "Show.S01E02", Status: "DOWNLOADING"},
+	}}
+
+	h := NewAPIHandler(testAPIKey, store, &fakeOrchestrator{}, lister, fakeActivity{}, nil, "itv").Routes()
+
+	rec := apiRequest(t, h, http.MethodGet, "/transfers")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Transfers []APITransfer `json:"transfers"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Transfers, 3)
+
+	assert.Equal(t, APITransfer{
+		ID: "1", Name: "Show.S01E01", SeedboxStatus: "COMPLETED", OnSeedbox: true, PipelineStatus: "downloaded", Size: 10,
+	}, body.Transfers[0])
+	assert.Equal(t, APITransfer{ID: "2", PipelineStatus: "failed"}, body.Transfers[1])
+	assert.Equal(t, "untracked", body.Transfers[2].PipelineStatus)
+	assert.True(t, body.Transfers[2].OnSeedbox)
+}
+
+// The ledger is what the operator most often came to read, so a seedbox outage
+// must not take the listing down with it.
+func TestAPI_ListFallsBackToLedgerWhenSeedboxFails(t *testing.T) {
+	store := newFakeStore(storage.DownloadRecord{DownloadID: "1", Status: "downloaded"})
+	lister := &fakeLister{err: errors.New("seedbox unreachable")}
+
+	h := NewAPIHandler(testAPIKey, store, &fakeOrchestrator{}, lister, fakeActivity{}, nil, "itv").Routes()
+
+	rec := apiRequest(t, h, http.MethodGet, "/transfers")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"pipeline_status":"downloaded"`)
+}
+
+func TestAPI_GetReportsFilesAndHistory(t *testing.T) {
+	store := newFakeStore(storage.DownloadRecord{DownloadID: "1", Status: "downloaded"})
+	store.history["1"] = []storage.TransferEvent{
+		{Status: "downloading", At: "2025-01-01T00:00:00Z"},
+		{Status: "downloaded", At: "2025-01-01T00:05:00Z"},
+	}
+	lister := &fakeLister{transfers: []*transfer.Transfer{{
+		ID:    "1",
+		Name:  "Show.S01",
+		Files: []*transfer.File{{Path: "Show.S01/e01.mkv", Size: 5}, {Path: "Show.S01/e02.mkv", Size: 6}},
+	}}}
+
+	h := NewAPIHandler(testAPIKey, store, &fakeOrchestrator{}, lister, fakeActivity{}, nil, "itv").Routes()
+
+	rec := apiRequest(t, h, http.MethodGet, "/transfers/1")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var detail APITransferDetail
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &detail))
+
+	assert.Equal(t, "Show.S01", detail.LocalName)
+	assert.Len(t, detail.Files, 2)
+	assert.Equal(t, []APITransferEvent{
+		{Status: "downloading", At: "2025-01-01T00:00:00Z"},
+		{Status: "downloaded", At: "2025-01-01T00:05:00Z"},
+	}, detail.History)
+}
+
+func TestAPI_GetUnknownTransferIsNotFound(t *testing.T) {
+	h := NewAPIHandler(testAPIKey, newFakeStore(), &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv").Routes()
+
+	rec := apiRequest(t, h, http.MethodGet, "/transfers/404")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAPI_RetryOnlyFromAFailedState(t *testing.T) {
+	tests := []struct {
+		status string
+		want   int
+	}{
+		{"failed", http.StatusAccepted},
+		{"missing", http.StatusAccepted},
+		{"downloaded", http.StatusConflict},
+		{"downloading", http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			store := newFakeStore(storage.DownloadRecord{DownloadID: "1", Status: tt.status})
+			h := NewAPIHandler(testAPIKey, store, &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv").Routes()
+
+			rec := apiRequest(t, h, http.MethodPost, "/transfers/1/retry")
+			assert.Equal(t, tt.want, rec.Code)
+
+			if tt.want == http.StatusAccepted {
+				assert.Equal(t, "pending", store.records["1"].Status)
+			} else {
+				assert.Equal(t, tt.status, store.records["1"].Status, "a refused retry must not change the transfer")
+			}
+		})
+	}
+}
+
+// Re-download is the escape hatch for a claim left behind by an instance that
+// stopped mid-download, so it must work from any state.
+func TestAPI_RedownloadFromAnyState(t *testing.T) {
+	store := newFakeStore(storage.DownloadRecord{DownloadID: "1", Status: "downloading", LockedBy: "dead-instance"})
+	h := NewAPIHandler(testAPIKey, store, &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv").Routes()
+
+	rec := apiRequest(t, h, http.MethodPost, "/transfers/1/redownload")
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, storage.DownloadRecord{DownloadID: "1", Status: "pending"}, store.records["1"])
+}
+
+func TestAPI_Forget(t *testing.T) {
+	store := newFakeStore(storage.DownloadRecord{DownloadID: "1", Status: "downloaded"})
+	h := NewAPIHandler(testAPIKey, store, &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv").Routes()
+
+	assert.Equal(t, http.StatusNoContent, apiRequest(t, h, http.MethodDelete, "/transfers/1").Code)
+	assert.NotContains(t, store.records, "1")
+	assert.Equal(t, http.StatusNotFound, apiRequest(t, h, http.MethodDelete, "/transfers/1").Code)
+}
+
+// A dry run changes nothing, the ledger included, so the requests that would
+// write it are refused and it is left as it was.
+func TestAPI_DryRunRefusesLedgerWrites(t *testing.T) {
+	record := storage.DownloadRecord{DownloadID: "1", Status: "failed"}
+	store := newFakeStore(record)
+	h := NewAPIHandler(testAPIKey, store, &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv", WithAPIDryRun()).Routes()
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodPost, "/transfers/1/retry"},
+		{http.MethodPost, "/transfers/1/redownload"},
+		{http.MethodDelete, "/transfers/1"},
+	} {
+		rec := apiRequest(t, h, req.method, req.path)
+		assert.Equal(t, http.StatusConflict, rec.Code, req.path)
+		assert.Contains(t, rec.Body.String(), ErrDryRun.Error(), req.path)
+	}
+
+	assert.Equal(t, record, store.records["1"])
+	assert.Equal(t, http.StatusOK, apiRequest(t, h, http.MethodGet, "/transfers/1").Code, "reads are still served")
+}
+
+func TestAPI_OrchestratorControls(t *testing.T) {
+	orchestrator := &fakeOrchestrator{}
+	h := NewAPIHandler(testAPIKey, newFakeStore(), orchestrator, &fakeLister{}, fakeActivity{}, nil, "itv").Routes()
+
+	assert.Equal(t, http.StatusAccepted, apiRequest(t, h, http.MethodPost, "/poll").Code)
+	assert.Equal(t, 1, orchestrator.polls)
+
+	require.Equal(t, http.StatusOK, apiRequest(t, h, http.MethodPost, "/orchestrator/pause").Code)
+	assert.True(t, orchestrator.paused)
+
+	rec := apiRequest(t, h, http.MethodGet, "/orchestrator")
+	assert.JSONEq(t, `{"paused":true}`, rec.Body.String())
+
+	require.Equal(t, http.StatusOK, apiRequest(t, h, http.MethodPost, "/orchestrator/resume").Code)
+	assert.False(t, orchestrator.paused)
+}
+
+func TestAPI_Activity(t *testing.T) {
+	activity := fakeActivity{activity: downloader.Activity{
+		Downloads: []downloader.FileProgress{{TransferID: "1", Path: "Show/e01.mkv", Written: 5, Total: 10}},
+		Watchers:  []downloader.Watcher{},
+		Failures:  []downloader.Failure{{TransferID: "2", Error: "boom"}},
+	}}
+	h := NewAPIHandler(testAPIKey, newFakeStore(), &fakeOrchestrator{}, &fakeLister{}, activity, nil, "itv").Routes()
+
+	rec := apiRequest(t, h, http.MethodGet, "/activity")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got downloader.Activity
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, int64(5), got.Downloads[0].Written)
+	assert.Equal(t, "boom", got.Failures[0].Error)
+}
+
+func TestAPI_Reload(t *testing.T) {
+	h := NewAPIHandler(testAPIKey, newFakeStore(), &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv").Routes()
+	assert.Equal(t, http.StatusNotFound, apiRequest(t, h, http.MethodPost, "/config/reload").Code, "served only with a reloader")
+
+	reloader := fakeReloader{reload: APIReload{Applied: []string{"LOG_LEVEL"}, Refused: []string{"DB_PATH"}}}
+	h = NewAPIHandler(testAPIKey, newFakeStore(), &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv",
+		WithReloader(reloader)).Routes()
+
+	rec := apiRequest(t, h, http.MethodPost, "/config/reload")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"applied":["LOG_LEVEL"],"refused":["DB_PATH"]}`, rec.Body.String())
+
+	reloader.err = errors.New("invalid POLLING_INTERVAL")
+	h = NewAPIHandler(testAPIKey, newFakeStore(), &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv",
+		WithReloader(reloader)).Routes()
+
+	rec = apiRequest(t, h, http.MethodPost, "/config/reload")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "invalid POLLING_INTERVAL")
+}
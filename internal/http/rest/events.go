@@ -0,0 +1,107 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/logctx"
+)
+
+// heartbeatInterval is how often an idle event stream sends a comment line, so
+// proxies that close quiet connections leave it open.
+const heartbeatInterval = 15 * time.Second
+
+// HandleEvents streams pipeline events as server-sent events. A client that
+// reconnects with Last-Event-ID -- which browsers send on their own -- first
+// receives what it missed, as far back as the bus remembers. The stream ends
+// when the client falls too far behind to keep up; reconnecting resumes it.
+func (h *APIHandler) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := logctx.LoggerFromContext(ctx)
+
+	lastID, err := lastEventID(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	rc := http.NewResponseController(w)
+
+	// The server's write timeout is sized for JSON responses; a stream is meant
+	// to outlive it.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		logger.DebugContext(ctx, "cannot clear write deadline for event stream", "err", err)
+	}
+
+	stream, cancel := h.events.Subscribe(lastID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		logger.ErrorContext(ctx, "event stream cannot be flushed", "err", err)
+
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-stream:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				logger.ErrorContext(ctx, "failed to encode event", "event_id", event.ID, "err", err)
+
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// lastEventID reads where a reconnecting client left off: the Last-Event-ID
+// header, or a last_event_id query parameter for clients that cannot set
+// headers. Neither means start from now.
+func lastEventID(r *http.Request) (uint64, error) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+
+	if raw == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid last event id %q", raw)
+	}
+
+	return id, nil
+}
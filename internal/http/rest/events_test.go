@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseFrame is one server-sent event as read off the wire.
+type sseFrame struct {
+	id, event, data string
+}
+
+func readFrame(t *testing.T, r *bufio.Reader) sseFrame {
+	t.Helper()
+
+	var f sseFrame
+
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "":
+			if f.id != "" {
+				return f
+			}
+		case strings.HasPrefix(line, "id: "):
+			f.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			f.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			f.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func openEventStream(t *testing.T, url, lastEventID string) *bufio.Reader {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url+"/events", nil)
+	require.NoError(t, err)
+	req.Header.Set(APIKeyHeader, testAPIKey)
+
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	return bufio.NewReader(resp.Body)
+}
+
+func TestAPI_EventsStreamsAsPublished(t *testing.T) {
+	bus := events.NewBus(events.DefaultHistory)
+	srv := httptest.NewServer(NewAPIHandler(testAPIKey, newFakeStore(), &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, bus, "itv").Routes())
+	t.Cleanup(srv.Close)
+
+	stream := openEventStream(t, srv.URL, "")
+
+	bus.Publish(events.TransferDownloadFailed, events.Transfer{ID: "7", Name: "Show", Error: "boom"})
+
+	f := readFrame(t, stream)
+	assert.Equal(t, "1", f.id)
+	assert.Equal(t, events.TransferDownloadFailed, f.event)
+
+	var got struct {
+		Type string          `json:"type"`
+		Data events.Transfer `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(f.data), &got))
+	assert.Equal(t, events.TransferDownloadFailed, got.Type)
+	assert.Equal(t, events.Transfer{ID: "7", Name: "Show", Error: "boom"}, got.Data)
+}
+
+func TestAPI_EventsResumeFromLastEventID(t *testing.T) {
+	bus := events.NewBus(events.DefaultHistory)
+	srv := httptest.NewServer(NewAPIHandler(testAPIKey, newFakeStore(), &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, bus, "itv").Routes())
+	t.Cleanup(srv.Close)
+
+	bus.Publish(events.TransferClaimed, events.Transfer{ID: "1"})
+	bus.Publish(events.TransferDownloaded, events.Transfer{ID: "1"})
+	bus.Publish(events.TransferImported, events.Transfer{ID: "1"})
+
+	stream := openEventStream(t, srv.URL, "1")
+
+	assert.Equal(t, events.TransferDownloaded, readFrame(t, stream).event)
+	assert.Equal(t, events.TransferImported, readFrame(t, stream).event)
+}
+
+func TestAPI_EventsRejectsABadLastEventID(t *testing.T) {
+	h := NewAPIHandler(testAPIKey, newFakeStore(), &fakeOrchestrator{}, &fakeLister{}, fakeActivity{}, nil, "itv").Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/events?last_event_id=yesterday", nil)
+	req.Header.Set(APIKeyHeader, testAPIKey)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
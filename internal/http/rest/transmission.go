@@ -12,9 +12,11 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/italolelis/seedbox_downloader/internal/logctx"
+	"github.com/italolelis/seedbox_downloader/internal/pathmap"
 	"github.com/italolelis/seedbox_downloader/internal/telemetry"
 	"github.com/italolelis/seedbox_downloader/internal/transfer"
 	"github.com/zeebo/bencode"
@@ -24,6 +26,10 @@ const sessionID = "useless-session-id"
 
 const maxTorrentSize = 10 * 1024 * 1024 // 10MB - matches Phase 4 limit
 
+// ErrDryRun is the reason a method that would change the seedbox is refused in a
+// dry run.
+var ErrDryRun = errors.New("refused in a dry run")
+
 // DownloadClient defines the interface for torrent client operations.
 // This interface enables mocking in tests while the production code uses *putio.Client.
 type DownloadClient interface {
@@ -122,18 +128,77 @@ type TransmissionHandler struct {
 	dc       DownloadClient
 	label    string
 	// localRoot is the directory on local disk that transfers are written into.
-	// It is what gets advertised to the *arr apps, because it is the only path
-	// that actually exists from their point of view. A seedbox-side path must
+	// It is what gets advertised to the *arr apps -- translated to where each
+	// mounts it -- because it is the only path that actually exists from their
+	// point of view. A seedbox-side path must
 	// never be put here: advertising one is why imports silently never happened.
 	localRoot string
 	telemetry *telemetry.Telemetry
+	// dryRun refuses the methods that would change anything on the seedbox.
+	dryRun bool
+
+	// mu guards what Reconfigure may change: the fields below it.
+	mu sync.RWMutex
+
+	// owners records which *arr instance added each transfer; instances are the
+	// names an app may identify itself by. Both are nil without WithOwners.
+	owners    OwnerRecorder
+	instances map[string]bool
+
+	// paths translates localRoot for the app asking, by the username it connected
+	// with; defaultPaths is for an app that did not identify itself.
+	paths        map[string]pathmap.Mappings
+	defaultPaths pathmap.Mappings
+}
+
+// OwnerRecorder records which *arr instance added a transfer.
+type OwnerRecorder interface {
+	SetTransferOwner(transferID, owner string) error
+}
+
+// TransmissionOption configures a TransmissionHandler.
+type TransmissionOption func(*TransmissionHandler)
+
+// WithOwners records the *arr instance behind each torrent-add in owners. An app
+// identifies itself by connecting with its instance name as the username, or by
+// sending it as the category; either must be one of instances. The password is
+// the same for every app, so naming an instance grants nothing it did not have.
+func WithOwners(owners OwnerRecorder, instances ...string) TransmissionOption {
+	return func(h *TransmissionHandler) {
+		h.owners = owners
+		h.instances = make(map[string]bool, len(instances))
+
+		for _, name := range instances {
+			h.instances[name] = true
+		}
+	}
+}
+
+// WithPathMappings advertises the local root as each app mounts it: byInstance
+// is keyed by the instance name an app connects as, and fallback serves any app
+// that connects as TRANSMISSION_USERNAME.
+func WithPathMappings(byInstance map[string]pathmap.Mappings, fallback pathmap.Mappings) TransmissionOption {
+	return func(h *TransmissionHandler) {
+		h.paths = byInstance
+		h.defaultPaths = fallback
+	}
+}
+
+// WithDryRun refuses torrent-add and torrent-remove, the methods that would
+// change anything on the seedbox, with an error result the *arr apps show. It is
+// given at construction; Reconfigure cannot turn a dry run on or off.
+func WithDryRun() TransmissionOption {
+	return func(h *TransmissionHandler) {
+		h.dryRun = true
+	}
 }
 
 // NewTransmissionHandler creates a new content handler.
 func NewTransmissionHandler(
 	username, password string, dc DownloadClient, label string, localRoot string, t *telemetry.Telemetry,
+	opts ...TransmissionOption,
 ) *TransmissionHandler {
-	return &TransmissionHandler{
+	h := &TransmissionHandler{
 		username:  username,
 		password:  password,
 		dc:        dc,
@@ -141,6 +206,31 @@ func NewTransmissionHandler(
 		localRoot: localRoot,
 		telemetry: t,
 	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Reconfigure applies opts to a handler that may already be serving requests,
+// for the *arr instances and their path mappings to change without a restart.
+func (h *TransmissionHandler) Reconfigure(opts ...TransmissionOption) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, opt := range opts {
+		opt(h)
+	}
+}
+
+// isInstance reports whether name is one of the *arr instances.
+func (h *TransmissionHandler) isInstance(name string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.instances[name]
 }
 
 func (h *TransmissionHandler) Routes() http.Handler {
@@ -173,7 +263,7 @@ func (h *TransmissionHandler) HandleRPC(w http.ResponseWriter, r *http.Request)
 
 	switch req.Method {
 	case "session-get":
-		tConfig := NewTransmissionConfig(h.localRoot)
+		tConfig := NewTransmissionConfig(h.advertisedRoot(r))
 
 		w.Header().Set("Content-Type", "application/json")
 
@@ -190,7 +280,7 @@ func (h *TransmissionHandler) HandleRPC(w http.ResponseWriter, r *http.Request)
 			Arguments: jsonConfig,
 		}
 	case "torrent-get":
-		response, err = h.handleTorrentGet(ctx)
+		response, err = h.handleTorrentGet(ctx, h.advertisedRoot(r))
 	case "torrent-set":
 		// Nothing to do here
 		response = &TransmissionResponse{
@@ -202,9 +292,13 @@ func (h *TransmissionHandler) HandleRPC(w http.ResponseWriter, r *http.Request)
 			Result: "success",
 		}
 	case "torrent-remove":
-		response, err = h.handleTorrentRemove(ctx, &req)
+		if err = h.refuseInDryRun(req.Method); err == nil {
+			response, err = h.handleTorrentRemove(ctx, &req)
+		}
 	case "torrent-add":
-		response, err = h.handleTorrentAdd(ctx, &req)
+		if err = h.refuseInDryRun(req.Method); err == nil {
+			response, err = h.handleTorrentAdd(ctx, r, &req)
+		}
 	default:
 		logger.ErrorContext(ctx, "unknown method", "method", req.Method)
 		http.Error(w, fmt.Sprintf("unknown method %s", req.Method), http.StatusBadRequest)
@@ -242,6 +336,15 @@ func (h *TransmissionHandler) HandleRPC(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// refuseInDryRun fails method, one that changes the seedbox, in a dry run.
+func (h *TransmissionHandler) refuseInDryRun(method string) error {
+	if !h.dryRun {
+		return nil
+	}
+
+	return fmt.Errorf("%s: %w", method, ErrDryRun)
+}
+
 // HandleRPCGet handles GET requests to the RPC endpoint.
 func (h *TransmissionHandler) HandleRPCGet(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -259,7 +362,7 @@ func (h *TransmissionHandler) basicAuthMiddleware(next http.Handler) http.Handle
 			return
 		}
 
-		if username != h.username || password != h.password {
+		if (username != h.username && !h.isInstance(username)) || password != h.password {
 			http.Error(w, "invalid username or password", http.StatusUnauthorized)
 
 			return
@@ -373,7 +476,9 @@ func (h *TransmissionHandler) handleTorrentAddByMetaInfo(ctx context.Context, re
 	return torrent, nil
 }
 
-func (h *TransmissionHandler) handleTorrentAdd(ctx context.Context, req *TransmissionRequest) (*TransmissionResponse, error) {
+func (h *TransmissionHandler) handleTorrentAdd(
+	ctx context.Context, r *http.Request, req *TransmissionRequest,
+) (*TransmissionResponse, error) {
 	logger := logctx.LoggerFromContext(ctx).With("method", "handle_torrent_add")
 
 	torrent, err := h.resolveTorrent(ctx, req, logger)
@@ -381,6 +486,8 @@ func (h *TransmissionHandler) handleTorrentAdd(ctx context.Context, req *Transmi
 		return nil, err
 	}
 
+	h.recordOwner(ctx, r, req, torrent, logger)
+
 	// Marshal success response (Transmission format with torrent-added)
 	jsonTorrent, err := json.Marshal(map[string]interface{}{
 		"torrent-added": map[string]interface{}{
@@ -399,6 +506,49 @@ func (h *TransmissionHandler) handleTorrentAdd(ctx context.Context, req *Transmi
 	}, nil
 }
 
+// recordOwner records which *arr instance added torrent: the one named by the
+// username, or failing that by the category. Failing to record it only costs the
+// import check its precision, so it does not fail the add.
+func (h *TransmissionHandler) recordOwner(
+	ctx context.Context, r *http.Request, req *TransmissionRequest, torrent *transfer.Transfer, logger *slog.Logger,
+) {
+	h.mu.RLock()
+	owners := h.owners
+	h.mu.RUnlock()
+
+	if owners == nil {
+		return
+	}
+
+	owner := ""
+
+	if username, _, _ := r.BasicAuth(); h.isInstance(username) {
+		owner = username
+	} else {
+		for _, label := range req.Arguments.Labels {
+			if h.isInstance(label) {
+				owner = label
+
+				break
+			}
+		}
+	}
+
+	if owner == "" {
+		logger.DebugContext(ctx, "torrent added by an unidentified app", "transfer_id", torrent.ID)
+
+		return
+	}
+
+	if err := owners.SetTransferOwner(torrent.ID, owner); err != nil {
+		logger.WarnContext(ctx, "failed to record transfer owner", "transfer_id", torrent.ID, "owner", owner, "err", err)
+
+		return
+	}
+
+	logger.DebugContext(ctx, "transfer owner recorded", "transfer_id", torrent.ID, "owner", owner)
+}
+
 // resolveTorrent determines the torrent source (metainfo or magnet) and adds the transfer.
 func (h *TransmissionHandler) resolveTorrent(
 	ctx context.Context, req *TransmissionRequest, logger *slog.Logger,
@@ -450,7 +600,21 @@ func (h *TransmissionHandler) handleTorrentRemove(ctx context.Context, req *Tran
 	}, nil
 }
 
-func (h *TransmissionHandler) handleTorrentGet(ctx context.Context) (*TransmissionResponse, error) {
+// advertisedRoot is the local root as the app making r sees it.
+func (h *TransmissionHandler) advertisedRoot(r *http.Request) string {
+	username, _, _ := r.BasicAuth()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if mappings, ok := h.paths[username]; ok {
+		return mappings.ToRemote(h.localRoot)
+	}
+
+	return h.defaultPaths.ToRemote(h.localRoot)
+}
+
+func (h *TransmissionHandler) handleTorrentGet(ctx context.Context, root string) (*TransmissionResponse, error) {
 	logger := logctx.LoggerFromContext(ctx).With("method", "handle_torrent_get")
 
 	logger.DebugContext(ctx, "fetching torrents from download client")
@@ -507,8 +671,6 @@ func (h *TransmissionHandler) handleTorrentGet(ctx context.Context) (*Transmissi
 			status = StatusStopped // 0
 		}
 
-		hashBytes := sha1.Sum([]byte(transfer.ID))
-
 		// The advertised path must be the path that was written. The *arr apps join
 		// these two and look for the result on disk, with no branching on whether
 		// the transfer is one file or a folder -- so the name has to come from the
@@ -524,9 +686,9 @@ func (h *TransmissionHandler) handleTorrentGet(ctx context.Context) (*Transmissi
 
 		transmissionTorrents[i] = TransmissionTorrent{
 			ID:            id,
-			HashString:    hex.EncodeToString(hashBytes[:]),
+			HashString:    transfer.HashString(),
 			Name:          name,
-			DownloadDir:   h.localRoot,
+			DownloadDir:   root,
 			TotalSize:     transfer.Size,
 			LeftUntilDone: transfer.Size - transfer.Downloaded,
 			IsFinished: strings.ToLower(transfer.Status) == "completed" ||
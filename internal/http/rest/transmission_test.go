@@ -12,6 +12,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/italolelis/seedbox_downloader/internal/pathmap"
 	"github.com/italolelis/seedbox_downloader/internal/transfer"
 	"github.com/stretchr/testify/require"
 )
@@ -787,3 +788,146 @@ func TestHandleTorrentGet_LabelsPopulated(t *testing.T) {
 
 	require.Equal(t, []string{"mytag"}, args.Torrents[0].Labels)
 }
+
+type fakeOwners map[string]string
+
+func (o fakeOwners) SetTransferOwner(transferID, owner string) error {
+	o[transferID] = owner
+
+	return nil
+}
+
+func TestHandleTorrentAdd_RecordsTheOwner(t *testing.T) {
+	magnet := `{"method": "torrent-add", "arguments": {"filename": "magnet:?xt=urn:btih:ABCDEF", "labels": [%s]}}`
+
+	tests := []struct {
+		name     string
+		username string
+		labels   string
+		want     string
+	}{
+		{name: "instance name as username", username: "sonarr", want: "sonarr"},
+		{name: "instance name as category", username: "testuser", labels: `"radarr"`, want: "radarr"},
+		{name: "username wins over category", username: "sonarr", labels: `"radarr"`, want: "sonarr"},
+		{name: "unidentified", username: "testuser", labels: `"tv-sonarr"`, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := &mockPutioClient{
+				addTransferFunc: func(ctx context.Context, magnetLink, parentName string) (*transfer.Transfer, error) {
+					return &transfer.Transfer{ID: "42", Name: "magnet-transfer"}, nil
+				},
+			}
+			owners := fakeOwners{}
+
+			handler := NewTransmissionHandler("testuser", "testpass", mockClient, "test-label", "/downloads", nil,
+				WithOwners(owners, "sonarr", "radarr"))
+
+			req := httptest.NewRequest(http.MethodPost, "/transmission/rpc", strings.NewReader(fmt.Sprintf(magnet, tt.labels)))
+			req.SetBasicAuth(tt.username, "testpass")
+
+			w := httptest.NewRecorder()
+			handler.Routes().ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, tt.want, owners["42"])
+		})
+	}
+}
+
+func TestBasicAuth_InstanceNamesNeedThePassword(t *testing.T) {
+	handler := NewTransmissionHandler("testuser", "testpass", &mockPutioClient{}, "test-label", "/downloads", nil,
+		WithOwners(fakeOwners{}, "sonarr"))
+
+	for _, creds := range [][2]string{{"sonarr", "wrong"}, {"lidarr", "testpass"}} {
+		req := httptest.NewRequest(http.MethodPost, "/transmission/rpc", strings.NewReader(`{"method": "session-get"}`))
+		req.SetBasicAuth(creds[0], creds[1])
+
+		w := httptest.NewRecorder()
+		handler.Routes().ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusUnauthorized, w.Code, creds[0])
+	}
+}
+
+// Each app is told the download directory as it mounts it, by the name it
+// connects as; an app that does not say who it is gets the default mappings.
+func TestHandleRPC_AdvertisesTheRootAsEachAppSeesIt(t *testing.T) {
+	mockClient := &mockPutioClient{
+		getTaggedTorrentsFunc: func(ctx context.Context, label string) ([]*transfer.Transfer, error) {
+			return []*transfer.Transfer{{ID: "1", Name: "Show", Status: "COMPLETED", Files: []*transfer.File{{Path: "Show/e01.mkv"}}}}, nil
+		},
+	}
+
+	handler := NewTransmissionHandler("testuser", "testpass", mockClient, "test-label", "/downloads", nil,
+		WithOwners(fakeOwners{}, "sonarr", "radarr"),
+		WithPathMappings(map[string]pathmap.Mappings{
+			"sonarr": {{Local: "/downloads", Remote: "/tv/torrents"}},
+			"radarr": nil,
+		}, pathmap.Mappings{{Local: "/downloads", Remote: "/data"}}),
+	)
+
+	for username, want := range map[string]string{"sonarr": "/tv/torrents", "radarr": "/downloads", "testuser": "/data"} {
+		for _, method := range []string{"session-get", "torrent-get"} {
+			req := httptest.NewRequest(http.MethodPost, "/transmission/rpc", strings.NewReader(`{"method": "`+method+`"}`))
+			req.SetBasicAuth(username, "testpass")
+
+			w := httptest.NewRecorder()
+			handler.Routes().ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var resp TransmissionResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+			var args struct {
+				DownloadDir string                `json:"download-dir"`
+				Torrents    []TransmissionTorrent `json:"torrents"`
+			}
+			require.NoError(t, json.Unmarshal(resp.Arguments, &args))
+
+			if method == "torrent-get" {
+				require.Len(t, args.Torrents, 1)
+				args.DownloadDir = args.Torrents[0].DownloadDir
+			}
+
+			require.Equal(t, want, args.DownloadDir, "%s as %s", method, username)
+		}
+	}
+}
+
+// A dry run against a real account must not add to it or remove from it, and
+// the app that asked is told why.
+func TestHandleRPC_DryRunRefusesWhatWouldChangeTheSeedbox(t *testing.T) {
+	mockClient := &mockPutioClient{}
+	handler := NewTransmissionHandler("testuser", "testpass", mockClient, "test-label", "/downloads", nil, WithDryRun())
+
+	for _, body := range []string{
+		`{"method": "torrent-add", "arguments": {"filename": "magnet:?xt=urn:btih:abc"}}`,
+		`{"method": "torrent-remove", "arguments": {"ids": ["abc"], "delete-local-data": true}}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/transmission/rpc", strings.NewReader(body))
+		req.SetBasicAuth("testuser", "testpass")
+
+		w := httptest.NewRecorder()
+		handler.Routes().ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp TransmissionResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Contains(t, resp.Result, "refused in a dry run")
+	}
+
+	require.False(t, mockClient.addTransferCalled)
+
+	req := httptest.NewRequest(http.MethodPost, "/transmission/rpc", strings.NewReader(`{"method": "torrent-get"}`))
+	req.SetBasicAuth("testuser", "testpass")
+
+	w := httptest.NewRecorder()
+	handler.Routes().ServeHTTP(w, req)
+
+	var resp TransmissionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, "success", resp.Result, "reading is still allowed")
+}
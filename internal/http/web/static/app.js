@@ -0,0 +1,180 @@
+// The dashboard is a thin client of the native API. Requests are relative so it
+// keeps working behind a reverse proxy that serves it under a path prefix, and
+// they carry no key: the browser resends the basic-auth credentials it was
+// challenged for when the page loaded.
+"use strict";
+
+const API = "../api/v1";
+const REFRESH_MS = 2000;
+
+const RETRYABLE = new Set(["failed", "missing", "import_failed"]);
+
+async function api(method, path) {
+  const resp = await fetch(`${API}${path}`, { method, credentials: "same-origin" });
+  if (!resp.ok) {
+    let message = resp.statusText;
+    try {
+      message = (await resp.json()).error || message;
+    } catch (_) {
+      // Not JSON; the status text will do.
+    }
+    throw new Error(`${method} ${path}: ${message}`);
+  }
+  if (resp.status === 204 || resp.headers.get("Content-Type") !== "application/json") {
+    return null;
+  }
+  return resp.json();
+}
+
+function humanBytes(n) {
+  if (!n) return "";
+  const units = ["B", "kB", "MB", "GB", "TB"];
+  let i = 0;
+  while (n >= 1000 && i < units.length - 1) {
+    n /= 1000;
+    i++;
+  }
+  return `${n.toFixed(i === 0 ? 0 : 1)} ${units[i]}`;
+}
+
+function when(iso) {
+  return iso ? new Date(iso).toLocaleString() : "";
+}
+
+function cell(content) {
+  const td = document.createElement("td");
+  if (content instanceof Node) {
+    td.appendChild(content);
+  } else {
+    td.textContent = content ?? "";
+  }
+  return td;
+}
+
+function fill(tbodyID, rows, columns, empty) {
+  const tbody = document.getElementById(tbodyID);
+  tbody.replaceChildren();
+  if (rows.length === 0) {
+    const td = cell(empty);
+    td.colSpan = columns;
+    td.className = "empty";
+    const tr = document.createElement("tr");
+    tr.appendChild(td);
+    tbody.appendChild(tr);
+    return;
+  }
+  for (const cells of rows) {
+    const tr = document.createElement("tr");
+    for (const c of cells) tr.appendChild(cell(c));
+    tbody.appendChild(tr);
+  }
+}
+
+function action(label, method, path, confirmText) {
+  const button = document.createElement("button");
+  button.type = "button";
+  button.textContent = label;
+  button.addEventListener("click", async () => {
+    if (confirmText && !window.confirm(confirmText)) return;
+    await run(() => api(method, path));
+  });
+  return button;
+}
+
+function actions(t) {
+  const span = document.createElement("span");
+  const id = encodeURIComponent(t.id);
+  if (RETRYABLE.has(t.pipeline_status)) {
+    span.appendChild(action("Retry", "POST", `/transfers/${id}/retry`));
+  }
+  if (t.pipeline_status !== "untracked") {
+    span.appendChild(action("Re-download", "POST", `/transfers/${id}/redownload`,
+      `Download ${t.name || t.id} again?`));
+    span.appendChild(action("Forget", "DELETE", `/transfers/${id}`,
+      `Forget ${t.name || t.id}? Its ledger row and history are deleted.`));
+  }
+  return span;
+}
+
+function progressBar(d) {
+  const wrap = document.createElement("span");
+  const bar = document.createElement("progress");
+  if (d.total > 0) {
+    bar.max = d.total;
+    bar.value = d.written;
+  }
+  wrap.appendChild(bar);
+  const pct = d.total > 0 ? ` ${((d.written * 100) / d.total).toFixed(1)}%` : "";
+  wrap.appendChild(document.createTextNode(` ${humanBytes(d.written)} / ${humanBytes(d.total)}${pct}`));
+  return wrap;
+}
+
+let paused = false;
+
+function showError(err) {
+  const el = document.getElementById("error");
+  el.hidden = !err;
+  el.textContent = err ? err.message : "";
+}
+
+async function run(fn) {
+  try {
+    await fn();
+    await refresh();
+  } catch (err) {
+    showError(err);
+  }
+}
+
+async function refresh() {
+  try {
+    const [transfers, activity, orchestrator] = await Promise.all([
+      api("GET", "/transfers"),
+      api("GET", "/activity"),
+      api("GET", "/orchestrator"),
+    ]);
+
+    paused = orchestrator.paused;
+    const badge = document.getElementById("orchestrator-state");
+    badge.textContent = paused ? "paused" : "polling";
+    badge.classList.toggle("paused", paused);
+    document.getElementById("toggle-pause").textContent = paused ? "Resume" : "Pause";
+
+    fill("downloads",
+      activity.downloads.map((d) => [d.transfer_name || d.transfer_id, d.path, progressBar(d)]),
+      3, "Nothing downloading");
+
+    fill("watchers",
+      activity.watchers.map((w) => [w.transfer_name || w.transfer_id, w.kind, when(w.since)]),
+      3, "Nothing waiting");
+
+    fill("transfers",
+      transfers.transfers.map((t) => [
+        t.id, t.name, t.seedbox_status || (t.on_seedbox ? "" : "gone"), t.pipeline_status, humanBytes(t.size), actions(t),
+      ]),
+      6, "No transfers");
+
+    fill("failures",
+      activity.failures.map((f) => [when(f.at), f.transfer_name || f.transfer_id, f.error]),
+      3, "No recent failures");
+
+    showError(null);
+  } catch (err) {
+    showError(err);
+  }
+}
+
+document.getElementById("poll").addEventListener("click", () => run(() => api("POST", "/poll")));
+document.getElementById("toggle-pause").addEventListener("click",
+  () => run(() => api("POST", paused ? "/orchestrator/resume" : "/orchestrator/pause")));
+
+refresh();
+setInterval(refresh, REFRESH_MS);
+
+// Pipeline events refresh the page as they happen rather than at the next tick.
+// EventSource reconnects on its own, resuming from the last event it saw.
+const stream = new EventSource(`${API}/events`, { withCredentials: true });
+for (const type of ["transfer.claimed", "transfer.download_failed", "transfer.downloaded",
+  "transfer.imported", "transfer.import_failed", "transfer.cleaned_up", "transfer.missing"]) {
+  stream.addEventListener(type, refresh);
+}
@@ -0,0 +1,27 @@
+// Package web serves the built-in dashboard: static files embedded in the
+// binary, which read and act on the pipeline through the native API. It holds no
+// state and talks to nothing but its own origin.
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler serves the dashboard's files. It is mounted behind the same key as the
+// API, which the dashboard's requests reuse through the browser's basic-auth
+// cache -- so no key is ever written into the page.
+func Handler() http.Handler {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		// static is embedded at build time; a missing directory fails the build,
+		// not this call.
+		panic(err)
+	}
+
+	return http.FileServer(http.FS(files))
+}
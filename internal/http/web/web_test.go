@@ -0,0 +1,26 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_ServesTheEmbeddedDashboard(t *testing.T) {
+	for path, want := range map[string]string{
+		"/":          "<title>Seedbox Downloader</title>",
+		"/app.js":    `const API = "../api/v1";`,
+		"/style.css": "--accent",
+	} {
+		t.Run(path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Body.String(), want)
+		})
+	}
+}
@@ -1,8 +1,7 @@
 package notifier
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
 )
@@ -30,54 +29,43 @@ type Notifier interface {
 
 type DiscordNotifier struct {
 	WebhookURL string
+	// Client sends the requests; nil is one with DefaultTimeout.
+	Client *http.Client
 }
 
 func (d *DiscordNotifier) Notify(content string) error {
-	if d.WebhookURL == "" {
-		return fmt.Errorf("webhook URL is not set")
-	}
+	return d.notify(context.Background(), content)
+}
 
-	payload := map[string]string{"content": content}
+// NotifyEmbed sends a Discord embed notification to the configured webhook URL.
+func (d *DiscordNotifier) NotifyEmbed(embed Embed) error {
+	return d.notifyEmbed(context.Background(), embed)
+}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
-	}
+// NotifyEventContext sends the event's embed, or its message, until ctx is done.
+func (d *DiscordNotifier) NotifyEventContext(ctx context.Context, event Event) error {
+	return sendEvent(ctx, event, d.notify, d.notifyEmbed)
+}
 
-	resp, err := http.Post(d.WebhookURL, "application/json", bytes.NewBuffer(body))
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+func (d *DiscordNotifier) notify(ctx context.Context, content string) error {
+	if d.WebhookURL == "" {
+		return fmt.Errorf("webhook URL is not set")
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("webhook failed with status %d", resp.StatusCode)
+	if err := postJSON(ctx, d.Client, d.WebhookURL, map[string]string{"content": content}, nil); err != nil {
+		return fmt.Errorf("discord: %w", err)
 	}
 
 	return nil
 }
 
-// NotifyEmbed sends a Discord embed notification to the configured webhook URL.
-func (d *DiscordNotifier) NotifyEmbed(embed Embed) error {
+func (d *DiscordNotifier) notifyEmbed(ctx context.Context, embed Embed) error {
 	if d.WebhookURL == "" {
 		return fmt.Errorf("webhook URL is not set")
 	}
 
-	payload := map[string][]Embed{"embeds": {embed}}
-
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal embed payload: %w", err)
-	}
-
-	resp, err := http.Post(d.WebhookURL, "application/json", bytes.NewBuffer(body))
-	if err != nil {
-		return fmt.Errorf("failed to send embed request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("embed webhook failed with status %d", resp.StatusCode)
+	if err := postJSON(ctx, d.Client, d.WebhookURL, map[string][]Embed{"embeds": {embed}}, nil); err != nil {
+		return fmt.Errorf("discord: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,160 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Embed colors, one for each stage of a transfer's life so that a channel of
+// notifications can be read at a glance.
+const (
+	ColorInfo     = 3447003  // 0x3498DB blue
+	ColorSuccess  = 3066993  // 0x2ECC71 green
+	ColorVerified = 1752220  // 0x1ABC9C teal
+	ColorImported = 10181046 // 0x9B59B6 purple
+	ColorSeeding  = 15844367 // 0xF1C40F yellow
+	ColorCleanup  = 9807270  // 0x95A5A6 grey
+	ColorFailure  = 15158332 // 0xE74C3C red
+	ColorLost     = 10038562 // 0x992D22 dark red
+)
+
+// EventEmbed describes event as an embed, which every notifier can render: the
+// title and color say what happened, and the fields, always in the same order,
+// whatever is known of the transfer.
+func EventEmbed(event Event) Embed {
+	embed := Embed{Fields: eventFields(event)}
+
+	if !event.Time.IsZero() {
+		embed.Timestamp = event.Time.UTC().Format(time.RFC3339)
+	}
+
+	switch event.Type {
+	case EventClaimed:
+		embed.Title, embed.Color = "Transfer Claimed", ColorInfo
+		embed.Description = "The transfer is queued for download."
+	case EventDownloaded:
+		embed.Title, embed.Color = "Download Finished", ColorSuccess
+		embed.Description = "Every file of the transfer has been downloaded."
+	case EventVerified:
+		embed.Title, embed.Color = "Download Verified", ColorVerified
+		embed.Description = "Every file is the size the seedbox reported."
+	case EventImported:
+		embed.Title, embed.Color = "Transfer Imported", ColorImported
+		embed.Description = "The transfer has been imported."
+
+		if event.Instance != "" {
+			embed.Description = "The transfer has been imported by " + event.Instance + "."
+		}
+	case EventSeedingComplete:
+		embed.Title, embed.Color = "Seeding Complete", ColorSeeding
+		embed.Description = "The transfer has reached its seed ratio."
+	case EventCleanupDone:
+		embed.Title, embed.Color = "Cleanup Done", ColorCleanup
+		embed.Description = "The transfer and its files have been removed from the seedbox."
+	case EventDownloadFailed:
+		embed.Title, embed.Color = "Download Failed", ColorFailure
+		embed.Description = "The transfer could not be downloaded."
+	case EventImportFailed:
+		embed.Title, embed.Color = "Import Failed", ColorFailure
+		embed.Description = importFailedDescription(event.Reason)
+	case EventMissing:
+		embed.Title, embed.Color = "Transfer Removed", ColorFailure
+		embed.Description = "This transfer was removed from Put.io before download could complete."
+
+		if event.Reason == "files_missing" {
+			embed.Title = "Transfer Files Missing"
+			embed.Description = "The files for this transfer were deleted from Put.io while download was in progress."
+		}
+	case EventDeadLettered:
+		embed.Title, embed.Color = "Notification Not Delivered", ColorLost
+		embed.Description = fmt.Sprintf("The %s notification could not be sent to %s and was given up on.",
+			strings.ReplaceAll(event.Reason, "_", " "), event.Target)
+	default:
+		embed.Title, embed.Color = digestName(event), ColorInfo
+		embed.Description = event.Message
+	}
+
+	return embed
+}
+
+func importFailedDescription(reason string) string {
+	switch reason {
+	case "download_ignored":
+		return "The *arr app was told to ignore this transfer."
+	case "timeout":
+		return "No *arr app imported this transfer in time."
+	default:
+		return "The *arr app will not import this transfer."
+	}
+}
+
+// eventFields lists what is known of event's transfer.
+func eventFields(event Event) []EmbedField {
+	var fields []EmbedField
+
+	add := func(name, value string, inline bool) {
+		if value != "" {
+			fields = append(fields, EmbedField{Name: name, Value: value, Inline: inline})
+		}
+	}
+
+	add("Transfer Name", event.TransferName, true)
+	add("Transfer ID", event.TransferID, true)
+	add("Label", event.Label, true)
+
+	if event.Size > 0 {
+		add("Size", humanize.Bytes(uint64(event.Size)), true)
+	}
+
+	if event.FileCount > 0 {
+		add("Files", fmt.Sprint(event.FileCount), true)
+	}
+
+	// How the download went only means something once it is over.
+	if event.Duration > 0 && (event.Type == EventDownloaded || event.Type == EventVerified) {
+		add("Duration", event.Duration.Round(time.Second).String(), true)
+
+		if event.Size > 0 {
+			add("Average Speed", humanize.Bytes(uint64(float64(event.Size)/event.Duration.Seconds()))+"/s", true)
+		}
+	}
+
+	add("Instance", event.Instance, true)
+
+	if event.Ratio > 0 {
+		add("Ratio", fmt.Sprintf("%.2f", event.Ratio), true)
+	}
+
+	if event.Type != EventDeadLettered {
+		add("Reason", event.Reason, true)
+	}
+
+	add("Target", event.Target, true)
+	add("Error", event.Error, false)
+
+	return fields
+}
+
+// DeadLetter is the event telling of event, given up on after it could not be
+// sent to target.
+func DeadLetter(target string, event Event, err error) Event {
+	lost := Event{
+		Type:         EventDeadLettered,
+		Time:         time.Now().UTC(),
+		TransferID:   event.TransferID,
+		TransferName: event.TransferName,
+		Label:        event.Label,
+		Size:         event.Size,
+		Reason:       event.Type,
+		Target:       target,
+	}
+
+	if err != nil {
+		lost.Error = err.Error()
+	}
+
+	return lost
+}
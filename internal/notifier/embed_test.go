@@ -0,0 +1,88 @@
+package notifier
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventEmbed_Downloaded(t *testing.T) {
+	embed := EventEmbed(Event{
+		Type: EventDownloaded, Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		TransferID: "42", TransferName: "Show.S01", Label: "sonarr",
+		Size: 3_000_000_000, FileCount: 8, Duration: 2 * time.Minute, LocalPath: "/downloads/Show.S01",
+	})
+
+	assert.Equal(t, "Download Finished", embed.Title)
+	assert.Equal(t, ColorSuccess, embed.Color)
+	assert.Equal(t, "2024-05-01T12:00:00Z", embed.Timestamp)
+	assert.Equal(t, []EmbedField{
+		{Name: "Transfer Name", Value: "Show.S01", Inline: true},
+		{Name: "Transfer ID", Value: "42", Inline: true},
+		{Name: "Label", Value: "sonarr", Inline: true},
+		{Name: "Size", Value: "3.0 GB", Inline: true},
+		{Name: "Files", Value: "8", Inline: true},
+		{Name: "Duration", Value: "2m0s", Inline: true},
+		{Name: "Average Speed", Value: "25 MB/s", Inline: true},
+	}, embed.Fields)
+}
+
+// Every event type has a title and color of its own kind, and the fields of
+// one are named as those of any other.
+func TestEventEmbed_EveryType(t *testing.T) {
+	for eventType := range eventTypes {
+		if eventType == EventMessage {
+			continue
+		}
+
+		embed := EventEmbed(Event{Type: eventType, TransferID: "42", TransferName: "Show.S01"})
+
+		assert.NotEmpty(t, embed.Title, eventType)
+		assert.NotEmpty(t, embed.Description, eventType)
+		assert.NotZero(t, embed.Color, eventType)
+		require.GreaterOrEqual(t, len(embed.Fields), 2, eventType)
+		assert.Equal(t, EmbedField{Name: "Transfer Name", Value: "Show.S01", Inline: true}, embed.Fields[0], eventType)
+		assert.Equal(t, EmbedField{Name: "Transfer ID", Value: "42", Inline: true}, embed.Fields[1], eventType)
+	}
+}
+
+func TestEventEmbed_Failures(t *testing.T) {
+	embed := EventEmbed(Event{Type: EventImportFailed, TransferName: "a", Instance: "sonarr", Reason: "timeout", Error: "no import"})
+	assert.Equal(t, ColorFailure, embed.Color)
+	assert.Equal(t, "No *arr app imported this transfer in time.", embed.Description)
+	assert.Contains(t, embed.Fields, EmbedField{Name: "Instance", Value: "sonarr", Inline: true})
+	assert.Equal(t, EmbedField{Name: "Error", Value: "no import"}, embed.Fields[len(embed.Fields)-1])
+
+	embed = EventEmbed(Event{Type: EventMissing, TransferName: "a", Reason: "files_missing"})
+	assert.Equal(t, "Transfer Files Missing", embed.Title)
+
+	embed = EventEmbed(Event{Type: EventImported, TransferName: "a", Instance: "radarr"})
+	assert.Equal(t, "The transfer has been imported by radarr.", embed.Description)
+}
+
+func TestDeadLetter(t *testing.T) {
+	lost := DeadLetter("slack", Event{Type: EventImported, TransferID: "42", TransferName: "a"}, errors.New("status 403"))
+
+	assert.Equal(t, EventDeadLettered, lost.Type)
+	assert.Equal(t, "42", lost.TransferID)
+	assert.Equal(t, "status 403", lost.Error)
+
+	embed := EventEmbed(lost)
+	assert.Equal(t, ColorLost, embed.Color)
+	assert.Equal(t, "The imported notification could not be sent to slack and was given up on.", embed.Description)
+	assert.NotContains(t, embed.Fields, EmbedField{Name: "Reason", Value: EventImported, Inline: true})
+}
+
+// Every backend renders the embed; the plain-text ones in their own markup.
+func TestEventEmbed_RendersEverywhere(t *testing.T) {
+	embed := EventEmbed(Event{Type: EventSeedingComplete, TransferID: "42", TransferName: "Show.S01", Ratio: 1.5})
+
+	assert.Equal(t, "Seeding Complete\n\nThe transfer has reached its seed ratio.\n"+
+		"Transfer Name: Show.S01\nTransfer ID: 42\nRatio: 1.50", plainText(embed))
+	assert.Contains(t, htmlText(embed), "<b>Ratio:</b> 1.50")
+	assert.Contains(t, markdownText(embed), "**Ratio:** 1.50")
+	assert.Equal(t, "#f1c40f", hexColor(embed.Color))
+}
@@ -0,0 +1,152 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Event types, as Event.Type names them.
+const (
+	EventClaimed         = "claimed"
+	EventDownloadFailed  = "download_failed"
+	EventDownloaded      = "downloaded"
+	EventVerified        = "verified"
+	EventImported        = "imported"
+	EventImportFailed    = "import_failed"
+	EventSeedingComplete = "seeding_complete"
+	EventCleanupDone     = "cleanup_done"
+	EventMissing         = "missing"
+	// EventDeadLettered is a notification given up on after it could not be
+	// sent to Target.
+	EventDeadLettered = "dead_lettered"
+	// EventMessage is a bare message, sent through Notify or NotifyEmbed rather
+	// than about anything in particular.
+	EventMessage = "message"
+	// EventDigest gathers the events a target held back, in Events.
+	EventDigest = "digest"
+)
+
+// eventTypes are the event types a target's rules may name.
+var eventTypes = map[string]bool{
+	EventClaimed:         true,
+	EventDownloadFailed:  true,
+	EventDownloaded:      true,
+	EventVerified:        true,
+	EventImported:        true,
+	EventImportFailed:    true,
+	EventSeedingComplete: true,
+	EventCleanupDone:     true,
+	EventMissing:         true,
+	EventDeadLettered:    true,
+	EventMessage:         true,
+}
+
+// Event is something that happened to a transfer, with everything known about
+// it. Notifiers that can make use of the detail implement EventNotifier; the
+// rest are sent Embed, or Message when there is no embed.
+type Event struct {
+	Type         string    `json:"type"`
+	Time         time.Time `json:"time"`
+	TransferID   string    `json:"transfer_id,omitempty"`
+	TransferName string    `json:"transfer_name,omitempty"`
+	// Label is the label the transfer was tagged with on the seedbox.
+	Label string `json:"label,omitempty"`
+	// Size is the transfer's size in bytes.
+	Size      int64  `json:"size,omitempty"`
+	FileCount int    `json:"file_count,omitempty"`
+	LocalPath string `json:"local_path,omitempty"`
+	// Duration is how long the download took.
+	Duration time.Duration `json:"-"`
+	Error    string        `json:"error,omitempty"`
+	// Instance is the *arr instance involved: the one that imported the
+	// transfer, or the one that refused it.
+	Instance string `json:"instance,omitempty"`
+	// Reason says why, on failures: why the import failed, or how the transfer
+	// went missing. A dead-lettered event has the type of the one lost.
+	Reason string `json:"reason,omitempty"`
+	// Ratio is the upload ratio the transfer was seeded to.
+	Ratio float64 `json:"ratio,omitempty"`
+	// Target is the target a dead-lettered notification was for.
+	Target string `json:"target,omitempty"`
+
+	Message string `json:"message,omitempty"`
+	Embed   *Embed `json:"embed,omitempty"`
+	// Events are the events a digest gathers.
+	Events []Event `json:"events,omitempty"`
+}
+
+// MarshalJSON writes Duration in seconds, which is what a service on the other
+// end can use.
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+
+	return json.Marshal(struct {
+		event
+		Duration float64 `json:"duration_seconds,omitempty"`
+	}{event(e), e.Duration.Seconds()})
+}
+
+// UnmarshalJSON reads an Event as MarshalJSON writes it.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	type event Event
+
+	var decoded struct {
+		event
+		Duration float64 `json:"duration_seconds"`
+	}
+
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*e = Event(decoded.event)
+	e.Duration = seconds2duration(decoded.Duration)
+
+	return nil
+}
+
+// EventNotifier is a notifier that describes events itself.
+type EventNotifier interface {
+	NotifyEvent(event Event) error
+}
+
+// ContextNotifier is a notifier whose requests a context cancels.
+type ContextNotifier interface {
+	NotifyEventContext(ctx context.Context, event Event) error
+}
+
+// Send notifies n of event: as the event itself if n takes events, and
+// otherwise as its embed or message.
+func Send(n Notifier, event Event) error {
+	if en, ok := n.(EventNotifier); ok {
+		return en.NotifyEvent(event)
+	}
+
+	if event.Embed != nil {
+		return n.NotifyEmbed(*event.Embed)
+	}
+
+	return n.Notify(event.Message)
+}
+
+// SendContext is Send, with ctx cancelling the request if n takes one.
+func SendContext(ctx context.Context, n Notifier, event Event) error {
+	if cn, ok := n.(ContextNotifier); ok {
+		return cn.NotifyEventContext(ctx, event)
+	}
+
+	return Send(n, event)
+}
+
+// sendEvent sends event as its embed, or its message if it has none, for a
+// notifier that describes no events itself.
+func sendEvent(
+	ctx context.Context, event Event, notify func(context.Context, string) error, notifyEmbed func(context.Context, Embed) error,
+) error {
+	if event.Embed != nil {
+		return notifyEmbed(ctx, *event.Embed)
+	}
+
+	return notify(ctx, event.Message)
+}
@@ -0,0 +1,70 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GotifyNotifier sends messages to a Gotify server as an application. Embeds
+// are sent as Markdown, which the Gotify clients render.
+type GotifyNotifier struct {
+	ServerURL string
+	// Token is the application token.
+	Token string
+	// Priority is the message priority; zero leaves it to the server's default.
+	Priority int
+	// Client sends the requests; nil is one with DefaultTimeout.
+	Client *http.Client
+}
+
+type gotifyMessage struct {
+	Title    string         `json:"title,omitempty"`
+	Message  string         `json:"message"`
+	Priority int            `json:"priority,omitempty"`
+	Extras   map[string]any `json:"extras,omitempty"`
+}
+
+func (g *GotifyNotifier) Notify(content string) error {
+	return g.notify(context.Background(), content)
+}
+
+// NotifyEmbed sends the embed with its title as the message's.
+func (g *GotifyNotifier) NotifyEmbed(embed Embed) error {
+	return g.notifyEmbed(context.Background(), embed)
+}
+
+// NotifyEventContext sends the event's embed, or its message, until ctx is done.
+func (g *GotifyNotifier) NotifyEventContext(ctx context.Context, event Event) error {
+	return sendEvent(ctx, event, g.notify, g.notifyEmbed)
+}
+
+func (g *GotifyNotifier) notify(ctx context.Context, content string) error {
+	return g.send(ctx, gotifyMessage{Message: content, Priority: g.Priority})
+}
+
+func (g *GotifyNotifier) notifyEmbed(ctx context.Context, embed Embed) error {
+	return g.send(ctx, gotifyMessage{
+		Title:    embed.Title,
+		Message:  markdownText(embed),
+		Priority: g.Priority,
+		Extras: map[string]any{
+			"client::display": map[string]string{"contentType": "text/markdown"},
+		},
+	})
+}
+
+func (g *GotifyNotifier) send(ctx context.Context, message gotifyMessage) error {
+	if g.ServerURL == "" || g.Token == "" {
+		return fmt.Errorf("server URL and application token are required")
+	}
+
+	header := http.Header{"X-Gotify-Key": {g.Token}}
+
+	if err := postJSON(ctx, g.Client, strings.TrimSuffix(g.ServerURL, "/")+"/message", message, header); err != nil {
+		return fmt.Errorf("gotify: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package notifier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGotify_NotifyEmbed(t *testing.T) {
+	gotify := newService(t)
+
+	n := &GotifyNotifier{ServerURL: gotify.URL, Token: "app-token", Priority: 8}
+	require.NoError(t, n.NotifyEmbed(testEmbed))
+
+	got := gotify.only(t)
+	assert.Equal(t, "/message", got.Path)
+	assert.Equal(t, "app-token", got.Header.Get("X-Gotify-Key"))
+	assert.JSONEq(t, `{
+		"title": "Download Failed",
+		"message": "Transfer <Show.S01E01> could not be downloaded\n**Transfer ID:** 42\n**Reason:** disk full",
+		"priority": 8,
+		"extras": {"client::display": {"contentType": "text/markdown"}}
+	}`, got.Body)
+}
@@ -0,0 +1,183 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds a request to a notification service when its target
+// sets no timeout of its own.
+const DefaultTimeout = 10 * time.Second
+
+var defaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// StatusError is a notification service answering with a status outside 2xx.
+type StatusError struct {
+	StatusCode int
+	// Body is the start of what the service said about it.
+	Body string
+	// RetryAfter is how long the service asked to be left alone for, when it
+	// said; it is set on rate limiting.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether the request may succeed if sent again: the service
+// was rate limiting, timed out or failed on its side. Anything else it refused
+// will be refused again.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode >= http.StatusInternalServerError
+}
+
+// Retryable reports whether a notification that failed with err is worth
+// sending again. Only a service refusing it says it is not; failing to reach the
+// service at all may pass.
+func Retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+
+	var permanent *permanentError
+
+	return !errors.As(err, &permanent)
+}
+
+// RetryAfter returns how long the service that failed with err asked to be
+// left alone for, or zero.
+func RetryAfter(err error) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+
+	return 0
+}
+
+// permanentError is a notification that cannot be sent as it is, such as a
+// template that fails to render.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// post sends body to rawURL and fails on any status outside 2xx with a
+// StatusError. ctx cancels the request.
+func post(ctx context.Context, client *http.Client, rawURL, contentType string, body io.Reader, header http.Header) error {
+	return send(ctx, client, http.MethodPost, rawURL, contentType, body, header)
+}
+
+// send is post with another method. A nil client is one with DefaultTimeout.
+func send(ctx context.Context, client *http.Client, method, rawURL, contentType string, body io.Reader, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return &permanentError{fmt.Errorf("failed to create request: %w", err)}
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	if client == nil {
+		client = defaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		reason, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return &StatusError{
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(reason)),
+			RetryAfter: retryAfter(resp.Header, reason),
+		}
+	}
+
+	return nil
+}
+
+// retryAfter reads how long a service asks to be left alone for: from the
+// Retry-After header, in seconds or as a date; from Discord's
+// X-RateLimit-Reset-After; or from a retry_after in the body, in seconds, as
+// Discord and Telegram send it.
+func retryAfter(header http.Header, body []byte) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			return seconds2duration(seconds)
+		}
+
+		if at, err := http.ParseTime(value); err == nil {
+			return max(time.Until(at), 0)
+		}
+	}
+
+	if value := header.Get("X-RateLimit-Reset-After"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			return seconds2duration(seconds)
+		}
+	}
+
+	var payload struct {
+		RetryAfter float64 `json:"retry_after"`
+		Parameters struct {
+			RetryAfter float64 `json:"retry_after"`
+		} `json:"parameters"`
+	}
+
+	if json.Unmarshal(body, &payload) == nil {
+		return seconds2duration(max(payload.RetryAfter, payload.Parameters.RetryAfter))
+	}
+
+	return 0
+}
+
+func seconds2duration(seconds float64) time.Duration {
+	return time.Duration(max(seconds, 0) * float64(time.Second))
+}
+
+// postJSON sends payload as JSON.
+func postJSON(ctx context.Context, client *http.Client, rawURL string, payload any, header http.Header) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return &permanentError{fmt.Errorf("failed to marshal payload: %w", err)}
+	}
+
+	return post(ctx, client, rawURL, "application/json", bytes.NewReader(body), header)
+}
+
+// postForm sends form URL-encoded.
+func postForm(ctx context.Context, client *http.Client, rawURL string, form url.Values) error {
+	return post(ctx, client, rawURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), nil)
+}
+
+// redactURL keeps secret, carried in the URL of a request, out of err.
+func redactURL(err error, secret string) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = strings.ReplaceAll(urlErr.URL, secret, "***")
+	}
+
+	return err
+}
@@ -0,0 +1,33 @@
+package notifier
+
+import (
+	"log/slog"
+	"time"
+)
+
+// LogNotifier logs notifications in place of sending them, for dry runs, so that
+// what each target would have been told can be seen without telling it.
+type LogNotifier struct {
+	Target string
+	Logger *slog.Logger
+}
+
+func (l *LogNotifier) Notify(content string) error {
+	return l.NotifyEvent(Event{Type: EventMessage, Time: time.Now().UTC(), Message: content})
+}
+
+func (l *LogNotifier) NotifyEmbed(embed Embed) error {
+	return l.NotifyEvent(Event{Type: EventMessage, Time: time.Now().UTC(), Message: plainText(embed), Embed: &embed})
+}
+
+func (l *LogNotifier) NotifyEvent(event Event) error {
+	message := event.Message
+	if message == "" && event.Embed != nil {
+		message = plainText(*event.Embed)
+	}
+
+	l.Logger.Info("dry run: would notify",
+		"component", "notifier", "target", l.Target, "event", event.Type, "transfer_id", event.TransferID, "message", message)
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package notifier
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogNotifier(t *testing.T) {
+	var out bytes.Buffer
+
+	n := &LogNotifier{Target: "discord", Logger: slog.New(slog.NewTextHandler(&out, nil))}
+
+	require.NoError(t, Send(n, Event{Type: EventDownloaded, TransferID: "42", Message: "✅ Download finished"}))
+
+	assert.Contains(t, out.String(), "dry run: would notify")
+	assert.Contains(t, out.String(), "target=discord event=downloaded transfer_id=42")
+	assert.Contains(t, out.String(), "Download finished")
+}
@@ -0,0 +1,72 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// NtfyNotifier publishes to an ntfy topic. Embeds are sent as Markdown.
+type NtfyNotifier struct {
+	// ServerURL is the ntfy server, such as https://ntfy.sh.
+	ServerURL string
+	Topic     string
+	// Token is an access token, for a topic that needs one.
+	Token string
+	// Client sends the requests; nil is one with DefaultTimeout.
+	Client *http.Client
+}
+
+type ntfyMessage struct {
+	Topic    string `json:"topic"`
+	Title    string `json:"title,omitempty"`
+	Message  string `json:"message"`
+	Markdown bool   `json:"markdown,omitempty"`
+}
+
+func (n *NtfyNotifier) Notify(content string) error {
+	return n.notify(context.Background(), content)
+}
+
+// NotifyEmbed publishes the embed with its title as the notification's.
+func (n *NtfyNotifier) NotifyEmbed(embed Embed) error {
+	return n.notifyEmbed(context.Background(), embed)
+}
+
+// NotifyEventContext publishes the event's embed, or its message, until ctx is
+// done.
+func (n *NtfyNotifier) NotifyEventContext(ctx context.Context, event Event) error {
+	return sendEvent(ctx, event, n.notify, n.notifyEmbed)
+}
+
+func (n *NtfyNotifier) notify(ctx context.Context, content string) error {
+	return n.publish(ctx, ntfyMessage{Topic: n.Topic, Message: content})
+}
+
+func (n *NtfyNotifier) notifyEmbed(ctx context.Context, embed Embed) error {
+	return n.publish(ctx, ntfyMessage{
+		Topic:    n.Topic,
+		Title:    embed.Title,
+		Message:  markdownText(embed),
+		Markdown: true,
+	})
+}
+
+func (n *NtfyNotifier) publish(ctx context.Context, message ntfyMessage) error {
+	if n.ServerURL == "" || n.Topic == "" {
+		return fmt.Errorf("server URL and topic are required")
+	}
+
+	var header http.Header
+	if n.Token != "" {
+		header = http.Header{"Authorization": {"Bearer " + n.Token}}
+	}
+
+	// Publishing as JSON goes to the server root, with the topic in the body.
+	if err := postJSON(ctx, n.Client, strings.TrimSuffix(n.ServerURL, "/"), message, header); err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package notifier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNtfy_NotifyEmbed(t *testing.T) {
+	ntfy := newService(t)
+
+	n := &NtfyNotifier{ServerURL: ntfy.URL + "/", Topic: "seedbox", Token: "tk_secret"}
+	require.NoError(t, n.NotifyEmbed(testEmbed))
+
+	got := ntfy.only(t)
+	assert.Equal(t, "/", got.Path)
+	assert.Equal(t, "Bearer tk_secret", got.Header.Get("Authorization"))
+	assert.JSONEq(t, `{
+		"topic": "seedbox",
+		"title": "Download Failed",
+		"message": "Transfer <Show.S01E01> could not be downloaded\n**Transfer ID:** 42\n**Reason:** disk full",
+		"markdown": true
+	}`, got.Body)
+}
+
+func TestNtfy_NoTokenNoAuthorization(t *testing.T) {
+	ntfy := newService(t)
+
+	require.NoError(t, (&NtfyNotifier{ServerURL: ntfy.URL, Topic: "seedbox"}).Notify("hello"))
+
+	got := ntfy.only(t)
+	assert.Empty(t, got.Header.Get("Authorization"))
+	assert.JSONEq(t, `{"topic": "seedbox", "message": "hello"}`, got.Body)
+}
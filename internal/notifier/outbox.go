@@ -0,0 +1,450 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/logctx"
+	"github.com/italolelis/seedbox_downloader/internal/storage"
+)
+
+const (
+	// DefaultOutboxSize is how many notifications may wait for one target before
+	// the oldest are dropped.
+	DefaultOutboxSize = 1000
+	// DefaultMaxAttempts is how many times a notification is tried before it is
+	// given up on.
+	DefaultMaxAttempts = 10
+
+	// storeRetryInterval is how long delivery waits after failing to read the
+	// outbox.
+	storeRetryInterval = 5 * time.Second
+	// waitInterval is how often Wait looks at the outbox.
+	waitInterval = 50 * time.Millisecond
+)
+
+// Recorder counts what becomes of notifications.
+type Recorder interface {
+	RecordNotification(ctx context.Context, target, outcome, reason string)
+}
+
+// Outbox sends notifications in the background, so that a slow or failing
+// service holds up neither the pipeline nor the other targets. Notifications
+// are stored until sent, surviving restarts, and each target's go out in order.
+// A notification the service could not take is tried again with exponential
+// backoff, or after as long as a rate-limited service asked for.
+type Outbox struct {
+	store       storage.NotificationOutbox
+	size        int
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	recorder    Recorder
+	deadLetter  func(target string, event Event, err error)
+
+	mu     sync.Mutex
+	queues map[string]*queue
+	// start starts delivery to a queue once Deliver has been called, and is nil
+	// before.
+	start func(*queue)
+}
+
+// OutboxOption configures an Outbox.
+type OutboxOption func(*Outbox)
+
+// WithOutboxSize bounds how many notifications wait for each target; beyond
+// it, the oldest are dropped.
+func WithOutboxSize(size int) OutboxOption {
+	return func(o *Outbox) {
+		o.size = size
+	}
+}
+
+// WithMaxAttempts gives up on a notification after attempts tries.
+func WithMaxAttempts(attempts int) OutboxOption {
+	return func(o *Outbox) {
+		o.maxAttempts = attempts
+	}
+}
+
+// WithBackoff waits from minimum after the first failed attempt, doubling up
+// to maximum.
+func WithBackoff(minimum, maximum time.Duration) OutboxOption {
+	return func(o *Outbox) {
+		o.minBackoff, o.maxBackoff = minimum, maximum
+	}
+}
+
+// WithRecorder counts delivered, retried and dropped notifications.
+func WithRecorder(recorder Recorder) OutboxOption {
+	return func(o *Outbox) {
+		o.recorder = recorder
+	}
+}
+
+// WithDeadLetter calls deadLetter with each notification given up on, but for
+// notifications that were themselves about one given up on, so that a target
+// that takes nothing cannot set off an endless run of them.
+func WithDeadLetter(deadLetter func(target string, event Event, err error)) OutboxOption {
+	return func(o *Outbox) {
+		o.deadLetter = deadLetter
+	}
+}
+
+func NewOutbox(store storage.NotificationOutbox, opts ...OutboxOption) *Outbox {
+	o := &Outbox{
+		store:       store,
+		size:        DefaultOutboxSize,
+		maxAttempts: DefaultMaxAttempts,
+		minBackoff:  2 * time.Second,
+		maxBackoff:  10 * time.Minute,
+		queues:      map[string]*queue{},
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// Queue returns a notifier that puts notifications in the outbox for target, a
+// name unique among the outbox's targets, to be sent on to n. Notifications
+// already waiting for target from before a restart are sent too.
+//
+// Queueing a target again, once it has been reconfigured, sends what waits for
+// it on to the new n. One queued after delivery has started is delivered to
+// straight away.
+func (o *Outbox) Queue(target string, n Notifier) Notifier {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if q, ok := o.queues[target]; ok {
+		q.setNotifier(n)
+
+		return q
+	}
+
+	q := &queue{outbox: o, target: target, notifier: n, wake: make(chan struct{}, 1)}
+	o.queues[target] = q
+
+	if o.start != nil {
+		o.start(q)
+	}
+
+	return q
+}
+
+// Deliver starts sending each target's notifications, until ctx is done.
+func (o *Outbox) Deliver(ctx context.Context) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.start = func(q *queue) {
+		queueCtx, stop := context.WithCancel(ctx)
+		q.stop = stop
+
+		go q.run(queueCtx)
+	}
+
+	for _, q := range o.queues {
+		o.start(q)
+	}
+}
+
+// Retain stops delivering to every target but those named, and drops what waits
+// for them, so that a target no longer configured is sent nothing more. It
+// returns the first error dropping any target's notifications, having tried
+// them all.
+func (o *Outbox) Retain(ctx context.Context, targets ...string) error {
+	keep := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		keep[target] = true
+	}
+
+	o.mu.Lock()
+
+	var removed []*queue
+
+	for target, q := range o.queues {
+		if !keep[target] {
+			removed = append(removed, q)
+			delete(o.queues, target)
+		}
+	}
+	o.mu.Unlock()
+
+	var errs []error
+
+	for _, q := range removed {
+		if q.stop != nil {
+			q.stop()
+		}
+
+		dropped, err := o.store.DropNotifications(q.target)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to drop notifications for %s: %w", q.target, err))
+
+			continue
+		}
+
+		for range dropped {
+			o.record(ctx, q.target, "dropped", "target_removed")
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Wait waits until nothing in the outbox is due to be sent: every notification
+// has been sent or given up on, or failed and waits to be tried again later. It
+// reports false if ctx is done first. A run that exits once its work is done
+// calls it, so that what it told of goes out before it does; anything left is
+// sent on the next start.
+func (o *Outbox) Wait(ctx context.Context) bool {
+	ticker := time.NewTicker(waitInterval)
+	defer ticker.Stop()
+
+	for !o.settled() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+
+	return true
+}
+
+// settled reports whether no target has a notification due.
+func (o *Outbox) settled() bool {
+	o.mu.Lock()
+	targets := make([]string, 0, len(o.queues))
+
+	for target := range o.queues {
+		targets = append(targets, target)
+	}
+	o.mu.Unlock()
+
+	for _, target := range targets {
+		msg, err := o.store.NextNotification(target)
+
+		switch {
+		case errors.Is(err, storage.ErrOutboxEmpty):
+		case err != nil, !msg.NextAttemptAt.After(time.Now()):
+			return false
+		}
+	}
+
+	return true
+}
+
+func (o *Outbox) record(ctx context.Context, target, outcome, reason string) {
+	if o.recorder != nil {
+		o.recorder.RecordNotification(ctx, target, outcome, reason)
+	}
+}
+
+// backoff is how long to wait after a notification's attempts-th failure.
+func (o *Outbox) backoff(attempts int) time.Duration {
+	wait := o.minBackoff
+	for i := 1; i < attempts && wait < o.maxBackoff; i++ {
+		wait *= 2
+	}
+
+	return min(wait, o.maxBackoff)
+}
+
+// queue is one target's side of the outbox.
+type queue struct {
+	outbox *Outbox
+	target string
+	// wake tells the delivery loop something was added.
+	wake chan struct{}
+	// stop stops the delivery loop, once Deliver has started it.
+	stop context.CancelFunc
+
+	mu       sync.Mutex
+	notifier Notifier
+}
+
+func (q *queue) setNotifier(n Notifier) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.notifier = n
+}
+
+func (q *queue) currentNotifier() Notifier {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.notifier
+}
+
+func (q *queue) Notify(content string) error {
+	return q.NotifyEvent(Event{Type: EventMessage, Time: time.Now().UTC(), Message: content})
+}
+
+func (q *queue) NotifyEmbed(embed Embed) error {
+	return q.NotifyEvent(Event{Type: EventMessage, Time: time.Now().UTC(), Message: plainText(embed), Embed: &embed})
+}
+
+// NotifyEvent stores event for delivery. It fails only when the outbox cannot
+// be written.
+func (q *queue) NotifyEvent(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	dropped, err := q.outbox.store.EnqueueNotification(q.target, payload, q.outbox.size)
+	if err != nil {
+		return fmt.Errorf("failed to queue notification for %s: %w", q.target, err)
+	}
+
+	for range dropped {
+		q.outbox.record(context.Background(), q.target, "dropped", "overflow")
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (q *queue) run(ctx context.Context) {
+	logger := logctx.LoggerFromContext(ctx).With("component", "notifier", "target", q.target)
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.ErrorContext(ctx, "notification delivery panic",
+				"operation", "notification_delivery",
+				"panic", r,
+				"stack", string(debug.Stack()))
+
+			if ctx.Err() == nil {
+				time.Sleep(time.Second)
+
+				go q.run(ctx)
+			}
+		}
+	}()
+
+	for {
+		msg, err := q.outbox.store.NextNotification(q.target)
+
+		switch {
+		case errors.Is(err, storage.ErrOutboxEmpty):
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.wake:
+			}
+
+			continue
+		case err != nil:
+			logger.ErrorContext(ctx, "failed to read notification outbox", "err", err)
+
+			if !sleep(ctx, storeRetryInterval) {
+				return
+			}
+
+			continue
+		}
+
+		if wait := time.Until(msg.NextAttemptAt); wait > 0 {
+			if !sleep(ctx, wait) {
+				return
+			}
+		}
+
+		q.deliver(ctx, logger, msg)
+	}
+}
+
+// deliver makes one attempt at sending msg, and deletes it or schedules the
+// next attempt.
+func (q *queue) deliver(ctx context.Context, logger *slog.Logger, msg storage.OutboxMessage) {
+	var event Event
+
+	err := json.Unmarshal(msg.Payload, &event)
+	if err == nil {
+		err = SendContext(ctx, q.currentNotifier(), event)
+	} else {
+		err = &permanentError{fmt.Errorf("unreadable notification: %w", err)}
+	}
+
+	// Delivery stopped mid-send, on shutdown or with the target removed. That is
+	// no attempt: the notification is sent on the next start, or dropped with
+	// its target.
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	outcome, reason := "delivered", ""
+
+	switch {
+	case err == nil:
+	case !Retryable(err):
+		outcome, reason = "dropped", "refused"
+	case msg.Attempts+1 >= q.outbox.maxAttempts:
+		outcome, reason = "dropped", "attempts"
+	default:
+		wait := q.outbox.backoff(msg.Attempts + 1)
+
+		reason = "failed"
+		if retryAfter := RetryAfter(err); retryAfter > 0 {
+			wait, reason = retryAfter, "rate_limited"
+		}
+
+		logger.WarnContext(ctx, "failed to send notification, will retry",
+			"event", event.Type, "transfer_id", event.TransferID, "attempt", msg.Attempts+1, "retry_in", wait, "err", err)
+
+		if err := q.outbox.store.RetryNotification(msg.ID, time.Now().Add(wait), err.Error()); err != nil {
+			logger.ErrorContext(ctx, "failed to reschedule notification", "err", err)
+			sleep(ctx, storeRetryInterval)
+		}
+
+		q.outbox.record(ctx, q.target, "retried", reason)
+
+		return
+	}
+
+	if outcome == "dropped" {
+		logger.ErrorContext(ctx, "giving up on notification",
+			"event", event.Type, "transfer_id", event.TransferID, "attempts", msg.Attempts+1, "reason", reason, "err", err)
+
+		if q.outbox.deadLetter != nil && event.Type != "" && event.Type != EventDeadLettered {
+			q.outbox.deadLetter(q.target, event, err)
+		}
+	}
+
+	if err := q.outbox.store.DeleteNotification(msg.ID); err != nil {
+		logger.ErrorContext(ctx, "failed to remove notification from outbox", "err", err)
+		sleep(ctx, storeRetryInterval)
+	}
+
+	q.outbox.record(ctx, q.target, outcome, reason)
+}
+
+// sleep waits for d, and reports false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
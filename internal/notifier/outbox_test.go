@@ -0,0 +1,428 @@
+package notifier
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memOutbox is an in-memory storage.NotificationOutbox.
+type memOutbox struct {
+	mu       sync.Mutex
+	nextID   int64
+	messages []storage.OutboxMessage
+	errors   []string
+}
+
+func (m *memOutbox) EnqueueNotification(target string, payload []byte, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	m.messages = append(m.messages, storage.OutboxMessage{ID: m.nextID, Target: target, Payload: payload, NextAttemptAt: time.Now()})
+
+	var dropped int
+
+	for limit > 0 && m.count(target) > limit {
+		for i, msg := range m.messages {
+			if msg.Target == target {
+				m.messages = append(m.messages[:i], m.messages[i+1:]...)
+				dropped++
+
+				break
+			}
+		}
+	}
+
+	return dropped, nil
+}
+
+func (m *memOutbox) count(target string) int {
+	var n int
+
+	for _, msg := range m.messages {
+		if msg.Target == target {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (m *memOutbox) NextNotification(target string) (storage.OutboxMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, msg := range m.messages {
+		if msg.Target == target {
+			return msg, nil
+		}
+	}
+
+	return storage.OutboxMessage{}, storage.ErrOutboxEmpty
+}
+
+func (m *memOutbox) RetryNotification(id int64, nextAttemptAt time.Time, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.messages {
+		if m.messages[i].ID == id {
+			m.messages[i].Attempts++
+			m.messages[i].NextAttemptAt = nextAttemptAt
+			m.errors = append(m.errors, lastError)
+		}
+	}
+
+	return nil
+}
+
+func (m *memOutbox) DeleteNotification(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.messages {
+		if m.messages[i].ID == id {
+			m.messages = append(m.messages[:i], m.messages[i+1:]...)
+
+			break
+		}
+	}
+
+	return nil
+}
+
+func (m *memOutbox) DropNotifications(target string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.messages[:0]
+
+	for _, msg := range m.messages {
+		if msg.Target != target {
+			kept = append(kept, msg)
+		}
+	}
+
+	dropped := len(m.messages) - len(kept)
+	m.messages = kept
+
+	return dropped, nil
+}
+
+func (m *memOutbox) waiting() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.messages)
+}
+
+// counts is a Recorder.
+type counts struct {
+	mu       sync.Mutex
+	outcomes []string
+}
+
+func (c *counts) RecordNotification(_ context.Context, target, outcome, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.outcomes = append(c.outcomes, target+":"+outcome+":"+reason)
+}
+
+func (c *counts) get() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	got := append([]string(nil), c.outcomes...)
+	sort.Strings(got)
+
+	return got
+}
+
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	return ctx
+}
+
+func TestOutbox_DeliversInTheBackground(t *testing.T) {
+	store, metrics, rec := &memOutbox{}, &counts{}, &recorder{}
+
+	outbox := NewOutbox(store, WithRecorder(metrics))
+	q := outbox.Queue("rec", rec)
+
+	require.NoError(t, Send(q, Event{Type: EventDownloaded, TransferName: "a", Duration: time.Minute}))
+	require.NoError(t, q.Notify("hello"))
+	assert.Empty(t, rec.sent(), "nothing is sent before delivery starts")
+
+	outbox.Deliver(testContext(t))
+
+	// The outcome is counted last, once the notification is out of the outbox.
+	require.Eventually(t, func() bool { return len(metrics.get()) == 2 }, time.Second, 5*time.Millisecond)
+
+	sent := rec.sent()
+	assert.Equal(t, "a", sent[0].TransferName)
+	assert.Equal(t, time.Minute, sent[0].Duration, "the event survives the outbox whole")
+	assert.Equal(t, "hello", sent[1].Message)
+	assert.Zero(t, store.waiting())
+	assert.Equal(t, []string{"rec:delivered:", "rec:delivered:"}, metrics.get())
+}
+
+// A rate-limited service is left alone for as long as it asks, and then sent
+// the notification again.
+func TestOutbox_HonoursRetryAfter(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []time.Time
+	)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests = append(requests, time.Now())
+
+		if len(requests) == 1 {
+			w.Header().Set("Retry-After", "0.2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	store, metrics := &memOutbox{}, &counts{}
+	outbox := NewOutbox(store, WithRecorder(metrics), WithBackoff(time.Hour, time.Hour))
+	require.NoError(t, outbox.Queue("slack", &SlackNotifier{WebhookURL: s.URL}).Notify("hello"))
+
+	outbox.Deliver(testContext(t))
+
+	require.Eventually(t, func() bool { return len(metrics.get()) == 2 }, 2*time.Second, 5*time.Millisecond)
+	assert.Zero(t, store.waiting())
+	assert.Equal(t, []string{"slack:delivered:", "slack:retried:rate_limited"}, metrics.get())
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Len(t, requests, 2)
+	assert.GreaterOrEqual(t, requests[1].Sub(requests[0]), 200*time.Millisecond)
+}
+
+func TestOutbox_DropsWhatCannotBeSent(t *testing.T) {
+	refusing, failing := newService(t), newService(t)
+	refusing.status, failing.status = http.StatusBadRequest, http.StatusBadGateway
+
+	store, metrics := &memOutbox{}, &counts{}
+	outbox := NewOutbox(store, WithRecorder(metrics), WithMaxAttempts(3), WithBackoff(time.Millisecond, time.Millisecond))
+
+	require.NoError(t, outbox.Queue("refusing", &SlackNotifier{WebhookURL: refusing.URL}).Notify("hello"))
+	require.NoError(t, outbox.Queue("failing", &SlackNotifier{WebhookURL: failing.URL}).Notify("hello"))
+
+	outbox.Deliver(testContext(t))
+
+	require.Eventually(t, func() bool { return len(metrics.get()) == 4 }, 2*time.Second, 5*time.Millisecond)
+	assert.Zero(t, store.waiting())
+	assert.Equal(t, []string{
+		"failing:dropped:attempts", "failing:retried:failed", "failing:retried:failed",
+		"refusing:dropped:refused",
+	}, metrics.get())
+}
+
+// Wait returns once every notification is sent or waits for a later attempt,
+// rather than for that attempt.
+func TestOutbox_Wait(t *testing.T) {
+	failing, rec := newService(t), &recorder{}
+	failing.status = http.StatusBadGateway
+
+	store := &memOutbox{}
+	outbox := NewOutbox(store, WithBackoff(time.Hour, time.Hour))
+
+	require.NoError(t, outbox.Queue("rec", rec).Notify("hello"))
+	require.NoError(t, outbox.Queue("failing", &SlackNotifier{WebhookURL: failing.URL}).Notify("hello"))
+
+	ctx, cancel := context.WithTimeout(testContext(t), 100*time.Millisecond)
+	defer cancel()
+
+	assert.False(t, outbox.Wait(ctx), "nothing is sent before delivery starts")
+
+	outbox.Deliver(testContext(t))
+
+	ctx, cancel = context.WithTimeout(testContext(t), 2*time.Second)
+	defer cancel()
+
+	require.True(t, outbox.Wait(ctx))
+	assert.Len(t, rec.sent(), 1)
+	assert.Equal(t, 1, store.waiting(), "the failed notification is kept for its next attempt")
+}
+
+// A notification given up on is handed on, but one about a notification given
+// up on is not.
+func TestOutbox_DeadLetters(t *testing.T) {
+	refusing := newService(t)
+	refusing.status = http.StatusForbidden
+
+	var (
+		mu   sync.Mutex
+		lost []Event
+	)
+
+	store, metrics := &memOutbox{}, &counts{}
+	outbox := NewOutbox(store, WithRecorder(metrics), WithDeadLetter(func(target string, event Event, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		lost = append(lost, DeadLetter(target, event, err))
+	}))
+
+	q := outbox.Queue("slack", &SlackNotifier{WebhookURL: refusing.URL})
+	require.NoError(t, Send(q, Event{Type: EventImported, TransferID: "42", TransferName: "a"}))
+	require.NoError(t, Send(q, DeadLetter("discord", Event{Type: EventDownloaded}, nil)))
+
+	outbox.Deliver(testContext(t))
+
+	require.Eventually(t, func() bool { return len(metrics.get()) == 2 }, time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Len(t, lost, 1)
+	assert.Equal(t, "slack", lost[0].Target)
+	assert.Equal(t, EventImported, lost[0].Reason)
+	assert.Equal(t, "42", lost[0].TransferID)
+	assert.Contains(t, lost[0].Error, "403")
+}
+
+// A target queued again is sent what waits for it from then on, and one queued
+// after delivery started is delivered to all the same.
+func TestOutbox_QueueAfterDeliver(t *testing.T) {
+	store, metrics := &memOutbox{}, &counts{}
+	before, after, added := &recorder{}, &recorder{}, &recorder{}
+
+	outbox := NewOutbox(store, WithRecorder(metrics))
+	outbox.Queue("rec", before)
+	outbox.Deliver(testContext(t))
+
+	require.NoError(t, outbox.Queue("rec", after).Notify("hello"))
+	require.NoError(t, outbox.Queue("added", added).Notify("hello"))
+
+	require.Eventually(t, func() bool { return len(metrics.get()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Empty(t, before.sent())
+	assert.Len(t, after.sent(), 1)
+	assert.Len(t, added.sent(), 1)
+}
+
+func TestOutbox_DropsTheOldestWhenFull(t *testing.T) {
+	store, metrics := &memOutbox{}, &counts{}
+	q := NewOutbox(store, WithRecorder(metrics), WithOutboxSize(2)).Queue("rec", &recorder{})
+
+	for range 3 {
+		require.NoError(t, q.Notify("hello"))
+	}
+
+	assert.Equal(t, 2, store.waiting())
+	assert.Equal(t, []string{"rec:dropped:overflow"}, metrics.get())
+}
+
+func TestRetryAfter(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		body   string
+		want   time.Duration
+	}{
+		{"seconds", http.Header{"Retry-After": {"3"}}, "", 3 * time.Second},
+		{"discord header", http.Header{"X-Ratelimit-Reset-After": {"1.5"}}, "", 1500 * time.Millisecond},
+		{"discord body", nil, `{"message": "You are being rate limited.", "retry_after": 0.25, "global": false}`, 250 * time.Millisecond},
+		{"telegram body", nil, `{"ok": false, "error_code": 429, "parameters": {"retry_after": 7}}`, 7 * time.Second},
+		{"nothing", nil, "slow down", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, retryAfter(tc.header, []byte(tc.body)))
+		})
+	}
+}
+
+// A target dropped from the configuration is sent nothing more: neither what
+// waits for it nor what is being retried. The targets kept carry on.
+func TestOutbox_RetainStopsAndDropsRemovedTargets(t *testing.T) {
+	removed, kept := newService(t), &recorder{}
+	removed.status = http.StatusBadGateway
+
+	store, metrics := &memOutbox{}, &counts{}
+	outbox := NewOutbox(store, WithRecorder(metrics), WithBackoff(time.Hour, time.Hour))
+
+	keptQueue, removedQueue := outbox.Queue("kept", kept), outbox.Queue("removed", &SlackNotifier{WebhookURL: removed.URL})
+	require.NoError(t, removedQueue.Notify("retried"))
+
+	outbox.Deliver(testContext(t))
+
+	require.Eventually(t, func() bool { return len(metrics.get()) == 1 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, removedQueue.Notify("waiting"))
+
+	require.NoError(t, outbox.Retain(testContext(t), "kept"))
+	assert.Zero(t, store.waiting(), "what waited for the removed target is dropped")
+	assert.Equal(t, []string{"removed:dropped:target_removed", "removed:dropped:target_removed", "removed:retried:failed"}, metrics.get())
+
+	require.NoError(t, keptQueue.Notify("hello"))
+	require.Eventually(t, func() bool { return len(kept.sent()) == 1 }, time.Second, 5*time.Millisecond)
+	removed.only(t)
+}
+
+// Stopping delivery cancels a request the service is holding, rather than
+// waiting out its timeout, and counts it as no attempt: the notification waits
+// for the next start.
+func TestOutbox_StoppingCancelsARequestInFlight(t *testing.T) {
+	received, cancelled, release := make(chan struct{}), make(chan struct{}), make(chan struct{})
+
+	hanging := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		close(received)
+
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-release:
+		}
+	}))
+	t.Cleanup(hanging.Close)
+	t.Cleanup(func() { close(release) })
+
+	store, metrics := &memOutbox{}, &counts{}
+	outbox := NewOutbox(store, WithRecorder(metrics))
+
+	require.NoError(t, outbox.Queue("slow", &SlackNotifier{WebhookURL: hanging.URL}).Notify("hello"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	outbox.Deliver(ctx)
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("the notification was never sent")
+	}
+
+	cancel()
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the request outlived delivery")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, metrics.get(), "a cancelled send is no attempt")
+	assert.Equal(t, 1, store.waiting())
+}
@@ -0,0 +1,78 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultPushoverURL is Pushover's message API.
+const DefaultPushoverURL = "https://api.pushover.net/1/messages.json"
+
+// PushoverNotifier sends messages to a Pushover user or group. Embeds are
+// rendered in Pushover's HTML.
+type PushoverNotifier struct {
+	// Token is the application's API token.
+	Token string
+	// User is the user or group key to notify.
+	User string
+	// APIURL overrides DefaultPushoverURL.
+	APIURL string
+	// Client sends the requests; nil is one with DefaultTimeout.
+	Client *http.Client
+}
+
+func (p *PushoverNotifier) Notify(content string) error {
+	return p.notify(context.Background(), content)
+}
+
+// NotifyEmbed sends the embed with its title as the message's.
+func (p *PushoverNotifier) NotifyEmbed(embed Embed) error {
+	return p.notifyEmbed(context.Background(), embed)
+}
+
+// NotifyEventContext sends the event's embed, or its message, until ctx is done.
+func (p *PushoverNotifier) NotifyEventContext(ctx context.Context, event Event) error {
+	return sendEvent(ctx, event, p.notify, p.notifyEmbed)
+}
+
+func (p *PushoverNotifier) notify(ctx context.Context, content string) error {
+	return p.send(ctx, url.Values{"message": {content}})
+}
+
+func (p *PushoverNotifier) notifyEmbed(ctx context.Context, embed Embed) error {
+	form := url.Values{
+		"title":   {embed.Title},
+		"message": {htmlText(Embed{Description: embed.Description, Fields: embed.Fields})},
+		"html":    {"1"},
+	}
+
+	if ts, err := time.Parse(time.RFC3339, embed.Timestamp); err == nil {
+		form.Set("timestamp", strconv.FormatInt(ts.Unix(), 10))
+	}
+
+	return p.send(ctx, form)
+}
+
+func (p *PushoverNotifier) send(ctx context.Context, form url.Values) error {
+	if p.Token == "" || p.User == "" {
+		return fmt.Errorf("application token and user key are required")
+	}
+
+	form.Set("token", p.Token)
+	form.Set("user", p.User)
+
+	apiURL := p.APIURL
+	if apiURL == "" {
+		apiURL = DefaultPushoverURL
+	}
+
+	if err := postForm(ctx, p.Client, apiURL, form); err != nil {
+		return fmt.Errorf("pushover: %w", err)
+	}
+
+	return nil
+}
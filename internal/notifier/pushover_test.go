@@ -0,0 +1,31 @@
+package notifier
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushover_NotifyEmbed(t *testing.T) {
+	pushover := newService(t)
+
+	n := &PushoverNotifier{Token: "app-token", User: "user-key", APIURL: pushover.URL + "/1/messages.json"}
+	require.NoError(t, n.NotifyEmbed(testEmbed))
+
+	got := pushover.only(t)
+	assert.Equal(t, "/1/messages.json", got.Path)
+	assert.Equal(t, "application/x-www-form-urlencoded", got.Header.Get("Content-Type"))
+
+	form, err := url.ParseQuery(got.Body)
+	require.NoError(t, err)
+	assert.Equal(t, url.Values{
+		"token":     {"app-token"},
+		"user":      {"user-key"},
+		"title":     {"Download Failed"},
+		"message":   {"Transfer &lt;Show.S01E01&gt; could not be downloaded\n<b>Transfer ID:</b> 42\n<b>Reason:</b> disk full"},
+		"html":      {"1"},
+		"timestamp": {"1704164645"},
+	}, form)
+}
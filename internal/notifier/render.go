@@ -0,0 +1,63 @@
+package notifier
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// The renderings below carry an Embed to services without embeds of their own:
+// the title, the description, then each field on a line of its own.
+
+// plainText renders embed without markup, title included.
+func plainText(embed Embed) string {
+	var b strings.Builder
+
+	if embed.Title != "" {
+		fmt.Fprintf(&b, "%s\n\n", embed.Title)
+	}
+
+	b.WriteString(embed.Description)
+
+	for _, field := range embed.Fields {
+		fmt.Fprintf(&b, "\n%s: %s", field.Name, field.Value)
+	}
+
+	return b.String()
+}
+
+// markdownText renders embed's body in Markdown, the title left to the service.
+func markdownText(embed Embed) string {
+	var b strings.Builder
+
+	b.WriteString(embed.Description)
+
+	for _, field := range embed.Fields {
+		fmt.Fprintf(&b, "\n**%s:** %s", field.Name, field.Value)
+	}
+
+	return strings.TrimPrefix(b.String(), "\n")
+}
+
+// htmlText renders embed in the handful of HTML tags Telegram and Pushover take,
+// title included.
+func htmlText(embed Embed) string {
+	var b strings.Builder
+
+	if embed.Title != "" {
+		fmt.Fprintf(&b, "<b>%s</b>\n", html.EscapeString(embed.Title))
+	}
+
+	b.WriteString(html.EscapeString(embed.Description))
+
+	for _, field := range embed.Fields {
+		fmt.Fprintf(&b, "\n<b>%s:</b> %s", html.EscapeString(field.Name), html.EscapeString(field.Value))
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// hexColor renders an embed color as "#rrggbb".
+func hexColor(color int) string {
+	return fmt.Sprintf("#%06x", color&0xffffff)
+}
@@ -0,0 +1,358 @@
+package notifier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// maxDigestFields is how many events a digest lists; Discord allows no more
+// fields in an embed.
+const maxDigestFields = 25
+
+// Rules decide which events a target is sent, and when.
+type Rules struct {
+	// Events are the event types sent; empty sends every type.
+	Events []string
+	// Labels are the transfer labels sent; empty sends every label.
+	Labels []string
+	// MinSize leaves out transfers smaller than this many bytes.
+	MinSize int64
+	// Quiet holds events back while it lasts and sends them, as one digest, when
+	// it ends.
+	Quiet *QuietHours
+	// Digest holds events back for this long from the first of them and sends
+	// them as one digest.
+	Digest time.Duration
+}
+
+// Rules reads the target's routing: its events, labels, min_size, quiet_hours
+// and digest.
+func (t Target) Rules() (Rules, error) {
+	rules := Rules{Labels: t.Labels}
+
+	for _, eventType := range t.Events {
+		if !eventTypes[eventType] {
+			return Rules{}, fmt.Errorf("notification target %q: unknown event type %q", t.Label(), eventType)
+		}
+
+		rules.Events = append(rules.Events, eventType)
+	}
+
+	if t.MinSize != "" {
+		size, err := humanize.ParseBytes(t.MinSize)
+		if err != nil {
+			return Rules{}, fmt.Errorf("notification target %q: invalid min_size: %w", t.Label(), err)
+		}
+
+		rules.MinSize = int64(size)
+	}
+
+	if t.QuietHours != "" {
+		quiet, err := ParseQuietHours(t.QuietHours)
+		if err != nil {
+			return Rules{}, fmt.Errorf("notification target %q: %w", t.Label(), err)
+		}
+
+		rules.Quiet = &quiet
+	}
+
+	if t.Digest != "" {
+		digest, err := time.ParseDuration(t.Digest)
+		if err != nil || digest <= 0 {
+			return Rules{}, fmt.Errorf("notification target %q: invalid digest window %q", t.Label(), t.Digest)
+		}
+
+		rules.Digest = digest
+	}
+
+	return rules, nil
+}
+
+// Empty reports whether the rules send every event as it happens.
+func (r Rules) Empty() bool {
+	return len(r.Events) == 0 && len(r.Labels) == 0 && r.MinSize == 0 && r.Quiet == nil && r.Digest == 0
+}
+
+// Match reports whether event is one the target is sent. Labels and size only
+// apply to events about a transfer.
+func (r Rules) Match(event Event) bool {
+	if len(r.Events) > 0 && !contains(r.Events, event.Type) {
+		return false
+	}
+
+	if event.TransferID == "" {
+		return true
+	}
+
+	if len(r.Labels) > 0 && !contains(r.Labels, event.Label) {
+		return false
+	}
+
+	return event.Size >= r.MinSize
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// QuietHours is a daily stretch of time, in local time, that may run past
+// midnight.
+type QuietHours struct {
+	// Start and End are times of day, as time since midnight.
+	Start, End time.Duration
+}
+
+// ParseQuietHours reads quiet hours written as 22:00-07:00.
+func ParseQuietHours(s string) (QuietHours, error) {
+	start, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours %q: want HH:MM-HH:MM", s)
+	}
+
+	var (
+		q   QuietHours
+		err error
+	)
+
+	if q.Start, err = parseTimeOfDay(start); err != nil {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours %q: %w", s, err)
+	}
+
+	if q.End, err = parseTimeOfDay(end); err != nil {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours %q: %w", s, err)
+	}
+
+	if q.Start == q.End {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours %q: they start as they end", s)
+	}
+
+	return q, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day", s)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls within the quiet hours.
+func (q QuietHours) Contains(t time.Time) bool {
+	now := sinceMidnight(t)
+
+	if q.Start < q.End {
+		return now >= q.Start && now < q.End
+	}
+
+	return now >= q.Start || now < q.End
+}
+
+// Until returns how long from t the quiet hours next end.
+func (q QuietHours) Until(t time.Time) time.Duration {
+	wait := q.End - sinceMidnight(t)
+	if wait <= 0 {
+		wait += 24 * time.Hour
+	}
+
+	return wait
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
+
+// Routed is a notifier sent only the events its rules let through, some of
+// them held back and sent later as one digest.
+type Routed struct {
+	notifier Notifier
+	rules    Rules
+	// onError is told when sending held events fails, which happens on a timer
+	// with no caller to return the error to.
+	onError func(error)
+	now     func() time.Time
+
+	mu      sync.Mutex
+	pending []Event
+	timer   *time.Timer
+}
+
+// NewRouted has rules decide what n is sent.
+func NewRouted(n Notifier, rules Rules, onError func(error)) *Routed {
+	return &Routed{notifier: n, rules: rules, onError: onError, now: time.Now}
+}
+
+func (r *Routed) Notify(content string) error {
+	return r.NotifyEvent(Event{Type: EventMessage, Time: r.now().UTC(), Message: content})
+}
+
+func (r *Routed) NotifyEmbed(embed Embed) error {
+	return r.NotifyEvent(Event{Type: EventMessage, Time: r.now().UTC(), Message: plainText(embed), Embed: &embed})
+}
+
+// NotifyEvent sends event if the rules let it through: straight away, or held
+// until the digest window or the quiet hours end.
+func (r *Routed) NotifyEvent(event Event) error {
+	if !r.rules.Match(event) {
+		return nil
+	}
+
+	now := r.now()
+	quiet := r.rules.Quiet != nil && r.rules.Quiet.Contains(now)
+
+	if !quiet && r.rules.Digest == 0 {
+		return Send(r.notifier, event)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending = append(r.pending, event)
+
+	if r.timer == nil {
+		wait := r.rules.Digest
+		if quiet {
+			wait = max(wait, r.rules.Quiet.Until(now))
+		}
+
+		r.timer = time.AfterFunc(wait, r.flushHeld)
+	}
+
+	return nil
+}
+
+// flushHeld sends the held events when their time is up, unless that has come
+// in the middle of quiet hours, when they wait for the end of them.
+func (r *Routed) flushHeld() {
+	r.mu.Lock()
+
+	r.timer = nil
+
+	if now := r.now(); r.rules.Quiet != nil && r.rules.Quiet.Contains(now) && len(r.pending) > 0 {
+		r.timer = time.AfterFunc(r.rules.Quiet.Until(now), r.flushHeld)
+		r.mu.Unlock()
+
+		return
+	}
+
+	r.mu.Unlock()
+
+	if err := r.Flush(); err != nil && r.onError != nil {
+		r.onError(err)
+	}
+}
+
+// Flusher is a notifier holding events back, which can be made to send them.
+type Flusher interface {
+	Flush() error
+}
+
+// Flush sends the held events now, as one digest.
+func (r *Routed) Flush() error {
+	r.mu.Lock()
+
+	held := r.pending
+	r.pending = nil
+
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+
+	r.mu.Unlock()
+
+	switch len(held) {
+	case 0:
+		return nil
+	case 1:
+		return Send(r.notifier, held[0])
+	default:
+		return Send(r.notifier, Digest(held, r.now()))
+	}
+}
+
+// Digest gathers events into one, summed up in its message and embed.
+func Digest(events []Event, at time.Time) Event {
+	counts := map[string]int{}
+	for _, event := range events {
+		counts[event.Type]++
+	}
+
+	types := make([]string, 0, len(counts))
+	for eventType := range counts {
+		types = append(types, eventType)
+	}
+
+	sort.Slice(types, func(i, j int) bool {
+		if counts[types[i]] != counts[types[j]] {
+			return counts[types[i]] > counts[types[j]]
+		}
+
+		return types[i] < types[j]
+	})
+
+	summary := make([]string, 0, len(types))
+	for _, eventType := range types {
+		summary = append(summary, fmt.Sprintf("%d %s", counts[eventType], strings.ReplaceAll(eventType, "_", " ")))
+	}
+
+	embed := Embed{
+		Title:       fmt.Sprintf("%d notifications", len(events)),
+		Description: strings.Join(summary, ", "),
+		Color:       ColorInfo,
+		Timestamp:   at.UTC().Format(time.RFC3339),
+	}
+
+	for i, event := range events {
+		if i == maxDigestFields-1 && len(events) > maxDigestFields {
+			embed.Fields = append(embed.Fields, EmbedField{Name: "…", Value: fmt.Sprintf("and %d more", len(events)-i)})
+
+			break
+		}
+
+		embed.Fields = append(embed.Fields, EmbedField{Name: digestName(event), Value: digestValue(event)})
+	}
+
+	return Event{
+		Type:    EventDigest,
+		Time:    at.UTC(),
+		Message: plainText(embed),
+		Embed:   &embed,
+		Events:  events,
+	}
+}
+
+func digestName(event Event) string {
+	name := strings.ReplaceAll(event.Type, "_", " ")
+	if name == "" {
+		return "Event"
+	}
+
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// digestValue is the transfer's name, or what a message says.
+func digestValue(event Event) string {
+	switch {
+	case event.TransferName != "":
+		return event.TransferName
+	case event.Embed != nil && event.Embed.Title != "":
+		return event.Embed.Title
+	default:
+		first, _, _ := strings.Cut(event.Message, "\n")
+
+		return first
+	}
+}
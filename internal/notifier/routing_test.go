@@ -0,0 +1,183 @@
+package notifier
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a notifier that keeps what it is sent.
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) Notify(content string) error {
+	return r.NotifyEvent(Event{Type: EventMessage, Message: content})
+}
+
+func (r *recorder) NotifyEmbed(embed Embed) error {
+	return r.NotifyEvent(Event{Type: EventMessage, Embed: &embed})
+}
+
+func (r *recorder) NotifyEvent(event Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+
+	return nil
+}
+
+func (r *recorder) sent() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Event(nil), r.events...)
+}
+
+func transferEvent(eventType, name, label string, size int64) Event {
+	return Event{Type: eventType, TransferID: name, TransferName: name, Label: label, Size: size}
+}
+
+func TestTarget_Rules(t *testing.T) {
+	rules, err := Target{
+		Type: "slack", Events: []string{"downloaded", "import_failed"}, Labels: []string{"sonarr"},
+		MinSize: "1GB", QuietHours: "22:30-07:00", Digest: "1h",
+	}.Rules()
+	require.NoError(t, err)
+	assert.Equal(t, Rules{
+		Events:  []string{"downloaded", "import_failed"},
+		Labels:  []string{"sonarr"},
+		MinSize: 1_000_000_000,
+		Quiet:   &QuietHours{Start: 22*time.Hour + 30*time.Minute, End: 7 * time.Hour},
+		Digest:  time.Hour,
+	}, rules)
+
+	rules, err = Target{Type: "slack"}.Rules()
+	require.NoError(t, err)
+	assert.True(t, rules.Empty())
+
+	for _, bad := range []Target{
+		{Name: "t", Events: []string{"exploded"}},
+		{Name: "t", MinSize: "lots"},
+		{Name: "t", QuietHours: "22:00"},
+		{Name: "t", QuietHours: "25:00-07:00"},
+		{Name: "t", QuietHours: "07:00-07:00"},
+		{Name: "t", Digest: "soon"},
+		{Name: "t", Digest: "-1h"},
+	} {
+		_, err := bad.Rules()
+		require.ErrorContains(t, err, `notification target "t"`, "%+v", bad)
+	}
+}
+
+func TestRules_Match(t *testing.T) {
+	rules := Rules{Events: []string{"downloaded", "message"}, Labels: []string{"Sonarr"}, MinSize: 100}
+
+	assert.True(t, rules.Match(transferEvent(EventDownloaded, "a", "sonarr", 100)))
+	assert.False(t, rules.Match(transferEvent(EventImported, "a", "sonarr", 100)), "event type")
+	assert.False(t, rules.Match(transferEvent(EventDownloaded, "a", "radarr", 100)), "label")
+	assert.False(t, rules.Match(transferEvent(EventDownloaded, "a", "sonarr", 99)), "size")
+	assert.True(t, rules.Match(Event{Type: EventMessage, Message: "hello"}), "labels and size are for transfers")
+}
+
+func TestQuietHours(t *testing.T) {
+	overnight := QuietHours{Start: 22 * time.Hour, End: 7 * time.Hour}
+	at := func(hour, minute int) time.Time { return time.Date(2024, 1, 2, hour, minute, 0, 0, time.Local) }
+
+	assert.True(t, overnight.Contains(at(23, 0)))
+	assert.True(t, overnight.Contains(at(3, 0)))
+	assert.False(t, overnight.Contains(at(7, 0)))
+	assert.False(t, overnight.Contains(at(12, 0)))
+	assert.Equal(t, 8*time.Hour, overnight.Until(at(23, 0)))
+	assert.Equal(t, 4*time.Hour+30*time.Minute, overnight.Until(at(2, 30)))
+
+	afternoon := QuietHours{Start: 13 * time.Hour, End: 15 * time.Hour}
+	assert.True(t, afternoon.Contains(at(14, 0)))
+	assert.False(t, afternoon.Contains(at(23, 0)))
+}
+
+func TestRouted_SendsMatchingEventsStraightAway(t *testing.T) {
+	rec := &recorder{}
+	routed := NewRouted(rec, Rules{Events: []string{EventDownloadFailed}}, nil)
+
+	require.NoError(t, routed.NotifyEvent(transferEvent(EventDownloaded, "a", "", 0)))
+	require.NoError(t, routed.NotifyEvent(transferEvent(EventDownloadFailed, "b", "", 0)))
+
+	sent := rec.sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "b", sent[0].TransferName)
+}
+
+func TestRouted_Digest(t *testing.T) {
+	rec := &recorder{}
+	routed := NewRouted(rec, Rules{Digest: 50 * time.Millisecond}, nil)
+
+	require.NoError(t, routed.NotifyEvent(transferEvent(EventDownloaded, "a", "", 0)))
+	require.NoError(t, routed.NotifyEvent(transferEvent(EventDownloaded, "b", "", 0)))
+	require.NoError(t, routed.NotifyEvent(transferEvent(EventImported, "a", "", 0)))
+	assert.Empty(t, rec.sent(), "held for the window")
+
+	require.Eventually(t, func() bool { return len(rec.sent()) == 1 }, time.Second, 10*time.Millisecond)
+
+	digest := rec.sent()[0]
+	assert.Equal(t, EventDigest, digest.Type)
+	assert.Len(t, digest.Events, 3)
+	require.NotNil(t, digest.Embed)
+	assert.Equal(t, "3 notifications", digest.Embed.Title)
+	assert.Equal(t, "2 downloaded, 1 imported", digest.Embed.Description)
+	assert.Equal(t, []EmbedField{{Name: "Downloaded", Value: "a"}, {Name: "Downloaded", Value: "b"}, {Name: "Imported", Value: "a"}},
+		digest.Embed.Fields)
+}
+
+func TestRouted_QuietHours(t *testing.T) {
+	rec := &recorder{}
+	routed := NewRouted(rec, Rules{Quiet: &QuietHours{Start: 22 * time.Hour, End: 7 * time.Hour}}, nil)
+
+	now := time.Date(2024, 1, 2, 23, 0, 0, 0, time.Local)
+	routed.now = func() time.Time { return now }
+
+	require.NoError(t, routed.NotifyEvent(transferEvent(EventDownloaded, "a", "", 0)))
+	assert.Empty(t, rec.sent(), "held until morning")
+
+	// Quiet hours over, events pass straight through again and what was held is
+	// sent on its own, as there is only the one.
+	now = time.Date(2024, 1, 3, 7, 0, 0, 0, time.Local)
+
+	require.NoError(t, routed.NotifyEvent(transferEvent(EventDownloaded, "b", "", 0)))
+	require.NoError(t, routed.Flush())
+
+	sent := rec.sent()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "b", sent[0].TransferName)
+	assert.Equal(t, "a", sent[1].TransferName)
+}
+
+func TestDigest_ListsAtMostAnEmbedsWorth(t *testing.T) {
+	events := make([]Event, 30)
+	for i := range events {
+		events[i] = transferEvent(EventDownloaded, "t", "", 0)
+	}
+
+	digest := Digest(events, time.Now())
+	require.Len(t, digest.Embed.Fields, maxDigestFields)
+	assert.Equal(t, "and 6 more", digest.Embed.Fields[maxDigestFields-1].Value)
+}
+
+// Through Multi, a routed target takes events while an unrouted one takes
+// everything.
+func TestMulti_Flush(t *testing.T) {
+	all, held := &recorder{}, &recorder{}
+	multi := Multi{all, NewRouted(held, Rules{Digest: time.Hour}, nil)}
+
+	require.NoError(t, multi.NotifyEvent(transferEvent(EventDownloaded, "a", "", 0)))
+	assert.Len(t, all.sent(), 1)
+	assert.Empty(t, held.sent())
+
+	require.NoError(t, multi.Flush())
+	assert.Len(t, held.sent(), 1)
+}
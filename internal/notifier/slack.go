@@ -0,0 +1,86 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SlackNotifier posts to a Slack incoming webhook. Embeds become attachments,
+// which keep the colored bar and the side-by-side fields.
+type SlackNotifier struct {
+	WebhookURL string
+	// Client sends the requests; nil is one with DefaultTimeout.
+	Client *http.Client
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color,omitempty"`
+	Title  string       `json:"title,omitempty"`
+	Text   string       `json:"text,omitempty"`
+	Fields []slackField `json:"fields,omitempty"`
+	// TS is the time the attachment is about, in Unix seconds.
+	TS int64 `json:"ts,omitempty"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func (s *SlackNotifier) Notify(content string) error {
+	return s.notify(context.Background(), content)
+}
+
+// NotifyEmbed sends the embed as a Slack attachment.
+func (s *SlackNotifier) NotifyEmbed(embed Embed) error {
+	return s.notifyEmbed(context.Background(), embed)
+}
+
+// NotifyEventContext sends the event's embed, or its message, until ctx is done.
+func (s *SlackNotifier) NotifyEventContext(ctx context.Context, event Event) error {
+	return sendEvent(ctx, event, s.notify, s.notifyEmbed)
+}
+
+func (s *SlackNotifier) notify(ctx context.Context, content string) error {
+	if s.WebhookURL == "" {
+		return fmt.Errorf("webhook URL is not set")
+	}
+
+	if err := postJSON(ctx, s.Client, s.WebhookURL, map[string]string{"text": content}, nil); err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SlackNotifier) notifyEmbed(ctx context.Context, embed Embed) error {
+	if s.WebhookURL == "" {
+		return fmt.Errorf("webhook URL is not set")
+	}
+
+	attachment := slackAttachment{
+		Color: hexColor(embed.Color),
+		Title: embed.Title,
+		Text:  embed.Description,
+	}
+
+	for _, field := range embed.Fields {
+		attachment.Fields = append(attachment.Fields, slackField{Title: field.Name, Value: field.Value, Short: field.Inline})
+	}
+
+	if ts, err := time.Parse(time.RFC3339, embed.Timestamp); err == nil {
+		attachment.TS = ts.Unix()
+	}
+
+	// Slack shows text only in notifications, so the title doubles as it.
+	payload := map[string]any{"text": embed.Title, "attachments": []slackAttachment{attachment}}
+
+	if err := postJSON(ctx, s.Client, s.WebhookURL, payload, nil); err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+
+	return nil
+}
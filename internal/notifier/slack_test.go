@@ -0,0 +1,47 @@
+package notifier
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlack_NotifyEmbed(t *testing.T) {
+	slack := newService(t)
+
+	require.NoError(t, (&SlackNotifier{WebhookURL: slack.URL + "/services/T/B/X"}).NotifyEmbed(testEmbed))
+
+	got := slack.only(t)
+	assert.Equal(t, "/services/T/B/X", got.Path)
+	assert.Equal(t, "application/json", got.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"text": "Download Failed",
+		"attachments": [{
+			"color": "#e74c3c",
+			"title": "Download Failed",
+			"text": "Transfer <Show.S01E01> could not be downloaded",
+			"fields": [
+				{"title": "Transfer ID", "value": "42", "short": true},
+				{"title": "Reason", "value": "disk full", "short": false}
+			],
+			"ts": 1704164645
+		}]
+	}`, got.Body)
+}
+
+func TestSlack_Notify(t *testing.T) {
+	slack := newService(t)
+
+	require.NoError(t, (&SlackNotifier{WebhookURL: slack.URL}).Notify("hello"))
+	assert.JSONEq(t, `{"text": "hello"}`, slack.only(t).Body)
+}
+
+func TestSlack_Rejected(t *testing.T) {
+	slack := newService(t)
+	slack.status = http.StatusNotFound
+
+	err := (&SlackNotifier{WebhookURL: slack.URL}).Notify("hello")
+	require.ErrorContains(t, err, "slack: request failed with status 404")
+}
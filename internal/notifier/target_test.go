@@ -0,0 +1,157 @@
+package notifier
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// request is what a fake service received.
+type request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+// service is a fake notification service that records every request and
+// answers with status.
+type service struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []request
+	status   int
+}
+
+func newService(t *testing.T) *service {
+	t.Helper()
+
+	s := &service{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.requests = append(s.requests, request{Method: r.Method, Path: r.URL.Path, Header: r.Header, Body: string(body)})
+		w.WriteHeader(s.status)
+		_, _ = w.Write([]byte(`{"ok":false,"description":"rejected"}`))
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// only returns the one request the service should have had.
+func (s *service) only(t *testing.T) request {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	require.Len(t, s.requests, 1)
+
+	return s.requests[0]
+}
+
+var testEmbed = Embed{
+	Title:       "Download Failed",
+	Description: "Transfer <Show.S01E01> could not be downloaded",
+	Color:       0xE74C3C,
+	Fields: []EmbedField{
+		{Name: "Transfer ID", Value: "42", Inline: true},
+		{Name: "Reason", Value: "disk full"},
+	},
+	Timestamp: "2024-01-02T03:04:05Z",
+}
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		target Target
+		want   Notifier
+	}{
+		{Target{Type: "discord", URL: "https://discord/hook"}, &DiscordNotifier{WebhookURL: "https://discord/hook"}},
+		{Target{Type: "Slack", URL: "https://slack/hook"}, &SlackNotifier{WebhookURL: "https://slack/hook"}},
+		{Target{Type: "telegram", Token: "t", ChatID: "c"}, &TelegramNotifier{Token: "t", ChatID: "c"}},
+		{Target{Type: "ntfy", URL: "https://ntfy.sh", Topic: "seedbox"}, &NtfyNotifier{ServerURL: "https://ntfy.sh", Topic: "seedbox"}},
+		{Target{Type: "gotify", URL: "https://gotify", Token: "t", Priority: 5}, &GotifyNotifier{ServerURL: "https://gotify", Token: "t", Priority: 5}},
+		{Target{Type: "pushover", Token: "t", User: "u"}, &PushoverNotifier{Token: "t", User: "u"}},
+		{
+			Target{Type: "email", URL: "smtps://u:p@mail:2465", From: "a@b", To: []string{"c@d"}},
+			&EmailNotifier{Addr: "mail:2465", ImplicitTLS: true, Username: "u", Password: "p", From: "a@b", To: []string{"c@d"}},
+		},
+	} {
+		t.Run(tc.target.Type, func(t *testing.T) {
+			n, err := New(tc.target)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, n)
+		})
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(Target{Type: "carrier-pigeon"})
+	require.ErrorContains(t, err, `unknown type "carrier-pigeon"`)
+
+	_, err = New(Target{Type: "telegram", Name: "family"})
+	require.ErrorContains(t, err, `notification target "family": missing token, chat_id`)
+
+	_, err = New(Target{Type: "email", URL: "smtp://mail"})
+	require.ErrorContains(t, err, "url, from and to are required")
+
+	_, err = New(Target{Type: "webhook", URL: "https://hook", Body: "{{ .Nope"})
+	require.ErrorContains(t, err, "invalid body template")
+}
+
+// One target failing does not keep the notification from the others.
+func TestMulti_SendsToEveryTarget(t *testing.T) {
+	failing, working := newService(t), newService(t)
+	failing.status = http.StatusInternalServerError
+
+	multi := Multi{&SlackNotifier{WebhookURL: failing.URL}, &SlackNotifier{WebhookURL: working.URL}}
+
+	err := multi.NotifyEmbed(testEmbed)
+	require.ErrorContains(t, err, "status 500")
+
+	failing.only(t)
+	working.only(t)
+}
+
+// Notifiers that take events get the event; the rest get its embed.
+func TestMulti_NotifyEvent(t *testing.T) {
+	hook, slack := newService(t), newService(t)
+
+	webhook, err := NewWebhook(hook.URL, "", "{{ .Type }} {{ .TransferName }}", nil)
+	require.NoError(t, err)
+
+	multi := Multi{webhook, &SlackNotifier{WebhookURL: slack.URL}}
+
+	embed := testEmbed
+	require.NoError(t, multi.NotifyEvent(Event{Type: EventDownloaded, TransferName: "Show.S01E01", Embed: &embed}))
+
+	assert.Equal(t, "downloaded Show.S01E01", hook.only(t).Body)
+	assert.Contains(t, slack.only(t).Body, `"title":"Download Failed"`)
+}
+
+// What a Switch was set to before is handed back, to flush what it holds.
+func TestSwitch(t *testing.T) {
+	before, after := &recorder{}, &recorder{}
+
+	s := NewSwitch(Multi{before})
+	require.NoError(t, s.NotifyEvent(Event{Type: EventDownloaded, TransferName: "a"}))
+
+	replaced := s.Set(Multi{after})
+	require.NoError(t, s.Notify("b"))
+
+	assert.Equal(t, Multi{before}, replaced)
+	require.Len(t, before.sent(), 1)
+	assert.Equal(t, "a", before.sent()[0].TransferName)
+	require.Len(t, after.sent(), 1)
+	assert.Equal(t, "b", after.sent()[0].Message)
+}
@@ -0,0 +1,69 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefaultTelegramURL is the Bot API.
+const DefaultTelegramURL = "https://api.telegram.org"
+
+// TelegramNotifier sends messages to a chat through a Telegram bot. Embeds are
+// rendered in Telegram's HTML.
+type TelegramNotifier struct {
+	Token  string
+	ChatID string
+	// APIURL overrides DefaultTelegramURL, for a local Bot API server.
+	APIURL string
+	// Client sends the requests; nil is one with DefaultTimeout.
+	Client *http.Client
+}
+
+type telegramMessage struct {
+	ChatID    string `json:"chat_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+func (t *TelegramNotifier) Notify(content string) error {
+	return t.notify(context.Background(), content)
+}
+
+// NotifyEmbed sends the embed as an HTML message.
+func (t *TelegramNotifier) NotifyEmbed(embed Embed) error {
+	return t.notifyEmbed(context.Background(), embed)
+}
+
+// NotifyEventContext sends the event's embed, or its message, until ctx is done.
+func (t *TelegramNotifier) NotifyEventContext(ctx context.Context, event Event) error {
+	return sendEvent(ctx, event, t.notify, t.notifyEmbed)
+}
+
+func (t *TelegramNotifier) notify(ctx context.Context, content string) error {
+	return t.send(ctx, telegramMessage{ChatID: t.ChatID, Text: content})
+}
+
+func (t *TelegramNotifier) notifyEmbed(ctx context.Context, embed Embed) error {
+	return t.send(ctx, telegramMessage{ChatID: t.ChatID, Text: htmlText(embed), ParseMode: "HTML"})
+}
+
+func (t *TelegramNotifier) send(ctx context.Context, message telegramMessage) error {
+	if t.Token == "" || t.ChatID == "" {
+		return fmt.Errorf("bot token and chat id are required")
+	}
+
+	apiURL := t.APIURL
+	if apiURL == "" {
+		apiURL = DefaultTelegramURL
+	}
+
+	// The token is part of the path; it is kept out of the error, which is logged.
+	err := postJSON(ctx, t.Client, strings.TrimSuffix(apiURL, "/")+"/bot"+t.Token+"/sendMessage", message, nil)
+	if err != nil {
+		return fmt.Errorf("telegram: %w", redactURL(err, t.Token))
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package notifier
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTelegram_NotifyEmbed(t *testing.T) {
+	telegram := newService(t)
+
+	n := &TelegramNotifier{Token: "123:abc", ChatID: "-1001", APIURL: telegram.URL}
+	require.NoError(t, n.NotifyEmbed(testEmbed))
+
+	got := telegram.only(t)
+	assert.Equal(t, "/bot123:abc/sendMessage", got.Path)
+	assert.JSONEq(t, `{
+		"chat_id": "-1001",
+		"parse_mode": "HTML",
+		"text": "<b>Download Failed</b>\nTransfer &lt;Show.S01E01&gt; could not be downloaded\n<b>Transfer ID:</b> 42\n<b>Reason:</b> disk full"
+	}`, got.Body)
+}
+
+func TestTelegram_ErrorHidesTheToken(t *testing.T) {
+	telegram := newService(t)
+	telegram.status = http.StatusUnauthorized
+
+	err := (&TelegramNotifier{Token: "123:abc", ChatID: "-1001", APIURL: telegram.URL}).Notify("hello")
+	require.ErrorContains(t, err, "status 401")
+	assert.NotContains(t, err.Error(), "123:abc")
+}
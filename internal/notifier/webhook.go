@@ -0,0 +1,122 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// WebhookNotifier sends events to any URL, in whatever shape the service there
+// wants: the body and each header are Go templates executed with the Event.
+// Without a body template the Event is sent as JSON.
+type WebhookNotifier struct {
+	URL string
+	// Method defaults to POST.
+	Method  string
+	Body    *template.Template
+	Headers map[string]*template.Template
+	// Client sends the requests; nil is one with DefaultTimeout.
+	Client *http.Client
+}
+
+// templateFuncs are the functions webhook templates may use besides the
+// built-in ones.
+var templateFuncs = template.FuncMap{
+	// json writes a value as JSON, quoting strings, so a name with a quote in it
+	// cannot break the body.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+
+		return string(b), err
+	},
+	// bytes writes a size as people read it, such as 1.2 GB.
+	"bytes": func(size int64) string {
+		return humanize.Bytes(uint64(max(size, 0)))
+	},
+}
+
+// NewWebhook parses the body and header templates. An empty body sends the
+// Event as JSON.
+func NewWebhook(rawURL, method, body string, headers map[string]string) (*WebhookNotifier, error) {
+	w := &WebhookNotifier{URL: rawURL, Method: strings.ToUpper(method), Headers: make(map[string]*template.Template, len(headers))}
+
+	if body != "" {
+		tmpl, err := template.New("body").Funcs(templateFuncs).Parse(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body template: %w", err)
+		}
+
+		w.Body = tmpl
+	}
+
+	for name, value := range headers {
+		tmpl, err := template.New(name).Funcs(templateFuncs).Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for header %s: %w", name, err)
+		}
+
+		w.Headers[name] = tmpl
+	}
+
+	return w, nil
+}
+
+func (w *WebhookNotifier) Notify(content string) error {
+	return w.NotifyEvent(Event{Type: EventMessage, Time: time.Now().UTC(), Message: content})
+}
+
+// NotifyEmbed sends the embed as a message event, its text in Message.
+func (w *WebhookNotifier) NotifyEmbed(embed Embed) error {
+	return w.NotifyEvent(Event{Type: EventMessage, Time: time.Now().UTC(), Message: plainText(embed), Embed: &embed})
+}
+
+// NotifyEvent renders the templates with event and sends the result.
+func (w *WebhookNotifier) NotifyEvent(event Event) error {
+	return w.NotifyEventContext(context.Background(), event)
+}
+
+// NotifyEventContext is NotifyEvent, until ctx is done.
+func (w *WebhookNotifier) NotifyEventContext(ctx context.Context, event Event) error {
+	if w.URL == "" {
+		return fmt.Errorf("webhook URL is not set")
+	}
+
+	var body bytes.Buffer
+
+	if w.Body == nil {
+		if err := json.NewEncoder(&body).Encode(event); err != nil {
+			return &permanentError{fmt.Errorf("webhook: failed to marshal event: %w", err)}
+		}
+	} else if err := w.Body.Execute(&body, event); err != nil {
+		return &permanentError{fmt.Errorf("webhook: failed to render body: %w", err)}
+	}
+
+	header := http.Header{}
+
+	for name, tmpl := range w.Headers {
+		var value strings.Builder
+		if err := tmpl.Execute(&value, event); err != nil {
+			return &permanentError{fmt.Errorf("webhook: failed to render header %s: %w", name, err)}
+		}
+
+		header.Set(name, value.String())
+	}
+
+	method := w.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	if err := send(ctx, w.Client, method, w.URL, "application/json", &body, header); err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testEvent = Event{
+	Type:         EventDownloaded,
+	Time:         time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	TransferID:   "42",
+	TransferName: `Show "Pilot" S01E01`,
+	Size:         1_500_000_000,
+	FileCount:    3,
+	LocalPath:    "/downloads/Show.S01E01",
+	Duration:     90 * time.Second,
+	Instance:     "sonarr",
+}
+
+func TestWebhook_Templates(t *testing.T) {
+	s := newService(t)
+
+	w, err := NewWebhook(s.URL+"/hooks/seedbox", "put",
+		`{"text": {{ json (printf "%s finished in %s (%s)" .TransferName .Duration (bytes .Size)) }}, "files": {{ .FileCount }}}`,
+		map[string]string{"Authorization": "Bearer secret", "X-Event": "{{ .Type }}/{{ .TransferID }}"},
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, w.NotifyEvent(testEvent))
+
+	got := s.only(t)
+	assert.Equal(t, http.MethodPut, got.Method)
+	assert.Equal(t, "/hooks/seedbox", got.Path)
+	assert.Equal(t, "Bearer secret", got.Header.Get("Authorization"))
+	assert.Equal(t, "downloaded/42", got.Header.Get("X-Event"))
+	assert.Equal(t, "application/json", got.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"text": "Show \"Pilot\" S01E01 finished in 1m30s (1.5 GB)", "files": 3}`, got.Body)
+}
+
+// Without a body template the event itself is sent.
+func TestWebhook_DefaultBody(t *testing.T) {
+	s := newService(t)
+
+	w, err := NewWebhook(s.URL, "", "", map[string]string{"Content-Type": "application/vnd.seedbox+json"})
+	require.NoError(t, err)
+
+	require.NoError(t, w.NotifyEvent(testEvent))
+
+	got := s.only(t)
+	assert.Equal(t, http.MethodPost, got.Method)
+	assert.Equal(t, "application/vnd.seedbox+json", got.Header.Get("Content-Type"))
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal([]byte(got.Body), &body))
+	assert.Equal(t, map[string]any{
+		"type":             "downloaded",
+		"time":             "2024-01-02T03:04:05Z",
+		"transfer_id":      "42",
+		"transfer_name":    `Show "Pilot" S01E01`,
+		"size":             float64(1_500_000_000),
+		"file_count":       float64(3),
+		"local_path":       "/downloads/Show.S01E01",
+		"duration_seconds": float64(90),
+		"instance":         "sonarr",
+	}, body)
+}
+
+// Plain messages arrive as message events.
+func TestWebhook_Notify(t *testing.T) {
+	s := newService(t)
+
+	w, err := NewWebhook(s.URL, "", "{{ .Type }}: {{ .Message }}", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, w.NotifyEmbed(testEmbed))
+	assert.Equal(t, "message: "+plainText(testEmbed), s.only(t).Body)
+}
+
+func TestWebhook_Errors(t *testing.T) {
+	s := newService(t)
+	s.status = http.StatusBadRequest
+
+	w, err := NewWebhook(s.URL, "", "{{ .Missing }}", nil)
+	require.NoError(t, err)
+	require.ErrorContains(t, w.NotifyEvent(testEvent), "failed to render body")
+
+	w, err = NewWebhook(s.URL, "", "", nil)
+	require.NoError(t, err)
+	require.ErrorContains(t, w.NotifyEvent(testEvent), "status 400")
+}
@@ -0,0 +1,133 @@
+// Package pathmap translates paths between this process and an *arr app that
+// mounts the same volume somewhere else.
+//
+// A path is translated by its longest matching prefix, matched on whole
+// segments: /downloads maps /downloads/Show but not /downloads-old/Show. A path
+// no mapping covers is passed through unchanged.
+package pathmap
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Mapping pairs a prefix as this process sees it with the same prefix as the
+// *arr app sees it.
+type Mapping struct {
+	Local  string
+	Remote string
+}
+
+// Mappings is a set of mappings for one *arr app. The zero value maps nothing.
+type Mappings []Mapping
+
+// Parse reads mappings written as "local=remote", one per spec.
+func Parse(specs []string) (Mappings, error) {
+	mappings := make(Mappings, 0, len(specs))
+
+	for _, spec := range specs {
+		local, remote, ok := strings.Cut(spec, "=")
+		if !ok || strings.TrimSpace(local) == "" || strings.TrimSpace(remote) == "" {
+			return nil, fmt.Errorf("path mapping %q is not local=remote", spec)
+		}
+
+		mappings = append(mappings, Mapping{Local: clean(local), Remote: clean(remote)})
+	}
+
+	return mappings, nil
+}
+
+// ToRemote translates a local path to the app's view of it.
+func (m Mappings) ToRemote(local string) string {
+	return translate(m, local, func(mp Mapping) (string, string) { return mp.Local, mp.Remote })
+}
+
+// ToLocal translates a path as the app sees it to this process's view of it.
+func (m Mappings) ToLocal(remote string) string {
+	return translate(m, remote, func(mp Mapping) (string, string) { return mp.Remote, mp.Local })
+}
+
+// Validate checks that paths survive the trip out and back in both directions.
+// It fails when mappings overlap so that one translates a path another should:
+// two local prefixes sharing a remote one, or a remote prefix nested inside
+// another mapping's remote prefix but pointing somewhere else locally.
+//
+// Only the paths where mappings meet are checked, which is where a round trip
+// can break: every prefix, and every local path whose remote side is another
+// mapping's remote prefix.
+func (m Mappings) Validate() error {
+	var errs []error
+
+	for _, mp := range m {
+		if !path.IsAbs(mp.Local) || !path.IsAbs(mp.Remote) {
+			errs = append(errs, fmt.Errorf("path mapping %s=%s: both sides must be absolute", mp.Local, mp.Remote))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	var locals, remotes []string
+
+	for _, a := range m {
+		locals = append(locals, a.Local)
+		remotes = append(remotes, a.Remote)
+
+		for _, b := range m {
+			if within(b.Remote, a.Remote) {
+				locals = append(locals, path.Join(a.Local, strings.TrimPrefix(b.Remote, a.Remote)))
+			}
+		}
+	}
+
+	for _, local := range locals {
+		if back := m.ToLocal(m.ToRemote(local)); back != local {
+			errs = append(errs, fmt.Errorf("local path %s comes back as %s", local, back))
+		}
+	}
+
+	for _, remote := range remotes {
+		if back := m.ToRemote(m.ToLocal(remote)); back != remote {
+			errs = append(errs, fmt.Errorf("remote path %s comes back as %s", remote, back))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func translate(m Mappings, p string, sides func(Mapping) (from, to string)) string {
+	if len(m) == 0 || p == "" {
+		return p
+	}
+
+	cleaned := clean(p)
+
+	best, bestTo := "", ""
+
+	for _, mp := range m {
+		from, to := sides(mp)
+		if within(cleaned, from) && len(from) > len(best) {
+			best, bestTo = from, to
+		}
+	}
+
+	if best == "" {
+		return p
+	}
+
+	return path.Join(bestTo, strings.TrimPrefix(cleaned, best))
+}
+
+// within reports whether p is prefix or beneath it.
+func within(p, prefix string) bool {
+	return p == prefix || prefix == "/" || strings.HasPrefix(p, prefix+"/")
+}
+
+// clean drops a trailing separator and any doubled ones, so prefixes compare the
+// same however they were written.
+func clean(p string) string {
+	return path.Clean(strings.TrimSpace(p))
+}
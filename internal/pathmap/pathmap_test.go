@@ -0,0 +1,68 @@
+package pathmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	m, err := Parse([]string{"/downloads/=/data/torrents", " /media = /tv "})
+	require.NoError(t, err)
+	assert.Equal(t, Mappings{{Local: "/downloads", Remote: "/data/torrents"}, {Local: "/media", Remote: "/tv"}}, m)
+
+	for _, bad := range []string{"/downloads", "=/data", "/downloads="} {
+		_, err := Parse([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	m := Mappings{
+		{Local: "/downloads", Remote: "/data/torrents"},
+		{Local: "/downloads/4k", Remote: "/data/uhd"},
+	}
+
+	tests := []struct {
+		local, remote string
+	}{
+		{"/downloads", "/data/torrents"},
+		{"/downloads/Show/e01.mkv", "/data/torrents/Show/e01.mkv"},
+		// The longer prefix wins.
+		{"/downloads/4k/Movie", "/data/uhd/Movie"},
+		// Whole segments only.
+		{"/downloads-old/Show", "/downloads-old/Show"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.remote, m.ToRemote(tt.local), tt.local)
+		assert.Equal(t, tt.local, m.ToLocal(tt.remote), tt.remote)
+	}
+
+	assert.Equal(t, "/elsewhere/x", Mappings(nil).ToRemote("/elsewhere/x"))
+	assert.Empty(t, m.ToRemote(""))
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, Mappings{
+		{Local: "/downloads", Remote: "/data/torrents"},
+		{Local: "/downloads/4k", Remote: "/data/uhd"},
+	}.Validate())
+
+	// Two local prefixes on one remote: the way back is ambiguous.
+	err := Mappings{
+		{Local: "/downloads", Remote: "/data"},
+		{Local: "/media", Remote: "/data"},
+	}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "local path /media comes back as /downloads")
+
+	// A nested remote prefix that points outside the local one it sits in.
+	require.Error(t, Mappings{
+		{Local: "/downloads", Remote: "/data"},
+		{Local: "/media", Remote: "/data/media"},
+	}.Validate())
+
+	require.Error(t, Mappings{{Local: "downloads", Remote: "/data"}}.Validate())
+}
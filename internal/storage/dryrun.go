@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+)
+
+// DryRunLedger is what a DryRunRepository reads.
+type DryRunLedger interface {
+	DownloadRepository
+	GetDownload(transferID string) (DownloadRecord, error)
+}
+
+// DryRunRepository is the ledger as a dry run sees it: read as it is, but with
+// nothing written to it. A transfer it would claim is claimed in memory, once a
+// run, and status changes and history entries are dropped.
+type DryRunRepository struct {
+	ledger DryRunLedger
+
+	mu      sync.Mutex
+	claimed map[string]bool
+}
+
+func NewDryRunRepository(ledger DryRunLedger) *DryRunRepository {
+	return &DryRunRepository{ledger: ledger, claimed: map[string]bool{}}
+}
+
+func (r *DryRunRepository) GetDownloads() ([]DownloadRecord, error) {
+	return r.ledger.GetDownloads()
+}
+
+// ClaimTransfer claims in memory what the ledger would let be claimed: a
+// transfer it does not hold, or holds as pending or failed and unclaimed.
+func (r *DryRunRepository) ClaimTransfer(transferID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.claimed[transferID] {
+		return false, nil
+	}
+
+	record, err := r.ledger.GetDownload(transferID)
+
+	switch {
+	case errors.Is(err, ErrTransferNotFound):
+	case err != nil:
+		return false, err
+	case record.Status == "downloaded":
+		return false, ErrDownloaded
+	case record.Status != "pending" && record.Status != "failed", record.LockedBy != "":
+		return false, nil
+	}
+
+	r.claimed[transferID] = true
+
+	return true, nil
+}
+
+func (r *DryRunRepository) UpdateTransferStatus(string, string) error {
+	return nil
+}
+
+func (r *DryRunRepository) RecordTransferEvent(string, string) error {
+	return nil
+}
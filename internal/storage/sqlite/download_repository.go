@@ -1,7 +1,10 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/italolelis/seedbox_downloader/internal/storage"
@@ -15,6 +18,18 @@ func NewDownloadRepository(dbConn *sql.DB) *DownloadRepository {
 	return &DownloadRepository{db: dbConn}
 }
 
+// Ping checks that the ledger can be read. Reading the table, rather than only
+// pinging the connection, also catches a file that has been replaced or
+// truncated underneath us.
+func (r *DownloadRepository) Ping(ctx context.Context) error {
+	var n int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM (SELECT 1 FROM downloads LIMIT 1)`).Scan(&n); err != nil {
+		return fmt.Errorf("failed to read the downloads table: %w", err)
+	}
+
+	return nil
+}
+
 func (r *DownloadRepository) GetDownloads() ([]storage.DownloadRecord, error) {
 	rows, err := r.db.Query(`SELECT transfer_id, downloaded_at, status, locked_by FROM downloads`)
 	if err != nil {
@@ -25,24 +40,27 @@ func (r *DownloadRepository) GetDownloads() ([]storage.DownloadRecord, error) {
 	var downloads []storage.DownloadRecord
 
 	for rows.Next() {
-		var record storage.DownloadRecord
-
-		var lockedBy sql.NullString
-
-		err := rows.Scan(&record.DownloadID, &record.FilePath, &record.DownloadedAt, &record.Status, &lockedBy)
+		record, err := scanDownload(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		record.LockedBy = ""
-		if lockedBy.Valid {
-			record.LockedBy = lockedBy.String
-		}
-
 		downloads = append(downloads, record)
 	}
 
-	return downloads, nil
+	return downloads, rows.Err()
+}
+
+// GetDownload returns the ledger row for one transfer, or storage.ErrTransferNotFound.
+func (r *DownloadRepository) GetDownload(transferID string) (storage.DownloadRecord, error) {
+	row := r.db.QueryRow(`SELECT transfer_id, downloaded_at, status, locked_by FROM downloads WHERE transfer_id = ?`, transferID)
+
+	record, err := scanDownload(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.DownloadRecord{}, storage.ErrTransferNotFound
+	}
+
+	return record, err
 }
 
 // ClaimTransfer atomically sets status to 'downloading' and locked_by to instanceID if status is 'pending' or 'failed'.
@@ -58,6 +76,8 @@ func (r *DownloadRepository) ClaimTransfer(transferID string) (bool, error) {
 		return false, storage.ErrDownloaded
 	}
 
+	now := time.Now().Format(time.RFC3339)
+
 	// Now do the upsert/claim
 	rows, err := r.db.Exec(`
 		INSERT INTO downloads (transfer_id, downloaded_at, status, locked_by)
@@ -66,19 +86,161 @@ func (r *DownloadRepository) ClaimTransfer(transferID string) (bool, error) {
 			status = 'downloading',
 			locked_by = excluded.locked_by
 		WHERE downloads.status IN ('pending', 'failed') AND (downloads.locked_by IS NULL OR downloads.locked_by = '')
-	`, transferID, time.Now().Format(time.RFC3339), storage.GenerateInstanceID())
+	`, transferID, now, storage.GenerateInstanceID())
 	if err != nil {
 		return false, err
 	}
 
 	affected, _ := rows.RowsAffected()
+	if affected == 0 {
+		return false, nil
+	}
+
+	if err := r.recordEvent(transferID, "downloading"); err != nil {
+		return true, err
+	}
 
-	return affected > 0, nil
+	return true, nil
 }
 
 // UpdateTransferStatus sets the status for a download.
 func (r *DownloadRepository) UpdateTransferStatus(transferID, status string) error {
-	_, err := r.db.Exec(`UPDATE downloads SET status = ?, locked_by = NULL WHERE transfer_id = ?`, status, transferID)
+	res, err := r.db.Exec(`UPDATE downloads SET status = ?, locked_by = NULL WHERE transfer_id = ?`, status, transferID)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return nil
+	}
+
+	return r.recordEvent(transferID, status)
+}
+
+// RecordTransferEvent adds an entry to a transfer's history without touching its
+// status. Transfers without a row are ignored, as UpdateTransferStatus does.
+func (r *DownloadRepository) RecordTransferEvent(transferID, event string) error {
+	var exists bool
+	if err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM downloads WHERE transfer_id = ?)`, transferID).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return nil
+	}
+
+	return r.recordEvent(transferID, event)
+}
+
+// GetTransferHistory returns every recorded status change for a transfer, oldest first.
+func (r *DownloadRepository) GetTransferHistory(transferID string) ([]storage.TransferEvent, error) {
+	rows, err := r.db.Query(`SELECT status, at FROM transfer_events WHERE transfer_id = ? ORDER BY rowid`, transferID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []storage.TransferEvent
+
+	for rows.Next() {
+		var event storage.TransferEvent
+		if err := rows.Scan(&event.Status, &event.At); err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// ResetTransfer sets a transfer back to 'pending' and releases its claim.
+func (r *DownloadRepository) ResetTransfer(transferID string) error {
+	res, err := r.db.Exec(`UPDATE downloads SET status = 'pending', locked_by = NULL WHERE transfer_id = ?`, transferID)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return storage.ErrTransferNotFound
+	}
+
+	return r.recordEvent(transferID, "pending")
+}
+
+// SetTransferOwner records the *arr instance that added a transfer, replacing any
+// owner recorded before.
+func (r *DownloadRepository) SetTransferOwner(transferID, owner string) error {
+	_, err := r.db.Exec(`INSERT INTO transfer_owners (transfer_id, owner) VALUES (?, ?)
+		ON CONFLICT (transfer_id) DO UPDATE SET owner = excluded.owner`, transferID, owner)
+
+	return err
+}
+
+// TransferOwner returns the *arr instance that added a transfer, or "".
+func (r *DownloadRepository) TransferOwner(transferID string) (string, error) {
+	var owner string
+
+	err := r.db.QueryRow(`SELECT owner FROM transfer_owners WHERE transfer_id = ?`, transferID).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return owner, err
+}
+
+// ForgetTransfer deletes a transfer's row, its history and its owner in one transaction.
+func (r *DownloadRepository) ForgetTransfer(transferID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM downloads WHERE transfer_id = ?`, transferID)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return storage.ErrTransferNotFound
+	}
+
+	if _, err := tx.Exec(`DELETE FROM transfer_events WHERE transfer_id = ?`, transferID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM transfer_owners WHERE transfer_id = ?`, transferID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *DownloadRepository) recordEvent(transferID, status string) error {
+	_, err := r.db.Exec(`INSERT INTO transfer_events (transfer_id, status, at) VALUES (?, ?, ?)`,
+		transferID, status, time.Now().Format(time.RFC3339))
 
 	return err
 }
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDownload(s scanner) (storage.DownloadRecord, error) {
+	var record storage.DownloadRecord
+
+	var lockedBy sql.NullString
+
+	if err := s.Scan(&record.DownloadID, &record.DownloadedAt, &record.Status, &lockedBy); err != nil {
+		return storage.DownloadRecord{}, err
+	}
+
+	if lockedBy.Valid {
+		record.LockedBy = lockedBy.String
+	}
+
+	return record, nil
+}
@@ -73,3 +73,180 @@ func TestClaimTransfer_DownloadedTransferIsNotClaimedAgain(t *testing.T) {
 	assert.ErrorIs(t, err, storage.ErrDownloaded,
 		"an already-downloaded transfer must be reported as such, not silently skipped")
 }
+
+func TestTransferHistory_RecordsEveryStatusChange(t *testing.T) {
+	repo := newTestRepo(t)
+
+	_, err := repo.ClaimTransfer("100")
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateTransferStatus("100", "failed"))
+	require.NoError(t, repo.ResetTransfer("100"))
+
+	history, err := repo.GetTransferHistory("100")
+	require.NoError(t, err)
+
+	statuses := make([]string, 0, len(history))
+	for _, event := range history {
+		statuses = append(statuses, event.Status)
+	}
+
+	assert.Equal(t, []string{"downloading", "failed", "pending"}, statuses)
+}
+
+func TestRecordTransferEvent_LeavesStatusAlone(t *testing.T) {
+	repo := newTestRepo(t)
+
+	_, err := repo.ClaimTransfer("100")
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateTransferStatus("100", "downloaded"))
+	require.NoError(t, repo.RecordTransferEvent("100", "import_scan_completed"))
+	require.NoError(t, repo.RecordTransferEvent("unknown", "import_scan_completed"))
+
+	record, err := repo.GetDownload("100")
+	require.NoError(t, err)
+	assert.Equal(t, "downloaded", record.Status)
+
+	history, err := repo.GetTransferHistory("100")
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, "import_scan_completed", history[2].Status)
+
+	history, err = repo.GetTransferHistory("unknown")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+// A downloaded transfer is never claimed again on its own; resetting it is the
+// only way to fetch it a second time.
+func TestResetTransfer_DownloadedTransferCanBeClaimedAgain(t *testing.T) {
+	repo := newTestRepo(t)
+
+	_, err := repo.ClaimTransfer("100")
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateTransferStatus("100", "downloaded"))
+
+	require.NoError(t, repo.ResetTransfer("100"))
+
+	claimed, err := repo.ClaimTransfer("100")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestResetTransfer_UnknownTransferIsNotFound(t *testing.T) {
+	repo := newTestRepo(t)
+
+	assert.ErrorIs(t, repo.ResetTransfer("404"), storage.ErrTransferNotFound)
+}
+
+func TestForgetTransfer_RemovesRowAndHistory(t *testing.T) {
+	repo := newTestRepo(t)
+
+	require.NoError(t, repo.SetTransferOwner("100", "sonarr"))
+
+	_, err := repo.ClaimTransfer("100")
+	require.NoError(t, err)
+
+	require.NoError(t, repo.ForgetTransfer("100"))
+
+	owner, err := repo.TransferOwner("100")
+	require.NoError(t, err)
+	assert.Empty(t, owner)
+
+	_, err = repo.GetDownload("100")
+	assert.ErrorIs(t, err, storage.ErrTransferNotFound)
+
+	history, err := repo.GetTransferHistory("100")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	assert.ErrorIs(t, repo.ForgetTransfer("100"), storage.ErrTransferNotFound)
+}
+
+// The owner is known when the transfer is added, before there is a row to claim.
+func TestTransferOwner_RecordedBeforeTheClaim(t *testing.T) {
+	repo := newTestRepo(t)
+
+	owner, err := repo.TransferOwner("100")
+	require.NoError(t, err)
+	assert.Empty(t, owner, "no owner recorded")
+
+	require.NoError(t, repo.SetTransferOwner("100", "sonarr"))
+	require.NoError(t, repo.SetTransferOwner("100", "sonarr-4k"))
+
+	owner, err = repo.TransferOwner("100")
+	require.NoError(t, err)
+	assert.Equal(t, "sonarr-4k", owner)
+}
+
+func TestGetDownloads_ListsEveryRow(t *testing.T) {
+	repo := newTestRepo(t)
+
+	_, err := repo.ClaimTransfer("100")
+	require.NoError(t, err)
+	_, err = repo.ClaimTransfer("200")
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateTransferStatus("200", "downloaded"))
+
+	downloads, err := repo.GetDownloads()
+	require.NoError(t, err)
+	require.Len(t, downloads, 2)
+
+	byID := map[string]storage.DownloadRecord{}
+	for _, d := range downloads {
+		byID[d.DownloadID] = d
+	}
+
+	assert.Equal(t, "downloading", byID["100"].Status)
+	assert.NotEmpty(t, byID["100"].LockedBy)
+	assert.Equal(t, "downloaded", byID["200"].Status)
+	assert.Empty(t, byID["200"].LockedBy)
+}
+
+func TestPing(t *testing.T) {
+	repo := newTestRepo(t)
+
+	require.NoError(t, repo.Ping(context.Background()))
+
+	_, err := repo.db.Exec(`DROP TABLE downloads`)
+	require.NoError(t, err)
+
+	assert.Error(t, repo.Ping(context.Background()), "a ledger without its table is not usable")
+}
+
+// A dry run claims what a poll would, once, and leaves the ledger as it found it.
+func TestDryRunRepository_WritesNothing(t *testing.T) {
+	repo := newTestRepo(t)
+
+	_, err := repo.ClaimTransfer("100")
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateTransferStatus("100", "downloaded"))
+	_, err = repo.ClaimTransfer("200")
+	require.NoError(t, err)
+
+	dryRun := storage.NewDryRunRepository(repo)
+
+	_, err = dryRun.ClaimTransfer("100")
+	require.ErrorIs(t, err, storage.ErrDownloaded)
+
+	claimed, err := dryRun.ClaimTransfer("200")
+	require.NoError(t, err)
+	assert.False(t, claimed, "claimed in the ledger")
+
+	claimed, err = dryRun.ClaimTransfer("300")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = dryRun.ClaimTransfer("300")
+	require.NoError(t, err)
+	assert.False(t, claimed, "claimed once a run")
+
+	require.NoError(t, dryRun.UpdateTransferStatus("300", "downloaded"))
+	require.NoError(t, dryRun.RecordTransferEvent("100", "imported"))
+
+	_, err = repo.GetDownload("300")
+	require.ErrorIs(t, err, storage.ErrTransferNotFound)
+
+	history, err := repo.GetTransferHistory("100")
+	require.NoError(t, err)
+	assert.Len(t, history, 2, "downloading and downloaded, nothing since")
+}
@@ -0,0 +1,269 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DumpVersion is the version of the format Export writes, and the only one
+// Import reads.
+const DumpVersion = 1
+
+// ErrNotEmpty is returned by Import into a database that already holds
+// transfers or notifications, unless it was asked to replace them.
+var ErrNotEmpty = errors.New("the database is not empty")
+
+// Dump is every table of the database, as db export writes it and db import
+// reads it: for backups, and for moving the service to another host.
+type Dump struct {
+	Version    int    `json:"version"`
+	ExportedAt string `json:"exported_at"`
+
+	Downloads     []DumpDownload     `json:"downloads"`
+	Events        []DumpEvent        `json:"transfer_events"`
+	Owners        []DumpOwner        `json:"transfer_owners"`
+	Notifications []DumpNotification `json:"notification_outbox"`
+}
+
+// DumpDownload is a row of the ledger.
+type DumpDownload struct {
+	TransferID   string `json:"transfer_id"`
+	DownloadedAt string `json:"downloaded_at"`
+	Status       string `json:"status"`
+	LockedBy     string `json:"locked_by,omitempty"`
+}
+
+// DumpEvent is an entry in a transfer's history.
+type DumpEvent struct {
+	TransferID string `json:"transfer_id"`
+	Status     string `json:"status"`
+	At         string `json:"at"`
+}
+
+// DumpOwner is the *arr instance that added a transfer.
+type DumpOwner struct {
+	TransferID string `json:"transfer_id"`
+	Owner      string `json:"owner"`
+}
+
+// DumpNotification is a notification waiting in the outbox. Its ID is kept, as
+// each target's are sent in its order.
+type DumpNotification struct {
+	ID            int64  `json:"id"`
+	Target        string `json:"target"`
+	Payload       []byte `json:"payload"`
+	Attempts      int    `json:"attempts"`
+	NextAttemptAt string `json:"next_attempt_at"`
+	CreatedAt     string `json:"created_at"`
+	LastError     string `json:"last_error,omitempty"`
+}
+
+// Export reads every table in one transaction, so the dump is consistent even
+// while the service is writing.
+func (r *DownloadRepository) Export(ctx context.Context) (Dump, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Dump{}, err
+	}
+	defer tx.Rollback()
+
+	dump := Dump{Version: DumpVersion, ExportedAt: time.Now().UTC().Format(time.RFC3339)}
+
+	if dump.Downloads, err = exportDownloads(ctx, tx); err != nil {
+		return Dump{}, fmt.Errorf("failed to export downloads: %w", err)
+	}
+
+	if dump.Events, err = exportEvents(ctx, tx); err != nil {
+		return Dump{}, fmt.Errorf("failed to export transfer events: %w", err)
+	}
+
+	if dump.Owners, err = exportOwners(ctx, tx); err != nil {
+		return Dump{}, fmt.Errorf("failed to export transfer owners: %w", err)
+	}
+
+	if dump.Notifications, err = exportNotifications(ctx, tx); err != nil {
+		return Dump{}, fmt.Errorf("failed to export the notification outbox: %w", err)
+	}
+
+	return dump, nil
+}
+
+func exportDownloads(ctx context.Context, tx *sql.Tx) ([]DumpDownload, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT transfer_id, downloaded_at, status, locked_by FROM downloads ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	downloads := []DumpDownload{}
+
+	for rows.Next() {
+		var (
+			d        DumpDownload
+			lockedBy sql.NullString
+		)
+
+		if err := rows.Scan(&d.TransferID, &d.DownloadedAt, &d.Status, &lockedBy); err != nil {
+			return nil, err
+		}
+
+		d.LockedBy = lockedBy.String
+		downloads = append(downloads, d)
+	}
+
+	return downloads, rows.Err()
+}
+
+func exportEvents(ctx context.Context, tx *sql.Tx) ([]DumpEvent, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT transfer_id, status, at FROM transfer_events ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []DumpEvent{}
+
+	for rows.Next() {
+		var e DumpEvent
+		if err := rows.Scan(&e.TransferID, &e.Status, &e.At); err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+func exportOwners(ctx context.Context, tx *sql.Tx) ([]DumpOwner, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT transfer_id, owner FROM transfer_owners ORDER BY transfer_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owners := []DumpOwner{}
+
+	for rows.Next() {
+		var o DumpOwner
+		if err := rows.Scan(&o.TransferID, &o.Owner); err != nil {
+			return nil, err
+		}
+
+		owners = append(owners, o)
+	}
+
+	return owners, rows.Err()
+}
+
+func exportNotifications(ctx context.Context, tx *sql.Tx) ([]DumpNotification, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id, target, payload, attempts, next_attempt_at, created_at, last_error
+		FROM notification_outbox ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []DumpNotification{}
+
+	for rows.Next() {
+		var (
+			n         DumpNotification
+			lastError sql.NullString
+		)
+
+		if err := rows.Scan(&n.ID, &n.Target, &n.Payload, &n.Attempts, &n.NextAttemptAt, &n.CreatedAt, &lastError); err != nil {
+			return nil, err
+		}
+
+		n.LastError = lastError.String
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// Import writes dump into the database in one transaction. A database holding
+// transfers or notifications already is refused with ErrNotEmpty, unless replace
+// is set, in which case everything in it is deleted first.
+func (r *DownloadRepository) Import(ctx context.Context, dump Dump, replace bool) error {
+	if dump.Version != DumpVersion {
+		return fmt.Errorf("unsupported dump version %d, expected %d", dump.Version, DumpVersion)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tables := []string{"downloads", "transfer_events", "transfer_owners", "notification_outbox"}
+
+	if replace {
+		for _, table := range tables {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table); err != nil {
+				return fmt.Errorf("failed to clear %s: %w", table, err)
+			}
+		}
+	} else {
+		var empty bool
+
+		err := tx.QueryRowContext(ctx, `SELECT NOT EXISTS(SELECT 1 FROM downloads) AND NOT EXISTS(SELECT 1 FROM notification_outbox)`).
+			Scan(&empty)
+		if err != nil {
+			return err
+		}
+
+		if !empty {
+			return ErrNotEmpty
+		}
+	}
+
+	if err := importRows(ctx, tx, dump); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func importRows(ctx context.Context, tx *sql.Tx, dump Dump) error {
+	for _, d := range dump.Downloads {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO downloads (transfer_id, downloaded_at, status, locked_by) VALUES (?, ?, ?, ?)`,
+			d.TransferID, d.DownloadedAt, d.Status, nullString(d.LockedBy)); err != nil {
+			return fmt.Errorf("failed to import download %s: %w", d.TransferID, err)
+		}
+	}
+
+	for _, e := range dump.Events {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO transfer_events (transfer_id, status, at) VALUES (?, ?, ?)`,
+			e.TransferID, e.Status, e.At); err != nil {
+			return fmt.Errorf("failed to import an event of transfer %s: %w", e.TransferID, err)
+		}
+	}
+
+	for _, o := range dump.Owners {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO transfer_owners (transfer_id, owner) VALUES (?, ?)`,
+			o.TransferID, o.Owner); err != nil {
+			return fmt.Errorf("failed to import the owner of transfer %s: %w", o.TransferID, err)
+		}
+	}
+
+	for _, n := range dump.Notifications {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO notification_outbox
+			(id, target, payload, attempts, next_attempt_at, created_at, last_error) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			n.ID, n.Target, n.Payload, n.Attempts, n.NextAttemptAt, n.CreatedAt, nullString(n.LastError)); err != nil {
+			return fmt.Errorf("failed to import notification %d: %w", n.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// nullString stores an empty string as NULL, as the columns it is used for are
+// NULL until set.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
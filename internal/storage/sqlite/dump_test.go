@@ -0,0 +1,87 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+
+	_, err := repo.ClaimTransfer("100")
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateTransferStatus("100", "downloaded"))
+	_, err = repo.ClaimTransfer("200")
+	require.NoError(t, err)
+	require.NoError(t, repo.SetTransferOwner("100", "sonarr"))
+	_, err = repo.EnqueueNotification("slack", []byte(`{"type":"downloaded"}`), 0)
+	require.NoError(t, err)
+
+	dump, err := repo.Export(ctx)
+	require.NoError(t, err)
+
+	// Through JSON, as the dump travels.
+	encoded, err := json.Marshal(dump)
+	require.NoError(t, err)
+
+	var decoded Dump
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+
+	restored := newTestRepo(t)
+	require.NoError(t, restored.Import(ctx, decoded, false))
+
+	again, err := restored.Export(ctx)
+	require.NoError(t, err)
+
+	again.ExportedAt = dump.ExportedAt
+	assert.Equal(t, dump, again)
+
+	record, err := restored.GetDownload("100")
+	require.NoError(t, err)
+	assert.Equal(t, "downloaded", record.Status)
+
+	record, err = restored.GetDownload("200")
+	require.NoError(t, err)
+	assert.NotEmpty(t, record.LockedBy, "a claim is restored with the transfer")
+
+	owner, err := restored.TransferOwner("100")
+	require.NoError(t, err)
+	assert.Equal(t, "sonarr", owner)
+
+	next, err := restored.NextNotification("slack")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"downloaded"}`, string(next.Payload))
+}
+
+func TestImport_RefusesANonEmptyDatabase(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+
+	_, err := repo.ClaimTransfer("100")
+	require.NoError(t, err)
+
+	dump := Dump{Version: DumpVersion, Downloads: []DumpDownload{{TransferID: "200", DownloadedAt: "2026-01-02T15:04:05Z", Status: "failed"}}}
+	require.ErrorIs(t, repo.Import(ctx, dump, false), ErrNotEmpty)
+
+	require.NoError(t, repo.Import(ctx, dump, true))
+
+	records, err := repo.GetDownloads()
+	require.NoError(t, err)
+	require.Len(t, records, 1, "replacing deletes what was there")
+	assert.Equal(t, "200", records[0].DownloadID)
+
+	history, err := repo.GetTransferHistory("100")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestImport_RefusesAnUnknownVersion(t *testing.T) {
+	repo := newTestRepo(t)
+
+	require.ErrorContains(t, repo.Import(context.Background(), Dump{Version: 99}, false), "unsupported dump version 99")
+}
@@ -10,7 +10,9 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// InitDB initializes the SQLite database and creates the downloads table if it doesn't exist.
+// InitDB initializes the SQLite database and creates the downloads,
+// transfer_events, transfer_owners and notification_outbox tables if they don't
+// exist.
 func InitDB(ctx context.Context, dbPath string, maxOpenConns, maxIdleConns int) (*sql.DB, error) {
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
@@ -52,5 +54,63 @@ func InitDB(ctx context.Context, dbPath string, maxOpenConns, maxIdleConns int)
 		return nil, err
 	}
 
+	// One row per status change, kept so a transfer's past can be inspected
+	// after the downloads row has moved on. Nothing in the pipeline reads it.
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS transfer_events (
+		transfer_id TEXT NOT NULL,
+		status TEXT NOT NULL,
+		at DATETIME NOT NULL
+	)`)
+
+	if err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS transfer_events_transfer_id ON transfer_events (transfer_id)`)
+	if err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	// Which *arr instance added a transfer. Kept apart from downloads because it is
+	// known when the transfer is added, long before it is claimed.
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS transfer_owners (
+		transfer_id TEXT PRIMARY KEY,
+		owner TEXT NOT NULL
+	)`)
+
+	if err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	// Notifications not yet sent, per target, in the order they are to go.
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS notification_outbox (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		target TEXT NOT NULL,
+		payload BLOB NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at DATETIME NOT NULL,
+		created_at DATETIME NOT NULL,
+		last_error TEXT
+	)`)
+
+	if err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS notification_outbox_target ON notification_outbox (target, id)`)
+	if err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
 	return db, nil
 }
@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/italolelis/seedbox_downloader/internal/storage"
 	"github.com/italolelis/seedbox_downloader/internal/telemetry"
@@ -22,6 +23,12 @@ func NewInstrumentedDownloadRepository(dbConn *sql.DB, tel *telemetry.Telemetry)
 	}
 }
 
+// Ping checks that the ledger can be read. It is not instrumented: health probes
+// call it every few seconds and would drown out the operations worth measuring.
+func (r *InstrumentedDownloadRepository) Ping(ctx context.Context) error {
+	return r.repo.Ping(ctx)
+}
+
 // GetDownloads retrieves all downloads with telemetry.
 func (r *InstrumentedDownloadRepository) GetDownloads() ([]storage.DownloadRecord, error) {
 	var result []storage.DownloadRecord
@@ -66,3 +73,136 @@ func (r *InstrumentedDownloadRepository) UpdateTransferStatus(transferID, status
 		return r.repo.UpdateTransferStatus(transferID, status)
 	})
 }
+
+// RecordTransferEvent adds to a transfer's history with telemetry.
+func (r *InstrumentedDownloadRepository) RecordTransferEvent(transferID, event string) error {
+	return r.telemetry.InstrumentDBOperation(context.Background(), "record_transfer_event", func(ctx context.Context) error {
+		return r.repo.RecordTransferEvent(transferID, event)
+	})
+}
+
+// GetDownload retrieves one transfer's row with telemetry.
+func (r *InstrumentedDownloadRepository) GetDownload(transferID string) (storage.DownloadRecord, error) {
+	var result storage.DownloadRecord
+
+	err := r.telemetry.InstrumentDBOperation(context.Background(), "get_download", func(ctx context.Context) error {
+		var err error
+
+		result, err = r.repo.GetDownload(transferID)
+
+		return err
+	})
+
+	return result, err
+}
+
+// GetTransferHistory retrieves a transfer's status history with telemetry.
+func (r *InstrumentedDownloadRepository) GetTransferHistory(transferID string) ([]storage.TransferEvent, error) {
+	var result []storage.TransferEvent
+
+	err := r.telemetry.InstrumentDBOperation(context.Background(), "get_transfer_history", func(ctx context.Context) error {
+		var err error
+
+		result, err = r.repo.GetTransferHistory(transferID)
+
+		return err
+	})
+
+	return result, err
+}
+
+// ResetTransfer resets a transfer to pending with telemetry.
+func (r *InstrumentedDownloadRepository) ResetTransfer(transferID string) error {
+	return r.telemetry.InstrumentDBOperation(context.Background(), "reset_transfer", func(ctx context.Context) error {
+		return r.repo.ResetTransfer(transferID)
+	})
+}
+
+// ForgetTransfer deletes a transfer with telemetry.
+func (r *InstrumentedDownloadRepository) ForgetTransfer(transferID string) error {
+	return r.telemetry.InstrumentDBOperation(context.Background(), "forget_transfer", func(ctx context.Context) error {
+		return r.repo.ForgetTransfer(transferID)
+	})
+}
+
+// SetTransferOwner records a transfer's owner with telemetry.
+func (r *InstrumentedDownloadRepository) SetTransferOwner(transferID, owner string) error {
+	return r.telemetry.InstrumentDBOperation(context.Background(), "set_transfer_owner", func(ctx context.Context) error {
+		return r.repo.SetTransferOwner(transferID, owner)
+	})
+}
+
+// TransferOwner retrieves a transfer's owner with telemetry.
+func (r *InstrumentedDownloadRepository) TransferOwner(transferID string) (string, error) {
+	var result string
+
+	err := r.telemetry.InstrumentDBOperation(context.Background(), "get_transfer_owner", func(ctx context.Context) error {
+		var err error
+
+		result, err = r.repo.TransferOwner(transferID)
+
+		return err
+	})
+
+	return result, err
+}
+
+// EnqueueNotification adds a notification to the outbox with telemetry.
+func (r *InstrumentedDownloadRepository) EnqueueNotification(target string, payload []byte, limit int) (int, error) {
+	var dropped int
+
+	err := r.telemetry.InstrumentDBOperation(context.Background(), "enqueue_notification", func(ctx context.Context) error {
+		var err error
+
+		dropped, err = r.repo.EnqueueNotification(target, payload, limit)
+
+		return err
+	})
+
+	return dropped, err
+}
+
+// NextNotification retrieves a target's next notification with telemetry.
+func (r *InstrumentedDownloadRepository) NextNotification(target string) (storage.OutboxMessage, error) {
+	var msg storage.OutboxMessage
+
+	err := r.telemetry.InstrumentDBOperation(context.Background(), "next_notification", func(ctx context.Context) error {
+		var err error
+
+		msg, err = r.repo.NextNotification(target)
+
+		return err
+	})
+
+	return msg, err
+}
+
+// RetryNotification schedules a notification's next attempt with telemetry.
+func (r *InstrumentedDownloadRepository) RetryNotification(id int64, nextAttemptAt time.Time, lastError string) error {
+	return r.telemetry.InstrumentDBOperation(context.Background(), "retry_notification", func(ctx context.Context) error {
+		return r.repo.RetryNotification(id, nextAttemptAt, lastError)
+	})
+}
+
+// DeleteNotification removes a notification from the outbox with telemetry.
+func (r *InstrumentedDownloadRepository) DeleteNotification(id int64) error {
+	return r.telemetry.InstrumentDBOperation(context.Background(), "delete_notification", func(ctx context.Context) error {
+		return r.repo.DeleteNotification(id)
+	})
+}
+
+// DropNotifications removes a target's notifications from the outbox with
+// telemetry.
+func (r *InstrumentedDownloadRepository) DropNotifications(target string) (int, error) {
+	var dropped int
+
+	err := r.telemetry.InstrumentDBOperation(context.Background(), "drop_notifications", func(ctx context.Context) error {
+		var err error
+
+		dropped, err = r.repo.DropNotifications(target)
+
+		return err
+	})
+
+	return dropped, err
+}
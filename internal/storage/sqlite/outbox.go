@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/storage"
+)
+
+// EnqueueNotification adds a message for target and drops its oldest beyond
+// limit, in one transaction.
+func (r *DownloadRepository) EnqueueNotification(target string, payload []byte, limit int) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	if _, err := tx.Exec(`INSERT INTO notification_outbox (target, payload, next_attempt_at, created_at) VALUES (?, ?, ?, ?)`,
+		target, payload, now, now); err != nil {
+		return 0, err
+	}
+
+	var dropped int64
+
+	if limit > 0 {
+		res, err := tx.Exec(`DELETE FROM notification_outbox WHERE id IN (
+			SELECT id FROM notification_outbox WHERE target = ? ORDER BY id DESC LIMIT -1 OFFSET ?
+		)`, target, limit)
+		if err != nil {
+			return 0, err
+		}
+
+		dropped, _ = res.RowsAffected()
+	}
+
+	return int(dropped), tx.Commit()
+}
+
+// NextNotification returns target's oldest message, or storage.ErrOutboxEmpty.
+func (r *DownloadRepository) NextNotification(target string) (storage.OutboxMessage, error) {
+	var (
+		msg                      storage.OutboxMessage
+		nextAttemptAt, createdAt string
+	)
+
+	err := r.db.QueryRow(`SELECT id, target, payload, attempts, next_attempt_at, created_at
+		FROM notification_outbox WHERE target = ? ORDER BY id LIMIT 1`, target).
+		Scan(&msg.ID, &msg.Target, &msg.Payload, &msg.Attempts, &nextAttemptAt, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.OutboxMessage{}, storage.ErrOutboxEmpty
+	}
+
+	if err != nil {
+		return storage.OutboxMessage{}, err
+	}
+
+	if msg.NextAttemptAt, err = time.Parse(time.RFC3339Nano, nextAttemptAt); err != nil {
+		return storage.OutboxMessage{}, fmt.Errorf("invalid next attempt time for notification %d: %w", msg.ID, err)
+	}
+
+	if msg.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return storage.OutboxMessage{}, fmt.Errorf("invalid creation time for notification %d: %w", msg.ID, err)
+	}
+
+	return msg, nil
+}
+
+// RetryNotification counts a failed attempt and schedules the next.
+func (r *DownloadRepository) RetryNotification(id int64, nextAttemptAt time.Time, lastError string) error {
+	_, err := r.db.Exec(`UPDATE notification_outbox SET attempts = attempts + 1, next_attempt_at = ?, last_error = ? WHERE id = ?`,
+		nextAttemptAt.UTC().Format(time.RFC3339Nano), lastError, id)
+
+	return err
+}
+
+// DeleteNotification removes a message.
+func (r *DownloadRepository) DeleteNotification(id int64) error {
+	_, err := r.db.Exec(`DELETE FROM notification_outbox WHERE id = ?`, id)
+
+	return err
+}
+
+// DropNotifications removes every message for target.
+func (r *DownloadRepository) DropNotifications(target string) (int, error) {
+	res, err := r.db.Exec(`DELETE FROM notification_outbox WHERE target = ?`, target)
+	if err != nil {
+		return 0, err
+	}
+
+	dropped, _ := res.RowsAffected()
+
+	return int(dropped), nil
+}
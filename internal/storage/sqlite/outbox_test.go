@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationOutbox_OldestFirstPerTarget(t *testing.T) {
+	repo := newTestRepo(t)
+
+	for _, msg := range []struct{ target, payload string }{{"slack", "1"}, {"discord", "2"}, {"slack", "3"}} {
+		_, err := repo.EnqueueNotification(msg.target, []byte(msg.payload), 0)
+		require.NoError(t, err)
+	}
+
+	next, err := repo.NextNotification("slack")
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(next.Payload))
+	assert.Equal(t, "slack", next.Target)
+	assert.Zero(t, next.Attempts)
+	assert.WithinDuration(t, time.Now(), next.NextAttemptAt, time.Minute)
+
+	require.NoError(t, repo.DeleteNotification(next.ID))
+
+	next, err = repo.NextNotification("slack")
+	require.NoError(t, err)
+	assert.Equal(t, "3", string(next.Payload))
+
+	require.NoError(t, repo.DeleteNotification(next.ID))
+
+	_, err = repo.NextNotification("slack")
+	require.ErrorIs(t, err, storage.ErrOutboxEmpty)
+
+	next, err = repo.NextNotification("discord")
+	require.NoError(t, err)
+	assert.Equal(t, "2", string(next.Payload))
+}
+
+func TestNotificationOutbox_RetryReschedules(t *testing.T) {
+	repo := newTestRepo(t)
+
+	_, err := repo.EnqueueNotification("slack", []byte("1"), 0)
+	require.NoError(t, err)
+
+	next, err := repo.NextNotification("slack")
+	require.NoError(t, err)
+
+	later := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	require.NoError(t, repo.RetryNotification(next.ID, later, "status 429"))
+
+	next, err = repo.NextNotification("slack")
+	require.NoError(t, err)
+	assert.Equal(t, 1, next.Attempts)
+	assert.True(t, later.Equal(next.NextAttemptAt))
+}
+
+// A full outbox makes room by dropping the target's oldest messages, and only
+// the target's.
+func TestNotificationOutbox_DropsOldestBeyondLimit(t *testing.T) {
+	repo := newTestRepo(t)
+
+	_, err := repo.EnqueueNotification("discord", []byte("other"), 2)
+	require.NoError(t, err)
+
+	for i, payload := range []string{"1", "2", "3", "4"} {
+		dropped, err := repo.EnqueueNotification("slack", []byte(payload), 2)
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 0, 1, 1}[i], dropped, payload)
+	}
+
+	next, err := repo.NextNotification("slack")
+	require.NoError(t, err)
+	assert.Equal(t, "3", string(next.Payload))
+
+	next, err = repo.NextNotification("discord")
+	require.NoError(t, err)
+	assert.Equal(t, "other", string(next.Payload))
+}
+
+func TestNotificationOutbox_DropsATargetWhole(t *testing.T) {
+	repo := newTestRepo(t)
+
+	for _, target := range []string{"slack", "slack", "discord"} {
+		_, err := repo.EnqueueNotification(target, []byte(target), 0)
+		require.NoError(t, err)
+	}
+
+	dropped, err := repo.DropNotifications("slack")
+	require.NoError(t, err)
+	assert.Equal(t, 2, dropped)
+
+	_, err = repo.NextNotification("slack")
+	require.ErrorIs(t, err, storage.ErrOutboxEmpty)
+
+	_, err = repo.NextNotification("discord")
+	require.NoError(t, err)
+}
@@ -1,11 +1,34 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 var (
 	ErrDownloaded = errors.New("Download already completed")
+
+	// ErrTransferNotFound is returned when a transfer has no row in the ledger --
+	// it was never claimed here, or it has been forgotten since.
+	ErrTransferNotFound = errors.New("transfer not found")
+
+	// ErrOutboxEmpty is returned when a notification target has nothing waiting.
+	ErrOutboxEmpty = errors.New("no notification waiting")
+
+	// ErrNotRetryable is returned when a transfer asked to be retried has not
+	// failed: it is in flight, or done.
+	ErrNotRetryable = errors.New("only a failed or missing transfer can be retried")
 )
 
+// retryableStatuses are the states a transfer can be retried from. A transfer
+// in any other state is either in flight, done, or has never failed.
+var retryableStatuses = map[string]bool{
+	"failed":        true,
+	"missing":       true,
+	"import_failed": true,
+}
+
 // DownloadRecord represents a record of a downloaded file.
 type DownloadRecord struct {
 	DownloadID   string
@@ -15,8 +38,84 @@ type DownloadRecord struct {
 	LockedBy     string
 }
 
+// TransferEvent is one entry in a transfer's history, oldest first: a status
+// change, or something recorded against the transfer that left its status alone.
+type TransferEvent struct {
+	Status string
+	At     string
+}
+
 type DownloadRepository interface {
 	GetDownloads() ([]DownloadRecord, error)              // get all downloads
 	ClaimTransfer(transferID string) (bool, error)        // atomically claim a transfer
 	UpdateTransferStatus(transferID, status string) error // update status after download
+	RecordTransferEvent(transferID, event string) error   // note something in the history, status unchanged
+}
+
+// TransferAdmin is the administrative side of the ledger: the operations a person
+// performs on a transfer, as opposed to the ones the pipeline performs on its own.
+type TransferAdmin interface {
+	GetDownload(transferID string) (DownloadRecord, error)
+	GetTransferHistory(transferID string) ([]TransferEvent, error)
+	// ResetTransfer puts a transfer back to pending and releases its claim, so the
+	// next poll claims and downloads it again.
+	ResetTransfer(transferID string) error
+	// ForgetTransfer deletes a transfer's row and its history. A transfer still
+	// present on the seedbox under the label is claimed afresh on the next poll.
+	ForgetTransfer(transferID string) error
+}
+
+// RetryTransfer releases a failed or missing transfer so the next poll claims it
+// again, and returns the status it was released from. A transfer in any other
+// state is left alone, with ErrNotRetryable.
+func RetryTransfer(admin TransferAdmin, transferID string) (string, error) {
+	record, err := admin.GetDownload(transferID)
+	if err != nil {
+		return "", err
+	}
+
+	if !retryableStatuses[record.Status] {
+		return record.Status, fmt.Errorf("%w; it is %s", ErrNotRetryable, record.Status)
+	}
+
+	return record.Status, admin.ResetTransfer(transferID)
+}
+
+// TransferOwners records which *arr instance added each transfer, so that its
+// import is looked for in that instance alone. Transfers added some other way --
+// on the seedbox directly, or by an app that did not identify itself -- have none.
+type TransferOwners interface {
+	SetTransferOwner(transferID, owner string) error
+	// TransferOwner returns "" for a transfer with no recorded owner.
+	TransferOwner(transferID string) (string, error)
+}
+
+// OutboxMessage is a notification waiting to be sent to one target.
+type OutboxMessage struct {
+	ID      int64
+	Target  string
+	Payload []byte
+	// Attempts is how many times sending it has failed.
+	Attempts      int
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+}
+
+// NotificationOutbox keeps notifications until they have been sent, so that
+// neither a restart nor a service being down loses them. Each target's messages
+// are sent in the order they were added.
+type NotificationOutbox interface {
+	// EnqueueNotification adds a message for target and, beyond limit waiting
+	// for it, drops its oldest. It returns how many were dropped. A limit of zero
+	// or less keeps everything.
+	EnqueueNotification(target string, payload []byte, limit int) (int, error)
+	// NextNotification returns target's oldest message, or ErrOutboxEmpty.
+	NextNotification(target string) (OutboxMessage, error)
+	// RetryNotification records a failed attempt and when to make the next.
+	RetryNotification(id int64, nextAttemptAt time.Time, lastError string) error
+	// DeleteNotification removes a message sent, or given up on.
+	DeleteNotification(id int64) error
+	// DropNotifications removes every message waiting for target, and returns
+	// how many there were.
+	DropNotifications(target string) (int, error)
 }
@@ -3,35 +3,227 @@ package arr
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/italolelis/seedbox_downloader/internal/pathmap"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
 )
 
+// App is which *arr application a client talks to. The apps share one codebase
+// and most of its API, but not the API version, the name of the history event
+// for an import, or the command that imports a download.
+type App string
+
+const (
+	Sonarr App = "sonarr"
+	Radarr App = "radarr"
+	// Lidarr and Readarr are still on API v1.
+	Lidarr  App = "lidarr"
+	Readarr App = "readarr"
+	// Whisparr is the Sonarr-based v2, which files scenes as episodes.
+	Whisparr App = "whisparr"
+)
+
+// appSpec is what differs between the apps.
+type appSpec struct {
+	apiVersion string
+	// importedEvent is the history event for a download the app has imported,
+	// and importedCode its value in the eventType filter.
+	importedEvent string
+	importedCode  int
+	// scanCommand imports a finished download; empty when it is not known.
+	scanCommand string
+}
+
+// defaultSpec is used for a client not told its app: the API v3 the apps most
+// commonly speak, without a scan command.
+var defaultSpec = appSpec{apiVersion: "v3", importedEvent: "downloadFolderImported", importedCode: 3}
+
+var appSpecs = map[App]appSpec{
+	Sonarr:   {apiVersion: "v3", importedEvent: "downloadFolderImported", importedCode: 3, scanCommand: "DownloadedEpisodesScan"},
+	Radarr:   {apiVersion: "v3", importedEvent: "downloadFolderImported", importedCode: 3, scanCommand: "DownloadedMoviesScan"},
+	Lidarr:   {apiVersion: "v1", importedEvent: "trackFileImported", importedCode: 3, scanCommand: "DownloadedAlbumsScan"},
+	Readarr:  {apiVersion: "v1", importedEvent: "bookFileImported", importedCode: 3, scanCommand: "DownloadedBooksScan"},
+	Whisparr: {apiVersion: "v3", importedEvent: "downloadFolderImported", importedCode: 3, scanCommand: "DownloadedEpisodesScan"},
+}
+
+// ParseApp returns the App named s, case-insensitively.
+func ParseApp(s string) (App, error) {
+	app := App(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := appSpecs[app]; !ok {
+		return "", fmt.Errorf("unknown *arr application %q", s)
+	}
+
+	return app, nil
+}
+
 // Client represents an *arr API client.
 type Client struct {
 	client  *http.Client
 	apiKey  string
 	baseURL string
+	app     App
+	name    string
+	paths   pathmap.Mappings
+	history *historyCache
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithApp tells the client which application it talks to. Without it the client
+// speaks API v3 and can check imports, but cannot ask for one.
+func WithApp(app App) Option {
+	return func(c *Client) {
+		c.app = app
+	}
+}
+
+// WithName names the instance, for telling apart several of the same app.
+func WithName(name string) Option {
+	return func(c *Client) {
+		c.name = name
+	}
+}
+
+// WithPathMappings tells the client where the app mounts what this process sees
+// locally. Paths are passed to the client as local paths and translated on the
+// way out.
+func WithPathMappings(mappings pathmap.Mappings) Option {
+	return func(c *Client) {
+		c.paths = mappings
+	}
 }
 
 // NewClient creates a new *arr API client.
-func NewClient(apiKey, baseURL string) *Client {
-	return &Client{
+func NewClient(apiKey, baseURL string, opts ...Option) *Client {
+	c := &Client{
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 		apiKey:  apiKey,
-		baseURL: baseURL,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
+}
+
+// App reports which application the client talks to, or "" when it was not told.
+func (c *Client) App() App {
+	return c.app
+}
+
+// Name is the instance's name, defaulting to its app.
+func (c *Client) Name() string {
+	if c.name == "" {
+		return string(c.app)
+	}
+
+	return c.name
 }
 
+// PathMappings returns the client's path mappings.
+func (c *Client) PathMappings() pathmap.Mappings {
+	return c.paths
+}
+
+func (c *Client) spec() appSpec {
+	if spec, ok := appSpecs[c.app]; ok {
+		return spec
+	}
+
+	return defaultSpec
+}
+
+// endpoint is the URL of path under the app's API.
+func (c *Client) endpoint(path string) string {
+	return c.baseURL + "/api/" + c.spec().apiVersion + path
+}
+
+// ErrUnauthorized is an app refusing the API key.
+var ErrUnauthorized = errors.New("the API key was refused")
+
+// ErrAPIVersion is an app not serving the API version the client speaks: one
+// too old, another app than the client was told, or no *arr app at all.
+var ErrAPIVersion = errors.New("the API version is not served")
+
+// SystemStatus is what an app reports of itself.
+type SystemStatus struct {
+	AppName string `json:"appName"`
+	Version string `json:"version"`
+}
+
+// APIVersion is the version of the API the client speaks.
+func (c *Client) APIVersion() string {
+	return c.spec().apiVersion
+}
+
+// Ping checks that the application is reachable and accepts the API key.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.SystemStatus(ctx)
+
+	return err
+}
+
+// SystemStatus asks the application what it is. It fails with ErrUnauthorized
+// when the API key is refused, and ErrAPIVersion when the client's API version
+// is not served.
+func (c *Client) SystemStatus(ctx context.Context) (SystemStatus, error) {
+	url := c.endpoint("/system/status")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return SystemStatus{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Api-Key", c.apiKey)
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return SystemStatus{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return SystemStatus{}, fmt.Errorf("%w: url: %s, status: %d", ErrUnauthorized, url, resp.StatusCode)
+	case http.StatusNotFound:
+		return SystemStatus{}, fmt.Errorf("%w: url: %s, status: %d", ErrAPIVersion, url, resp.StatusCode)
+	default:
+		return SystemStatus{}, fmt.Errorf("url: %s, status: %d", url, resp.StatusCode)
+	}
+
+	var status SystemStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return SystemStatus{}, fmt.Errorf("%w: url: %s, unreadable status: %v", ErrAPIVersion, url, err)
+	}
+
+	return status, nil
+}
+
+// historyPageSize is the page size for the path fallback, which has to scan.
+const historyPageSize = 1000
+
 type HistoryRecord struct {
-	EventType string                 `json:"eventType"`
-	Data      map[string]interface{} `json:"data"`
+	ID         int                    `json:"id"`
+	Date       time.Time              `json:"date"`
+	EventType  string                 `json:"eventType"`
+	DownloadID string                 `json:"downloadId"`
+	Data       map[string]interface{} `json:"data"`
 }
 
 type HistoryResponse struct {
@@ -39,52 +231,221 @@ type HistoryResponse struct {
 	TotalRecords int             `json:"totalRecords"`
 }
 
-// CheckImported checks if a target path has been imported into the *arr application.
-func (c *Client) CheckImported(ctx context.Context, target string) (bool, error) {
-	inspected := 0
-	page := 0
+// The history events for a download the app gave up on, named alike by every app.
+const (
+	eventDownloadFailed  = "downloadFailed"
+	eventDownloadIgnored = "downloadIgnored"
+)
 
-	for {
-		url := fmt.Sprintf("%s/api/v3/history?includeSeries=false&includeEpisode=false&page=%d&pageSize=1000", c.baseURL, page)
+// ImportState is where a download stands with an app.
+type ImportState string
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-		if err != nil {
-			return false, fmt.Errorf("failed to create request: %w", err)
-		}
+const (
+	// ImportPending is a download the app has not finished with, or has never
+	// heard of.
+	ImportPending ImportState = "pending"
+	Imported      ImportState = "imported"
+	// ImportFailed is a download the app marked failed, and will usually replace
+	// with another release.
+	ImportFailed ImportState = "failed"
+	// ImportIgnored is a download someone told the app to stop tracking.
+	ImportIgnored ImportState = "ignored"
+)
 
-		req.Header.Set("X-Api-Key", c.apiKey)
+// ImportStatus is an ImportState with the app's reason, when it gave one.
+type ImportStatus struct {
+	State   ImportState
+	Message string
+	// Paths are the files the app has imported so far, as local paths. A download
+	// can be Imported with files still to go: a season pack is imported episode
+	// by episode. Empty when the app did not record which files it took.
+	Paths []string
+}
 
-		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+// CheckImported reports whether a download has been imported into the *arr
+// application. See CheckImport.
+func (c *Client) CheckImported(ctx context.Context, downloadID string, paths []string) (bool, error) {
+	status, err := c.CheckImport(ctx, downloadID, paths)
+	if err != nil {
+		return false, err
+	}
 
-		resp, err := c.client.Do(req)
-		if err != nil {
-			return false, fmt.Errorf("failed to send request: %w", err)
+	return status.State == Imported, nil
+}
+
+// CheckImport reports where a download stands with the *arr application. It
+// looks the download up by its id first, which is one small request and is
+// immune to the app seeing the files under a different path. Only when the app
+// has no history for that id at all -- the download was not tracked under it --
+// are the imports searched for one dropped from any of paths, which are local
+// and translated to the app's view before comparing. Failures are only known by
+// id: an untracked download can be found imported, never failed. A client made
+// WithHistoryCache looks in its cache instead.
+func (c *Client) CheckImport(ctx context.Context, downloadID string, paths []string) (ImportStatus, error) {
+	if c.history != nil {
+		return c.checkImportFromHistory(ctx, downloadID, paths)
+	}
+
+	status, known, err := c.checkImportByDownloadID(ctx, downloadID)
+	if err != nil {
+		return ImportStatus{}, err
+	}
+
+	if known {
+		return status, nil
+	}
+
+	imported, err := c.checkImportedByPath(ctx, paths)
+	if err != nil || len(imported) == 0 {
+		return ImportStatus{State: ImportPending}, err
+	}
+
+	return ImportStatus{State: Imported, Paths: imported}, nil
+}
+
+// checkImportByDownloadID reports where the app has got to with the download,
+// and whether it has any history for it at all.
+func (c *Client) checkImportByDownloadID(ctx context.Context, downloadID string) (status ImportStatus, known bool, err error) {
+	query := url.Values{}
+	// The apps record the id in upper case, as they do for every Transmission
+	// hash, and match the filter exactly.
+	query.Set("downloadId", strings.ToUpper(downloadID))
+	query.Set("page", "1")
+	query.Set("pageSize", "100")
+
+	history, err := c.getHistory(ctx, query)
+	if err != nil {
+		return ImportStatus{}, false, err
+	}
+
+	status, known = c.importStatus(history.Records, downloadID)
+
+	return status, known, nil
+}
+
+// importStatus reads where a download stands from records, newest first, and
+// whether any of them is the download's at all. Imports anywhere in the records
+// win; otherwise the most recent failure or ignore does.
+func (c *Client) importStatus(records []HistoryRecord, downloadID string) (status ImportStatus, known bool) {
+	status = ImportStatus{State: ImportPending}
+
+	var imported *ImportStatus
+
+	for _, record := range records {
+		// Versions without the downloadId filter ignore it and return everything,
+		// so the id is checked here too.
+		if !strings.EqualFold(record.DownloadID, downloadID) {
+			continue
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			return false, fmt.Errorf("url: %s, status: %d", url, resp.StatusCode)
+		known = true
+
+		switch record.EventType {
+		case c.spec().importedEvent:
+			if imported == nil {
+				imported = &ImportStatus{State: Imported}
+			}
+
+			if droppedPath, ok := record.Data["droppedPath"].(string); ok && droppedPath != "" {
+				imported.Paths = append(imported.Paths, c.paths.ToLocal(droppedPath))
+			}
+		case eventDownloadFailed, eventDownloadIgnored:
+			if status.State != ImportPending {
+				continue
+			}
+
+			status.State = ImportFailed
+			if record.EventType == eventDownloadIgnored {
+				status.State = ImportIgnored
+			}
+
+			status.Message, _ = record.Data["message"].(string)
 		}
+	}
+
+	if imported != nil {
+		return *imported, true
+	}
+
+	return status, known
+}
+
+// checkImportedByPath pages through the app's imports for ones dropped from any
+// of paths, and returns those paths. It stops early once every path is found.
+func (c *Client) checkImportedByPath(ctx context.Context, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	remote := make(map[string]string, len(paths))
+	for _, p := range paths {
+		remote[c.paths.ToRemote(p)] = p
+	}
+
+	var found []string
+
+	inspected := 0
+
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("eventType", strconv.Itoa(c.spec().importedCode))
+		query.Set("sortKey", "date")
+		query.Set("sortDirection", "descending")
+		query.Set("page", strconv.Itoa(page))
+		query.Set("pageSize", strconv.Itoa(historyPageSize))
 
-		var historyResponse HistoryResponse
-		if err := json.NewDecoder(resp.Body).Decode(&historyResponse); err != nil {
-			return false, fmt.Errorf("failed to decode response: %w", err)
+		history, err := c.getHistory(ctx, query)
+		if err != nil {
+			return nil, err
 		}
 
-		for _, record := range historyResponse.Records {
-			if record.EventType == "downloadFolderImported" {
-				if droppedPath, ok := record.Data["droppedPath"].(string); ok && droppedPath == target {
-					return true, nil
+		for _, record := range history.Records {
+			if record.EventType == c.spec().importedEvent {
+				droppedPath, _ := record.Data["droppedPath"].(string)
+				if local, ok := remote[droppedPath]; ok {
+					found = append(found, local)
+					delete(remote, droppedPath)
 				}
 			}
 
 			inspected++
 		}
 
-		if historyResponse.TotalRecords > inspected {
-			page++
-		} else {
-			return false, nil
+		if len(remote) == 0 || len(history.Records) == 0 || inspected >= history.TotalRecords {
+			return found, nil
 		}
 	}
 }
+
+func (c *Client) getHistory(ctx context.Context, query url.Values) (HistoryResponse, error) {
+	query.Set("includeSeries", "false")
+	query.Set("includeEpisode", "false")
+
+	url := c.endpoint("/history?" + query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return HistoryResponse{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Api-Key", c.apiKey)
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return HistoryResponse{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return HistoryResponse{}, fmt.Errorf("url: %s, status: %d", url, resp.StatusCode)
+	}
+
+	var historyResponse HistoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&historyResponse); err != nil {
+		return HistoryResponse{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return historyResponse, nil
+}
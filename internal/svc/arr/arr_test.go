@@ -0,0 +1,269 @@
+package arr
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/italolelis/seedbox_downloader/internal/pathmap"
+	"github.com/italolelis/seedbox_downloader/test/servarr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const hash = "3f786850e387550fdab836ed7e6dc881de23001b"
+
+func TestCheckImported_ByDownloadID(t *testing.T) {
+	app := servarr.New(t,
+		servarr.Record{EventType: "grabbed", DownloadID: strings.ToUpper(hash)},
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: strings.ToUpper(hash), DroppedPath: "/remote/Show/e01.mkv"},
+	)
+	client := NewClient(servarr.APIKey, app.URL())
+
+	// The path the app saw is not ours; matching by id does not care.
+	imported, err := client.CheckImported(context.Background(), hash, []string{"/downloads/Show/e01.mkv"})
+	require.NoError(t, err)
+	assert.True(t, imported)
+
+	require.Len(t, app.Requests(), 1, "a tracked download needs one request, not a history scan")
+	assert.Contains(t, app.Requests()[0], "downloadId="+strings.ToUpper(hash))
+}
+
+func TestCheckImported_GrabbedButNotYetImported(t *testing.T) {
+	app := servarr.New(t,
+		servarr.Record{EventType: "grabbed", DownloadID: strings.ToUpper(hash)},
+		// Someone else's import from the same path must not count, and is not even
+		// looked for: the app knows this download, so its history is the answer.
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: "OTHER", DroppedPath: "/downloads/Show/e01.mkv"},
+	)
+	client := NewClient(servarr.APIKey, app.URL())
+
+	imported, err := client.CheckImported(context.Background(), hash, []string{"/downloads/Show/e01.mkv"})
+	require.NoError(t, err)
+	assert.False(t, imported)
+	assert.Len(t, app.Requests(), 1)
+}
+
+func TestCheckImported_FallsBackToPathForUnknownDownloads(t *testing.T) {
+	app := servarr.New(t,
+		servarr.Record{EventType: "grabbed", DownloadID: "OTHER", DroppedPath: "/downloads/Show/e01.mkv"},
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: "UNTRACKED", DroppedPath: "/downloads/Show/e01.mkv"},
+	)
+	client := NewClient(servarr.APIKey, app.URL())
+
+	imported, err := client.CheckImported(context.Background(), hash, []string{"/downloads/Show/e01.mkv"})
+	require.NoError(t, err)
+	assert.True(t, imported)
+
+	requests := app.Requests()
+	require.Len(t, requests, 2)
+	assert.Contains(t, requests[1], "eventType=3", "the fallback asks only for imports")
+}
+
+func TestCheckImported_PathFallbackPages(t *testing.T) {
+	records := []servarr.Record{{EventType: "downloadFolderImported", DownloadID: "X", DroppedPath: "/downloads/Show/e01.mkv"}}
+	for i := 0; i < historyPageSize; i++ {
+		records = append(records, servarr.Record{EventType: "downloadFolderImported", DownloadID: "Y", DroppedPath: "/downloads/other.mkv"})
+	}
+
+	app := servarr.New(t, records...)
+	client := NewClient(servarr.APIKey, app.URL())
+
+	imported, err := client.CheckImported(context.Background(), hash, []string{"/downloads/Show/e01.mkv"})
+	require.NoError(t, err)
+	assert.True(t, imported, "the oldest import is on the second page")
+	assert.Len(t, app.Requests(), 3)
+}
+
+// A season pack is imported an episode at a time. Every file imported so far is
+// reported, whichever way the download was found.
+func TestCheckImport_ReportsEachImportedFile(t *testing.T) {
+	app := servarr.New(t,
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: strings.ToUpper(hash), DroppedPath: "/data/Show/e01.mkv"},
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: strings.ToUpper(hash), DroppedPath: "/data/Show/e02.mkv"},
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: "OTHER", DroppedPath: "/data/Other/e01.mkv"},
+	)
+	client := NewClient(servarr.APIKey, app.URL(), WithPathMappings(pathmap.Mappings{{Local: "/downloads", Remote: "/data"}}))
+
+	status, err := client.CheckImport(context.Background(), hash, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Imported, status.State)
+	assert.ElementsMatch(t, []string{"/downloads/Show/e01.mkv", "/downloads/Show/e02.mkv"}, status.Paths)
+
+	status, err = client.CheckImport(context.Background(), "0000",
+		[]string{"/downloads/Other/e01.mkv", "/downloads/Other/e02.mkv", "/downloads/Show/e01.mkv"})
+	require.NoError(t, err)
+	assert.Equal(t, Imported, status.State)
+	assert.ElementsMatch(t, []string{"/downloads/Other/e01.mkv", "/downloads/Show/e01.mkv"}, status.Paths)
+}
+
+func TestCheckImported_NothingImported(t *testing.T) {
+	app := servarr.New(t)
+	client := NewClient(servarr.APIKey, app.URL())
+
+	imported, err := client.CheckImported(context.Background(), hash, []string{"/downloads/Show/e01.mkv"})
+	require.NoError(t, err)
+	assert.False(t, imported)
+}
+
+// Versions without the downloadId filter return the whole history for it. Their
+// answer must not be mistaken for this download's history.
+func TestCheckImported_ServerIgnoringFilters(t *testing.T) {
+	app := servarr.New(t,
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: "OTHER", DroppedPath: "/downloads/Other/e01.mkv"},
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: strings.ToUpper(hash), DroppedPath: "/remote/Show/e01.mkv"},
+	)
+	app.IgnoreFilters()
+
+	client := NewClient(servarr.APIKey, app.URL())
+
+	imported, err := client.CheckImported(context.Background(), hash, []string{"/downloads/Show/e01.mkv"})
+	require.NoError(t, err)
+	assert.True(t, imported)
+
+	imported, err = client.CheckImported(context.Background(), "0000", []string{"/downloads/Show/e01.mkv"})
+	require.NoError(t, err)
+	assert.False(t, imported, "another download's import is not this one's")
+}
+
+func TestCheckImported_WrongKey(t *testing.T) {
+	app := servarr.New(t)
+	client := NewClient("wrong", app.URL())
+
+	_, err := client.CheckImported(context.Background(), hash, nil)
+	assert.Error(t, err)
+}
+
+func TestCheckImported_LidarrOnAPIv1(t *testing.T) {
+	app := servarr.New(t,
+		servarr.Record{EventType: "grabbed", DownloadID: strings.ToUpper(hash)},
+		servarr.Record{EventType: "trackFileImported", DownloadID: strings.ToUpper(hash), DroppedPath: "/downloads/Album/01.flac"},
+	)
+	app.UseAPIVersion("v1")
+	client := NewClient(servarr.APIKey, app.URL(), WithApp(Lidarr))
+
+	imported, err := client.CheckImported(context.Background(), hash, nil)
+	require.NoError(t, err)
+	assert.True(t, imported)
+}
+
+func TestCheckImported_ReadarrPathFallback(t *testing.T) {
+	app := servarr.New(t,
+		// Another app's event name means nothing to Readarr.
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: "OTHER", DroppedPath: "/downloads/Other/book.epub"},
+		servarr.Record{EventType: "bookFileImported", DownloadID: "OTHER", DroppedPath: "/downloads/Book/book.epub"},
+	)
+	app.UseAPIVersion("v1")
+	client := NewClient(servarr.APIKey, app.URL(), WithApp(Readarr))
+
+	imported, err := client.CheckImported(context.Background(), hash, []string{"/downloads/Book/book.epub"})
+	require.NoError(t, err)
+	assert.True(t, imported)
+
+	imported, err = client.CheckImported(context.Background(), hash, []string{"/downloads/Other/book.epub"})
+	require.NoError(t, err)
+	assert.False(t, imported)
+}
+
+// The app mounts the download volume elsewhere; the paths it records are
+// translated before they are compared with ours.
+func TestCheckImported_PathFallbackTranslatesPaths(t *testing.T) {
+	app := servarr.New(t,
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: "OTHER", DroppedPath: "/data/torrents/Show/e01.mkv"},
+	)
+	client := NewClient(servarr.APIKey, app.URL(),
+		WithPathMappings(pathmap.Mappings{{Local: "/downloads", Remote: "/data/torrents"}}))
+
+	imported, err := client.CheckImported(context.Background(), hash, []string{"/downloads/Show/e01.mkv"})
+	require.NoError(t, err)
+	assert.True(t, imported)
+}
+
+func TestCheckImport_FailedAndIgnored(t *testing.T) {
+	id := strings.ToUpper(hash)
+
+	tests := []struct {
+		name    string
+		records []servarr.Record
+		want    ImportStatus
+	}{
+		{
+			name: "failed",
+			records: []servarr.Record{
+				{EventType: "grabbed", DownloadID: id},
+				{EventType: "downloadFailed", DownloadID: id, Message: "Sample detected"},
+			},
+			want: ImportStatus{State: ImportFailed, Message: "Sample detected"},
+		},
+		{
+			name: "ignored",
+			records: []servarr.Record{
+				{EventType: "grabbed", DownloadID: id},
+				{EventType: "downloadIgnored", DownloadID: id},
+			},
+			want: ImportStatus{State: ImportIgnored},
+		},
+		{
+			name: "the most recent refusal wins",
+			records: []servarr.Record{
+				{EventType: "downloadIgnored", DownloadID: id},
+				{EventType: "downloadFailed", DownloadID: id},
+			},
+			want: ImportStatus{State: ImportFailed},
+		},
+		{
+			name: "an import wins over a refusal",
+			records: []servarr.Record{
+				{EventType: "downloadFailed", DownloadID: id},
+				{EventType: "downloadFolderImported", DownloadID: id},
+			},
+			want: ImportStatus{State: Imported},
+		},
+		{
+			name:    "someone else's failure",
+			records: []servarr.Record{{EventType: "downloadFailed", DownloadID: "OTHER"}},
+			want:    ImportStatus{State: ImportPending},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := servarr.New(t, tt.records...)
+			client := NewClient(servarr.APIKey, app.URL())
+
+			status, err := client.CheckImport(context.Background(), hash, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, status)
+		})
+	}
+}
+
+func TestParseApp(t *testing.T) {
+	app, err := ParseApp(" Lidarr ")
+	require.NoError(t, err)
+	assert.Equal(t, Lidarr, app)
+
+	_, err = ParseApp("prowlarr")
+	require.Error(t, err)
+}
+
+func TestPing(t *testing.T) {
+	app := servarr.New(t)
+
+	require.NoError(t, NewClient(servarr.APIKey, app.URL()).Ping(context.Background()))
+	assert.Error(t, NewClient("wrong", app.URL()).Ping(context.Background()))
+}
+
+func TestSystemStatus(t *testing.T) {
+	app := servarr.New(t)
+
+	status, err := NewClient(servarr.APIKey, app.URL(), WithApp(Sonarr)).SystemStatus(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, SystemStatus{AppName: "Servarr", Version: "4.0.0"}, status)
+
+	_, err = NewClient("wrong", app.URL(), WithApp(Sonarr)).SystemStatus(context.Background())
+	require.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = NewClient(servarr.APIKey, app.URL(), WithApp(Lidarr)).SystemStatus(context.Background())
+	require.ErrorIs(t, err, ErrAPIVersion, "v3 is served, not the v1 Lidarr speaks")
+}
@@ -0,0 +1,116 @@
+package arr
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/pathmap"
+	"github.com/italolelis/seedbox_downloader/test/servarr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanDownload_SendsTheAppsCommand(t *testing.T) {
+	for _, tc := range []struct {
+		app        App
+		apiVersion string
+		name       string
+	}{
+		{Sonarr, "v3", "DownloadedEpisodesScan"},
+		{Radarr, "v3", "DownloadedMoviesScan"},
+		{Lidarr, "v1", "DownloadedAlbumsScan"},
+		{Readarr, "v1", "DownloadedBooksScan"},
+		{Whisparr, "v3", "DownloadedEpisodesScan"},
+	} {
+		app, name := tc.app, tc.name
+
+		t.Run(string(app), func(t *testing.T) {
+			fake := servarr.New(t)
+			fake.UseAPIVersion(tc.apiVersion)
+			client := NewClient(servarr.APIKey, fake.URL(), WithApp(app))
+
+			command, err := client.ScanDownload(context.Background(), hash, "/downloads/Show")
+			require.NoError(t, err)
+			assert.Equal(t, CommandQueued, command.Status)
+			assert.False(t, command.Finished())
+
+			assert.Equal(t, []servarr.Command{{
+				ID:               command.ID,
+				Name:             name,
+				Path:             "/downloads/Show",
+				DownloadClientID: strings.ToUpper(hash),
+			}}, fake.Commands())
+		})
+	}
+}
+
+func TestScanDownload_SendsThePathAsTheAppSeesIt(t *testing.T) {
+	fake := servarr.New(t)
+	client := NewClient(servarr.APIKey, fake.URL(), WithApp(Sonarr),
+		WithPathMappings(pathmap.Mappings{{Local: "/downloads", Remote: "/data/torrents"}}))
+
+	_, err := client.ScanDownload(context.Background(), hash, "/downloads/Show")
+	require.NoError(t, err)
+
+	require.Len(t, fake.Commands(), 1)
+	assert.Equal(t, "/data/torrents/Show", fake.Commands()[0].Path)
+}
+
+func TestScanDownload_UnknownApp(t *testing.T) {
+	fake := servarr.New(t)
+	client := NewClient(servarr.APIKey, fake.URL())
+
+	_, err := client.ScanDownload(context.Background(), hash, "/downloads/Show")
+	require.ErrorIs(t, err, ErrScanUnsupported)
+	assert.Empty(t, fake.Commands())
+}
+
+func TestWaitForCommand_UntilFinished(t *testing.T) {
+	fake := servarr.New(t)
+
+	checks := 0
+	fake.OnCommand(func(servarr.Command) string {
+		checks++
+		if checks < 3 {
+			return CommandStarted
+		}
+
+		return CommandFailed
+	})
+
+	client := NewClient(servarr.APIKey, fake.URL(), WithApp(Sonarr))
+
+	queued, err := client.ScanDownload(context.Background(), hash, "/downloads/Show")
+	require.NoError(t, err)
+
+	command, err := client.WaitForCommand(context.Background(), queued.ID, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, CommandFailed, command.Status)
+	assert.False(t, command.Succeeded())
+	assert.Equal(t, 3, checks)
+}
+
+func TestWaitForCommand_GivesUpWithTheContext(t *testing.T) {
+	fake := servarr.New(t)
+	fake.OnCommand(func(servarr.Command) string { return CommandStarted })
+
+	client := NewClient(servarr.APIKey, fake.URL(), WithApp(Radarr))
+
+	queued, err := client.ScanDownload(context.Background(), hash, "/downloads/Movie")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = client.WaitForCommand(ctx, queued.ID, time.Millisecond)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCommand_Succeeded(t *testing.T) {
+	assert.True(t, Command{Status: CommandCompleted, Result: "successful"}.Succeeded())
+	assert.True(t, Command{Status: CommandCompleted}.Succeeded(), "versions without a result are taken at their status")
+	assert.False(t, Command{Status: CommandCompleted, Result: "unsuccessful"}.Succeeded())
+	assert.False(t, Command{Status: CommandAborted}.Succeeded())
+}
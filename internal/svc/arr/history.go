@@ -0,0 +1,253 @@
+package arr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// DefaultHistorySize is how many history records a cached client keeps.
+const DefaultHistorySize = 5000
+
+// WithHistoryCache has the client answer import checks from a copy of the app's
+// history instead of asking the app each time. The copy is brought up to date at
+// most once every maxAge, with only the records added since the last time, and
+// keeps the newest size records. Every transfer watched against the app shares
+// it, so a check costs one request per maxAge however many transfers there are.
+//
+// A download with no record among those kept is found by neither id nor path,
+// where an uncached client would page through the whole history for its path.
+func WithHistoryCache(maxAge time.Duration, size int) Option {
+	return func(c *Client) {
+		c.history = &historyCache{maxAge: maxAge, size: size}
+	}
+}
+
+// historyCache is the newest records of an app's history, indexed by download
+// id and by the path of each import.
+type historyCache struct {
+	maxAge time.Duration
+	size   int
+
+	// mu is held across a refresh, so checks arriving meanwhile wait for it and
+	// use its result rather than each asking the app.
+	mu        sync.Mutex
+	refreshed time.Time
+	// newest is the date of the newest record; the next refresh asks for records
+	// from then on.
+	newest time.Time
+	// records are oldest first.
+	records []HistoryRecord
+	seen    map[int]bool
+	// byDownloadID holds each download's records newest first, keyed in upper
+	// case.
+	byDownloadID map[string][]HistoryRecord
+	// imported holds the droppedPath of every import, as the app sees it.
+	imported map[string]bool
+}
+
+// expireHistory has the next check refresh the cache, whatever its age: the app
+// is known to have done something worth seeing, such as finishing an import.
+func (c *Client) expireHistory() {
+	if c.history == nil {
+		return
+	}
+
+	c.history.mu.Lock()
+	defer c.history.mu.Unlock()
+
+	c.history.refreshed = time.Time{}
+}
+
+// checkImportFromHistory is CheckImport answered from the cache.
+func (c *Client) checkImportFromHistory(ctx context.Context, downloadID string, paths []string) (ImportStatus, error) {
+	h := c.history
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := c.refreshHistory(ctx); err != nil {
+		return ImportStatus{}, err
+	}
+
+	if status, known := c.importStatus(h.byDownloadID[strings.ToUpper(downloadID)], downloadID); known {
+		return status, nil
+	}
+
+	var found []string
+
+	for _, p := range paths {
+		if h.imported[c.paths.ToRemote(p)] {
+			found = append(found, p)
+		}
+	}
+
+	if len(found) == 0 {
+		return ImportStatus{State: ImportPending}, nil
+	}
+
+	return ImportStatus{State: Imported, Paths: found}, nil
+}
+
+// refreshHistory brings the cache up to date if it is older than maxAge. The
+// first refresh fills it from the newest records back; later ones ask only for
+// what came after the newest record seen. h.mu must be held.
+func (c *Client) refreshHistory(ctx context.Context) error {
+	h := c.history
+
+	if !h.refreshed.IsZero() && time.Since(h.refreshed) < h.maxAge {
+		return nil
+	}
+
+	var (
+		records []HistoryRecord
+		err     error
+	)
+
+	if h.seen == nil {
+		records, err = c.getRecentHistory(ctx, h.size)
+	} else {
+		records, err = c.getHistorySince(ctx, h.newest)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if h.seen == nil {
+		h.seen = make(map[int]bool, len(records))
+	}
+
+	h.add(records, c.spec().importedEvent)
+	h.refreshed = time.Now()
+
+	return nil
+}
+
+// add takes in records not seen before, drops the oldest beyond size, and
+// rebuilds the indexes.
+func (h *historyCache) add(records []HistoryRecord, importedEvent string) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].Date.Equal(records[j].Date) {
+			return records[i].Date.Before(records[j].Date)
+		}
+
+		return records[i].ID < records[j].ID
+	})
+
+	for _, record := range records {
+		// A record dated exactly at the newest seen comes back on the next
+		// refresh, as the query is from that date on.
+		if h.seen[record.ID] {
+			continue
+		}
+
+		h.seen[record.ID] = true
+		h.records = append(h.records, record)
+
+		if record.Date.After(h.newest) {
+			h.newest = record.Date
+		}
+	}
+
+	if over := len(h.records) - h.size; over > 0 {
+		for _, record := range h.records[:over] {
+			delete(h.seen, record.ID)
+		}
+
+		h.records = append([]HistoryRecord(nil), h.records[over:]...)
+	}
+
+	h.byDownloadID = make(map[string][]HistoryRecord)
+	h.imported = make(map[string]bool)
+
+	for i := len(h.records) - 1; i >= 0; i-- {
+		record := h.records[i]
+
+		if record.DownloadID != "" {
+			id := strings.ToUpper(record.DownloadID)
+			h.byDownloadID[id] = append(h.byDownloadID[id], record)
+		}
+
+		if droppedPath, ok := record.Data["droppedPath"].(string); ok && droppedPath != "" && record.EventType == importedEvent {
+			h.imported[droppedPath] = true
+		}
+	}
+}
+
+// getRecentHistory returns up to limit of the newest records, paging back from
+// the newest.
+func (c *Client) getRecentHistory(ctx context.Context, limit int) ([]HistoryRecord, error) {
+	var records []HistoryRecord
+
+	for page := 1; len(records) < limit; page++ {
+		query := url.Values{}
+		query.Set("sortKey", "date")
+		query.Set("sortDirection", "descending")
+		query.Set("page", strconv.Itoa(page))
+		query.Set("pageSize", strconv.Itoa(min(historyPageSize, limit)))
+
+		history, err := c.getHistory(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, history.Records...)
+
+		if len(history.Records) == 0 || page*min(historyPageSize, limit) >= history.TotalRecords {
+			break
+		}
+	}
+
+	if len(records) > limit {
+		records = records[:limit]
+	}
+
+	return records, nil
+}
+
+// getHistorySince returns every record from date on, unpaged.
+func (c *Client) getHistorySince(ctx context.Context, date time.Time) ([]HistoryRecord, error) {
+	query := url.Values{}
+	query.Set("date", date.UTC().Format(time.RFC3339Nano))
+	query.Set("includeSeries", "false")
+	query.Set("includeEpisode", "false")
+
+	url := c.endpoint("/history/since?" + query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Api-Key", c.apiKey)
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("url: %s, status: %d", url, resp.StatusCode)
+	}
+
+	var records []HistoryRecord
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return records, nil
+}
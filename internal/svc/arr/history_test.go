@@ -0,0 +1,118 @@
+package arr
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/test/servarr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const otherHash = "da4b9237bacccdf19c0760cab7aec4a8359010b0"
+
+// However many downloads are checked, the app is asked once per refresh, and
+// after the first time only for what is new.
+func TestHistoryCache_SharedAndIncremental(t *testing.T) {
+	app := servarr.New(t,
+		servarr.Record{EventType: "grabbed", DownloadID: strings.ToUpper(hash)},
+		servarr.Record{EventType: "grabbed", DownloadID: strings.ToUpper(otherHash)},
+	)
+	client := NewClient(servarr.APIKey, app.URL(), WithHistoryCache(time.Hour, DefaultHistorySize))
+
+	for _, id := range []string{hash, otherHash, hash} {
+		status, err := client.CheckImport(context.Background(), id, nil)
+		require.NoError(t, err)
+		assert.Equal(t, ImportPending, status.State)
+	}
+
+	require.Len(t, app.Requests(), 1, "one fill serves every check")
+
+	app.Add(servarr.Record{EventType: "downloadFolderImported", DownloadID: strings.ToUpper(hash), DroppedPath: "/downloads/Show/e01.mkv"})
+
+	status, err := client.CheckImport(context.Background(), hash, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ImportPending, status.State, "the cache is not refreshed before it is due")
+
+	client.expireHistory()
+
+	status, err = client.CheckImport(context.Background(), hash, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Imported, status.State)
+	assert.Equal(t, []string{"/downloads/Show/e01.mkv"}, status.Paths)
+
+	status, err = client.CheckImport(context.Background(), otherHash, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ImportPending, status.State)
+
+	requests := app.Requests()
+	require.Len(t, requests, 2)
+	assert.Contains(t, requests[1], "date=2024-01-01T00%3A01%3A00Z", "only records from the newest seen on are asked for")
+}
+
+func TestHistoryCache_FindsUntrackedDownloadsByPath(t *testing.T) {
+	app := servarr.New(t,
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: "OTHER", DroppedPath: "/downloads/Show/e01.mkv"},
+		servarr.Record{EventType: "grabbed", DownloadID: "ANOTHER", DroppedPath: "/downloads/Show/e02.mkv"},
+	)
+	client := NewClient(servarr.APIKey, app.URL(), WithHistoryCache(time.Hour, DefaultHistorySize))
+
+	status, err := client.CheckImport(context.Background(), hash, []string{"/downloads/Show/e01.mkv", "/downloads/Show/e02.mkv"})
+	require.NoError(t, err)
+	assert.Equal(t, Imported, status.State)
+	assert.Equal(t, []string{"/downloads/Show/e01.mkv"}, status.Paths, "only imports count")
+}
+
+// The cache keeps the newest records and lets the oldest go.
+func TestHistoryCache_Bounded(t *testing.T) {
+	app := servarr.New(t,
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: strings.ToUpper(hash), DroppedPath: "/downloads/Show/e01.mkv"},
+		servarr.Record{EventType: "grabbed", DownloadID: "B"},
+	)
+	client := NewClient(servarr.APIKey, app.URL(), WithHistoryCache(time.Hour, 2))
+
+	status, err := client.CheckImport(context.Background(), hash, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Imported, status.State)
+
+	app.Add(servarr.Record{EventType: "grabbed", DownloadID: "C"})
+	client.expireHistory()
+
+	status, err = client.CheckImport(context.Background(), hash, []string{"/downloads/Show/e01.mkv"})
+	require.NoError(t, err)
+	assert.Equal(t, ImportPending, status.State, "the oldest record has made way for the newest")
+
+	status, err = client.CheckImport(context.Background(), "c", nil)
+	require.NoError(t, err)
+	assert.Equal(t, ImportPending, status.State)
+	assert.Len(t, client.history.records, 2)
+}
+
+// A finished command is a sign the app has done something, so the cache is not
+// left to age before the import it made is seen.
+func TestHistoryCache_RefreshedWhenACommandFinishes(t *testing.T) {
+	app := servarr.New(t, servarr.Record{EventType: "grabbed", DownloadID: strings.ToUpper(hash)})
+	app.OnCommand(func(cmd servarr.Command) string {
+		app.Add(servarr.Record{EventType: "downloadFolderImported", DownloadID: cmd.DownloadClientID, DroppedPath: cmd.Path + "/e01.mkv"})
+
+		return CommandCompleted
+	})
+
+	client := NewClient(servarr.APIKey, app.URL(), WithApp(Sonarr), WithHistoryCache(time.Hour, DefaultHistorySize))
+
+	status, err := client.CheckImport(context.Background(), hash, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ImportPending, status.State)
+
+	queued, err := client.ScanDownload(context.Background(), hash, "/downloads/Show")
+	require.NoError(t, err)
+
+	_, err = client.WaitForCommand(context.Background(), queued.ID, time.Millisecond)
+	require.NoError(t, err)
+
+	status, err = client.CheckImport(context.Background(), hash, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Imported, status.State)
+}
@@ -41,6 +41,12 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return rw.ResponseWriter.Write(b)
 }
 
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController, so
+// streaming handlers can still flush and clear deadlines through this wrapper.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // HTTPLogging middleware logs HTTP requests with appropriate level based on status code.
 // Requirements: HTTP-01, HTTP-02, HTTP-03, HTTP-04, HTTP-05, HTTP-06.
 func HTTPLogging(next http.Handler) http.Handler {
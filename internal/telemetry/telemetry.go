@@ -5,12 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
 	"go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/metric/noop"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
@@ -21,12 +26,15 @@ import (
 
 // Telemetry holds all telemetry instruments and providers.
 //
-// Metrics leave the process over OTLP and nothing else. There is no Prometheus
-// scrape endpoint: point a collector at OTELAddress and scrape the collector.
+// Metrics leave the process over OTLP, through a Prometheus scrape endpoint, or
+// both. The two see the same instruments, and a collector's Prometheus exporter
+// and this process's name them identically, so one set of queries serves either.
 type Telemetry struct {
 	meterProvider metric.MeterProvider
 	tracer        trace.Tracer
 	meter         metric.Meter
+	// registry backs the scrape endpoint; nil unless Prometheus is enabled.
+	registry *prometheus.Registry
 
 	// RED Metrics are now handled by otelhttp automatically
 
@@ -47,6 +55,7 @@ type Telemetry struct {
 	dbOperationsTotal     metric.Int64Counter
 	dbOperationDuration   metric.Float64Histogram
 	torrentTypeCounter    metric.Int64Counter
+	notificationsTotal    metric.Int64Counter
 
 	// System health
 	systemErrors metric.Int64Counter
@@ -72,14 +81,18 @@ type Config struct {
 	// an obvious diagnosis. Set false when the collector is reached over a network
 	// you do not trust.
 	Insecure bool
+	// Prometheus exposes the metrics for scraping, through MetricsHandler. It is
+	// independent of Enabled: either, both, or neither may be on.
+	Prometheus bool
 }
 
 // New creates a new telemetry instance.
 //
-// When cfg.Enabled is false, no-op providers are installed: the instruments are
-// still created so callers need no nil handling, but nothing is exported and no
-// connection is opened. When cfg.Enabled is true and cfg.OTELAddress is empty,
-// New returns ErrMissingOTELAddress rather than silently discarding metrics.
+// When neither cfg.Enabled nor cfg.Prometheus is set, no-op providers are
+// installed: the instruments are still created so callers need no nil handling,
+// but nothing is exported and no connection is opened. When cfg.Enabled is true
+// and cfg.OTELAddress is empty, New returns ErrMissingOTELAddress rather than
+// silently discarding metrics.
 func New(ctx context.Context, cfg Config) (*Telemetry, error) {
 	// Create resource with service attributes
 	extraResources, _ := resource.New(ctx,
@@ -102,16 +115,61 @@ func New(ctx context.Context, cfg Config) (*Telemetry, error) {
 		return nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
+	readers, registry, err := newReaders(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	var meterProvider metric.MeterProvider
 
-	switch {
-	case !cfg.Enabled:
+	if len(readers) == 0 {
 		slog.InfoContext(ctx, "telemetry disabled - metrics and traces will not be exported")
 
 		meterProvider = noop.NewMeterProvider()
-	case cfg.OTELAddress == "":
-		return nil, ErrMissingOTELAddress
-	default:
+	} else {
+		opts := []sdkmetric.Option{sdkmetric.WithResource(res)}
+		for _, reader := range readers {
+			opts = append(opts, sdkmetric.WithReader(reader))
+		}
+
+		meterProvider = sdkmetric.NewMeterProvider(opts...)
+	}
+
+	// Set global meter provider
+	otel.SetMeterProvider(meterProvider)
+
+	// Create tracer and meter
+	tracer := otel.Tracer(cfg.ServiceName)
+	meter := otel.Meter(cfg.ServiceName)
+
+	t := &Telemetry{
+		meterProvider: meterProvider,
+		tracer:        tracer,
+		meter:         meter,
+		registry:      registry,
+	}
+
+	// Initialize all metrics
+	if err := t.initializeMetrics(len(readers) > 0); err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	return t, nil
+}
+
+// newReaders builds a metric reader for each way metrics leave the process.
+// None means metrics are not exported at all.
+func newReaders(ctx context.Context, cfg Config) ([]sdkmetric.Reader, *prometheus.Registry, error) {
+	var (
+		readers  []sdkmetric.Reader
+		registry *prometheus.Registry
+	)
+
+	if cfg.Enabled {
+		if cfg.OTELAddress == "" {
+			return nil, nil, ErrMissingOTELAddress
+		}
+
 		// Report export failures at WARN. An unreachable collector means metrics
 		// are silently not arriving, which is not routine -- but it also must not
 		// take the process down, so it stays a log line rather than an error.
@@ -122,7 +180,6 @@ func New(ctx context.Context, cfg Config) (*Telemetry, error) {
 				"err", err)
 		}))
 
-		// Create OTLP exporter
 		opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(cfg.OTELAddress)}
 		if cfg.Insecure {
 			opts = append(opts, otlpmetricgrpc.WithInsecure())
@@ -130,35 +187,37 @@ func New(ctx context.Context, cfg Config) (*Telemetry, error) {
 
 		exporter, err := otlpmetricgrpc.New(ctx, opts...)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+			return nil, nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
 		}
 
-		// Create meter provider with resource
-		meterProvider = sdkmetric.NewMeterProvider(
-			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)),
-			sdkmetric.WithResource(res),
-		)
+		readers = append(readers, sdkmetric.NewPeriodicReader(exporter))
 	}
 
-	// Set global meter provider
-	otel.SetMeterProvider(meterProvider)
+	if cfg.Prometheus {
+		// A registry of our own rather than the global default, so the scrape
+		// carries exactly what the OTLP path would, and nothing a dependency
+		// registered behind our back.
+		registry = prometheus.NewRegistry()
 
-	// Create tracer and meter
-	tracer := otel.Tracer(cfg.ServiceName)
-	meter := otel.Meter(cfg.ServiceName)
+		exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create Prometheus exporter: %w", err)
+		}
 
-	t := &Telemetry{
-		meterProvider: meterProvider,
-		tracer:        tracer,
-		meter:         meter,
+		readers = append(readers, exporter)
 	}
 
-	// Initialize all metrics
-	if err := t.initializeMetrics(cfg.Enabled); err != nil {
-		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	return readers, registry, nil
+}
+
+// MetricsHandler serves the metrics in the Prometheus exposition format. It is
+// nil unless the Prometheus exporter was enabled.
+func (t *Telemetry) MetricsHandler() http.Handler {
+	if t.registry == nil {
+		return nil
 	}
 
-	return t, nil
+	return promhttp.HandlerFor(t.registry, promhttp.HandlerOpts{})
 }
 
 // RecordDownload records download metrics.
@@ -282,6 +341,20 @@ func (t *Telemetry) RecordTorrentType(ctx context.Context, torrentType string) {
 	}
 }
 
+// RecordNotification records what became of a notification for a target:
+// delivered, retried, or dropped for reason.
+func (t *Telemetry) RecordNotification(ctx context.Context, target, outcome, reason string) {
+	if t.notificationsTotal != nil {
+		t.notificationsTotal.Add(ctx, 1,
+			metric.WithAttributes(
+				attribute.String("target", target),
+				attribute.String("outcome", outcome),
+				attribute.String("reason", reason),
+			),
+		)
+	}
+}
+
 // Shutdown gracefully shuts down the telemetry system.
 func (t *Telemetry) Shutdown(ctx context.Context) error {
 	if mp, ok := t.meterProvider.(*sdkmetric.MeterProvider); ok {
@@ -447,5 +520,14 @@ func (t *Telemetry) initializeBusinessMetrics() error {
 		return fmt.Errorf("failed to create torrents.type.total counter: %w", err)
 	}
 
+	t.notificationsTotal, err = t.meter.Int64Counter(
+		"notifications.total",
+		metric.WithDescription("Notifications by target and outcome: delivered, retried or dropped"),
+		metric.WithUnit("{notification}"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create notifications.total counter: %w", err)
+	}
+
 	return nil
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -142,3 +144,77 @@ func TestDisabledTelemetry_RecordingAndShutdownAreSafe(t *testing.T) {
 
 	assert.NoError(t, tel.Shutdown(ctx))
 }
+
+func scrape(t *testing.T, tel *Telemetry) string {
+	t.Helper()
+
+	handler := tel.MetricsHandler()
+	require.NotNil(t, handler)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	return rec.Body.String()
+}
+
+func TestNew_PrometheusWithoutOTLP_ServesTheInstruments(t *testing.T) {
+	tel, err := New(context.Background(), Config{
+		ServiceName:    "test",
+		ServiceVersion: "test",
+		Prometheus:     true,
+	})
+	require.NoError(t, err)
+
+	defer tel.Shutdown(context.Background())
+
+	ctx := context.Background()
+	tel.RecordDownload(ctx, "success", time.Second)
+	tel.IncrementActiveDownloads(ctx)
+	tel.RecordClientOperation(ctx, "putio", "list", "error")
+	tel.RecordDBOperation(ctx, "claim", "success", time.Millisecond)
+
+	body := scrape(t, tel)
+
+	// These are the names the dashboards query, and the names a collector's
+	// Prometheus exporter gives the same instruments received over OTLP.
+	for _, name := range []string{
+		`downloads_total{`,
+		`downloads_active{`,
+		`downloads_duration_seconds_bucket{`,
+		`client_operations_total{`,
+		`client_errors_total{`,
+		`db_operations_total{`,
+		`db_operations_duration_seconds_bucket{`,
+		`target_info{`,
+	} {
+		assert.Contains(t, body, name)
+	}
+}
+
+func TestNew_PrometheusAndOTLPTogether(t *testing.T) {
+	tel, err := New(context.Background(), Config{
+		Enabled:        true,
+		ServiceName:    "test",
+		ServiceVersion: "test",
+		OTELAddress:    closedAddr(t),
+		Insecure:       true,
+		Prometheus:     true,
+	})
+	require.NoError(t, err)
+
+	tel.RecordTransfer(context.Background(), "add", "success")
+	assert.Contains(t, scrape(t, tel), `transfers_total{`)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_ = tel.Shutdown(shutdownCtx)
+}
+
+func TestNew_PrometheusDisabled_HasNoHandler(t *testing.T) {
+	tel, err := New(context.Background(), Config{ServiceName: "test", ServiceVersion: "test"})
+	require.NoError(t, err)
+
+	assert.Nil(t, tel.MetricsHandler())
+}
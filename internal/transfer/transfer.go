@@ -2,13 +2,17 @@ package transfer
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"path/filepath"
 	"runtime/debug"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/italolelis/seedbox_downloader/internal/events"
 	"github.com/italolelis/seedbox_downloader/internal/logctx"
 	"github.com/italolelis/seedbox_downloader/internal/storage"
 )
@@ -19,6 +23,13 @@ type DownloadClient interface {
 	GrabFile(ctx context.Context, file *File) (io.ReadCloser, error)
 }
 
+// LabelChecker is a download client that can tell whether the label transfers
+// are filed under exists. Polling alone cannot tell a missing label from one
+// with nothing under it yet.
+type LabelChecker interface {
+	CheckLabel(ctx context.Context, label string) error
+}
+
 type TransferClient interface {
 	AddTransfer(ctx context.Context, url string, downloadDir string) (*Transfer, error)
 	AddTransferByBytes(ctx context.Context, torrentBytes []byte, filename string, downloadDir string) (*Transfer, error)
@@ -54,6 +65,31 @@ type File struct {
 	Size int64
 }
 
+// HashString is the id the *arr apps know the transfer by: advertised to them as
+// Transmission's hashString, recorded in their history as the downloadId, and
+// sent back when they ask for the transfer to be removed. Not a torrent info
+// hash -- it is derived from the seedbox's own transfer id.
+func (t *Transfer) HashString() string {
+	hash := sha1.Sum([]byte(t.ID))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// TotalSize is the transfer's size in bytes. Not every seedbox reports it, but
+// each reports its files' sizes.
+func (t *Transfer) TotalSize() int64 {
+	if t.Size > 0 {
+		return t.Size
+	}
+
+	var size int64
+	for _, file := range t.Files {
+		size += file.Size
+	}
+
+	return size
+}
+
 func (t *Transfer) IsSeeding() bool {
 	return t.Status == "seeding" || t.Status == "seedingwait"
 }
@@ -136,10 +172,28 @@ func leadingSegment(p string) string {
 }
 
 type TransferOrchestrator struct {
-	repo            storage.DownloadRepository
-	dc              DownloadClient
-	label           string
-	pollingInterval time.Duration
+	repo  storage.DownloadRepository
+	dc    DownloadClient
+	label string
+	// pollingInterval is a time.Duration, changed while the loop runs by
+	// SetPollingInterval, which tells the loop through intervalChanged.
+	pollingInterval atomic.Int64
+	intervalChanged chan struct{}
+
+	// paused stops polls from claiming anything. The loop itself keeps running,
+	// so resuming needs no restart and loses no state.
+	paused atomic.Bool
+	// pollNow asks the loop for a poll ahead of the ticker. Buffered by one:
+	// requests made while one is already pending collapse into it.
+	pollNow chan struct{}
+	// lastTick is when the loop last woke, in Unix nanoseconds. A loop that
+	// stops waking is wedged, which is what liveness probes look for.
+	lastTick atomic.Int64
+	// handingOff is set while the loop waits for the downloader to take a
+	// transfer. It does not tick meanwhile: whether that wait is progressing is
+	// for the downloader to tell.
+	handingOff atomic.Bool
+	events     *events.Bus
 
 	// OnDownloadQueued is deliberately never closed: context cancellation stops
 	// the producer, and closing a channel from a goroutine that also sends on it
@@ -147,15 +201,98 @@ type TransferOrchestrator struct {
 	OnDownloadQueued chan *Transfer
 }
 
-func NewTransferOrchestrator(repo storage.DownloadRepository, dc DownloadClient, label string, pollingInterval time.Duration) *TransferOrchestrator {
-	return &TransferOrchestrator{
+// OrchestratorOption configures a TransferOrchestrator.
+type OrchestratorOption func(*TransferOrchestrator)
+
+// WithEvents publishes a transfer.claimed event to bus for every claim won.
+func WithEvents(bus *events.Bus) OrchestratorOption {
+	return func(o *TransferOrchestrator) {
+		o.events = bus
+	}
+}
+
+func NewTransferOrchestrator(
+	repo storage.DownloadRepository, dc DownloadClient, label string, pollingInterval time.Duration, opts ...OrchestratorOption,
+) *TransferOrchestrator {
+	o := &TransferOrchestrator{
 		repo:            repo,
 		dc:              dc,
 		label:           label,
-		pollingInterval: pollingInterval,
+		intervalChanged: make(chan struct{}, 1),
+		pollNow:         make(chan struct{}, 1),
 
 		OnDownloadQueued: make(chan *Transfer),
 	}
+
+	o.pollingInterval.Store(int64(pollingInterval))
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// Poll requests a poll now rather than at the next tick. It never blocks; a
+// request made while another is still pending is absorbed by it.
+func (o *TransferOrchestrator) Poll() {
+	select {
+	case o.pollNow <- struct{}{}:
+	default:
+	}
+}
+
+// PollingInterval is how long the loop waits between polls.
+func (o *TransferOrchestrator) PollingInterval() time.Duration {
+	return time.Duration(o.pollingInterval.Load())
+}
+
+// SetPollingInterval changes how long the loop waits between polls, counting the
+// new interval from now.
+func (o *TransferOrchestrator) SetPollingInterval(interval time.Duration) {
+	o.pollingInterval.Store(int64(interval))
+
+	select {
+	case o.intervalChanged <- struct{}{}:
+	default:
+	}
+}
+
+// Pause stops polls from claiming transfers until Resume is called. A download
+// already under way is not interrupted.
+func (o *TransferOrchestrator) Pause() {
+	o.paused.Store(true)
+}
+
+// Resume undoes Pause.
+func (o *TransferOrchestrator) Resume() {
+	o.paused.Store(false)
+}
+
+// Paused reports whether the orchestrator is paused.
+func (o *TransferOrchestrator) Paused() bool {
+	return o.paused.Load()
+}
+
+// LastTick reports when the polling loop last showed signs of life: woke for a
+// poll, or handed a transfer to the downloader. Zero until the loop has started.
+func (o *TransferOrchestrator) LastTick() time.Time {
+	nanos := o.lastTick.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, nanos)
+}
+
+func (o *TransferOrchestrator) tick() {
+	o.lastTick.Store(time.Now().UnixNano())
+}
+
+// HandingOff reports whether the loop is waiting for the downloader to take a
+// transfer, which lasts as long as the download before it does.
+func (o *TransferOrchestrator) HandingOff() bool {
+	return o.handingOff.Load()
 }
 
 func (o *TransferOrchestrator) ProduceTransfers(ctx context.Context) {
@@ -183,9 +320,13 @@ func (o *TransferOrchestrator) ProduceTransfers(ctx context.Context) {
 		}()
 
 		// Ticker with cleanup (deferred second, executes second during unwind)
-		ticker := time.NewTicker(o.pollingInterval)
+		ticker := time.NewTicker(o.PollingInterval())
 		defer ticker.Stop()
 
+		// The first poll is not left for a whole interval after starting.
+		o.tick()
+		o.poll(ctx)
+
 		for {
 			select {
 			case <-ctx.Done():
@@ -195,15 +336,62 @@ func (o *TransferOrchestrator) ProduceTransfers(ctx context.Context) {
 
 				return
 			case <-ticker.C:
-				if err := o.watchTransfers(ctx); err != nil {
-					logger.ErrorContext(ctx, "failed to watch transfers", "err", err)
-				}
+				o.tick()
+				o.poll(ctx)
+			case <-o.pollNow:
+				logger.InfoContext(ctx, "polling on request", "operation", "produce_transfers")
+
+				o.tick()
+				o.poll(ctx)
+			case <-o.intervalChanged:
+				logger.InfoContext(ctx, "polling interval changed",
+					"operation", "produce_transfers", "polling_interval", o.PollingInterval())
+
+				ticker.Reset(o.PollingInterval())
 			}
 		}
 	}()
 }
 
+func (o *TransferOrchestrator) poll(ctx context.Context) {
+	logger := logctx.LoggerFromContext(ctx)
+
+	if o.Paused() {
+		logger.DebugContext(ctx, "skipping poll because the orchestrator is paused", "label", o.label)
+
+		return
+	}
+
+	if err := o.watchTransfers(ctx); err != nil {
+		logger.ErrorContext(ctx, "failed to watch transfers", "err", err)
+	}
+}
+
 func (o *TransferOrchestrator) watchTransfers(ctx context.Context) error {
+	return o.claimTransfers(ctx, func(t *Transfer) error {
+		return o.handOff(ctx, t)
+	})
+}
+
+// PollOnce lists the transfers under the label and claims every one that can be
+// downloaded, returning them rather than queueing them for the downloader. It
+// is for runs that download what there is and exit, which call it instead of
+// ProduceTransfers.
+func (o *TransferOrchestrator) PollOnce(ctx context.Context) ([]*Transfer, error) {
+	var claimed []*Transfer
+
+	err := o.claimTransfers(ctx, func(t *Transfer) error {
+		claimed = append(claimed, t)
+
+		return nil
+	})
+
+	return claimed, err
+}
+
+// claimTransfers claims each downloadable transfer under the label and passes it
+// to handle, stopping at the first error.
+func (o *TransferOrchestrator) claimTransfers(ctx context.Context, handle func(*Transfer) error) error {
 	logger := logctx.LoggerFromContext(ctx)
 
 	logger.DebugContext(ctx, "polling for transfers", "label", o.label)
@@ -246,9 +434,32 @@ func (o *TransferOrchestrator) watchTransfers(ctx context.Context) error {
 		}
 
 		transferLogger.InfoContext(ctx, "transfer ready for download")
+		o.events.Publish(events.TransferClaimed, events.Transfer{
+			ID: transfer.ID, Name: transfer.Name, Label: transfer.Label, Size: transfer.TotalSize(), Files: len(transfer.Files),
+		})
 
-		o.OnDownloadQueued <- transfer
+		if err := handle(transfer); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
+
+// handOff queues a claimed transfer for the downloader. The downloader takes one
+// transfer at a time, so this can wait as long as a large download takes. The
+// heartbeat does not beat meanwhile, which would hide a hung downloader; the
+// downloader's own progress tells a long download from one.
+func (o *TransferOrchestrator) handOff(ctx context.Context, t *Transfer) error {
+	o.handingOff.Store(true)
+	defer o.handingOff.Store(false)
+
+	select {
+	case o.OnDownloadQueued <- t:
+		o.tick()
+
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("transfer %s not queued: %w", t.ID, ctx.Err())
+	}
+}
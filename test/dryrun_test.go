@@ -0,0 +1,80 @@
+package test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/downloader"
+	"github.com/italolelis/seedbox_downloader/internal/logctx"
+	"github.com/italolelis/seedbox_downloader/internal/svc/arr"
+	"github.com/italolelis/seedbox_downloader/test/seedbox"
+	"github.com/italolelis/seedbox_downloader/test/servarr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A dry run fetches nothing and writes nothing, and reports nothing downloaded,
+// so nothing downstream waits on an import.
+func TestDownload_DryRunWritesNothing(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Show.S01E01",
+		Root: seedbox.Entry{Name: "Show.S01E01", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode"},
+		}},
+	})
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+
+	root := t.TempDir()
+	client := sb.Client()
+	dl := downloader.NewDownloader(root, 5, client, client, nil, downloader.WithDryRun())
+
+	ctx := logctx.WithLogger(context.Background(), testLogger())
+
+	downloaded, err := dl.Download(ctx, transfers[0])
+	require.NoError(t, err)
+	assert.False(t, downloaded)
+	assert.NoDirExists(t, filepath.Join(root, "Show.S01E01"))
+}
+
+// An import found in a dry run is reported as one, but the files it would
+// remove are left where they are.
+func TestCheckImport_DryRunDeletesNothing(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Show.S01E01",
+		Root: seedbox.Entry{Name: "Show.S01E01", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode"},
+		}},
+	})
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+	tr := transfers[0]
+
+	dl, root := newDownloader(t, sb)
+
+	ctx := logctx.WithLogger(context.Background(), testLogger())
+
+	_, err := dl.DownloadTransfer(ctx, tr)
+	require.NoError(t, err)
+
+	sonarr := servarr.New(t, servarr.Record{
+		EventType:   "downloadFolderImported",
+		DownloadID:  strings.ToUpper(tr.HashString()),
+		DroppedPath: "/data/torrents/Show.S01E01/e01.mkv",
+	})
+
+	client := sb.Client()
+	dryRun := downloader.NewDownloader(root, 5, client, client,
+		[]*arr.Client{arr.NewClient(servarr.APIKey, sonarr.URL())}, downloader.WithDryRun())
+
+	imported, refused, err := dryRun.CheckImport(ctx, tr, time.Now())
+	require.NoError(t, err)
+	assert.True(t, imported)
+	assert.Nil(t, refused)
+	assertFile(t, filepath.Join(root, "Show.S01E01", "e01.mkv"), "episode")
+}
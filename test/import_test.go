@@ -0,0 +1,472 @@
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/downloader"
+	"github.com/italolelis/seedbox_downloader/internal/logctx"
+	"github.com/italolelis/seedbox_downloader/internal/svc/arr"
+	"github.com/italolelis/seedbox_downloader/test/seedbox"
+	"github.com/italolelis/seedbox_downloader/test/servarr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The *arr app mounts the download volume somewhere else, so the path it records
+// for the import is not one we ever wrote to. The hash we advertised is the same
+// on both sides, and that is what the import is found by.
+func TestWatchForImported_FindsTheImportByDownloadID(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Show.S01E01",
+		Root: seedbox.Entry{Name: "Show.S01E01", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode"},
+		}},
+	})
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+	tr := transfers[0]
+
+	sonarr := servarr.New(t, servarr.Record{EventType: "grabbed", DownloadID: strings.ToUpper(tr.HashString())})
+
+	root := t.TempDir()
+	client := sb.Client()
+	dl := downloader.NewDownloader(root, 5, client, client, []*arr.Client{arr.NewClient(servarr.APIKey, sonarr.URL())})
+
+	ctx, cancel := context.WithCancel(logctx.WithLogger(context.Background(), testLogger()))
+	defer cancel()
+
+	_, err := dl.DownloadTransfer(ctx, tr)
+	require.NoError(t, err)
+	assertFile(t, filepath.Join(root, "Show.S01E01", "e01.mkv"), "episode")
+
+	imported := collect(dl.OnTransferImported)
+	dl.WatchForImported(ctx, tr, 10*time.Millisecond)
+
+	// Grabbed but not imported: the watch keeps waiting.
+	select {
+	case <-imported:
+		t.Fatal("reported imported before the app imported it")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	sonarr.Add(servarr.Record{
+		EventType:   "downloadFolderImported",
+		DownloadID:  strings.ToUpper(tr.HashString()),
+		DroppedPath: "/data/torrents/Show.S01E01/e01.mkv",
+	})
+
+	select {
+	case got := <-imported:
+		assert.Equal(t, tr.ID, got.ID)
+	case <-time.After(wedgeTimeout):
+		t.Fatal("the import was never noticed")
+	}
+
+	assert.NoFileExists(t, filepath.Join(root, "Show.S01E01", "e01.mkv"), "imported files are removed")
+}
+
+// With push import on, the app is told to import the moment the download lands,
+// and the watch looks as soon as the app says it is done -- not a polling
+// interval later.
+func TestWatchForImported_PushesTheImport(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Show.S01E01",
+		Root: seedbox.Entry{Name: "Show.S01E01", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode"},
+		}},
+	})
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+	tr := transfers[0]
+
+	root := t.TempDir()
+	downloadID := strings.ToUpper(tr.HashString())
+
+	sonarr := servarr.New(t, servarr.Record{EventType: "grabbed", DownloadID: downloadID})
+	sonarr.OnCommand(func(cmd servarr.Command) string {
+		sonarr.Add(servarr.Record{
+			EventType:   "downloadFolderImported",
+			DownloadID:  cmd.DownloadClientID,
+			DroppedPath: filepath.Join(cmd.Path, "e01.mkv"),
+		})
+
+		return "completed"
+	})
+
+	client := sb.Client()
+	dl := downloader.NewDownloader(root, 5, client, client,
+		[]*arr.Client{arr.NewClient(servarr.APIKey, sonarr.URL(), arr.WithApp(arr.Sonarr))},
+		downloader.WithPushImport(time.Minute),
+	)
+
+	ctx, cancel := context.WithCancel(logctx.WithLogger(context.Background(), testLogger()))
+	defer cancel()
+
+	_, err := dl.DownloadTransfer(ctx, tr)
+	require.NoError(t, err)
+
+	scans := collect(dl.OnImportScanFinished)
+	imported := collect(dl.OnTransferImported)
+	dl.WatchForImported(ctx, tr, time.Hour)
+
+	select {
+	case result := <-scans:
+		assert.Equal(t, "sonarr", result.Instance)
+		assert.Equal(t, "completed", result.Outcome())
+		require.NoError(t, result.Err)
+	case <-time.After(wedgeTimeout):
+		t.Fatal("the pushed import was never reported")
+	}
+
+	select {
+	case got := <-imported:
+		assert.Equal(t, tr.ID, got.ID)
+	case <-time.After(wedgeTimeout):
+		t.Fatal("the import was not noticed when the scan finished")
+	}
+
+	assert.Equal(t, []servarr.Command{{
+		ID:               1,
+		Name:             "DownloadedEpisodesScan",
+		Path:             filepath.Join(root, "Show.S01E01"),
+		DownloadClientID: downloadID,
+	}}, sonarr.Commands())
+}
+
+type owners map[string]string
+
+func (o owners) TransferOwner(transferID string) (string, error) {
+	return o[transferID], nil
+}
+
+// A transfer Radarr added is only ever asked about in Radarr. One nobody claimed
+// is asked about everywhere, as before.
+func TestWatchForImported_AsksOnlyTheOwner(t *testing.T) {
+	sb := seedbox.New(t, "itv",
+		seedbox.Transfer{Name: "Movie", Root: seedbox.Entry{Name: "Movie", Children: []seedbox.Entry{
+			{Name: "movie.mkv", Content: "movie"},
+		}}},
+		seedbox.Transfer{Name: "Stray", Root: seedbox.Entry{Name: "Stray", Children: []seedbox.Entry{
+			{Name: "stray.mkv", Content: "stray"},
+		}}},
+	)
+
+	transfers := fetch(t, sb)
+	movie, stray := byName(t, transfers, "Movie"), byName(t, transfers, "Stray")
+
+	sonarr := servarr.New(t)
+	radarr := servarr.New(t,
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: strings.ToUpper(movie.HashString())},
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: strings.ToUpper(stray.HashString())},
+	)
+
+	client := sb.Client()
+	dl := downloader.NewDownloader(t.TempDir(), 5, client, client, []*arr.Client{
+		arr.NewClient(servarr.APIKey, sonarr.URL(), arr.WithApp(arr.Sonarr)),
+		arr.NewClient(servarr.APIKey, radarr.URL(), arr.WithApp(arr.Radarr)),
+	}, downloader.WithOwners(owners{movie.ID: "radarr"}))
+
+	ctx, cancel := context.WithCancel(logctx.WithLogger(context.Background(), testLogger()))
+	defer cancel()
+
+	imported := collect(dl.OnTransferImported)
+
+	dl.WatchForImported(ctx, movie, 10*time.Millisecond)
+
+	select {
+	case got := <-imported:
+		assert.Equal(t, movie.ID, got.ID)
+	case <-time.After(wedgeTimeout):
+		t.Fatal("the import was never noticed")
+	}
+
+	assert.Empty(t, sonarr.Requests(), "Sonarr was asked about Radarr's transfer")
+
+	dl.WatchForImported(ctx, stray, 10*time.Millisecond)
+
+	select {
+	case got := <-imported:
+		assert.Equal(t, stray.ID, got.ID)
+	case <-time.After(wedgeTimeout):
+		t.Fatal("the import was never noticed")
+	}
+
+	assert.NotEmpty(t, sonarr.Requests(), "a transfer of unknown origin is asked about everywhere")
+}
+
+// A download the app gave up on is reported once and no longer watched; its files
+// stay where they are until someone decides what to do with them.
+func TestWatchForImported_ReportsARefusedImport(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Show.S01E01",
+		Root: seedbox.Entry{Name: "Show.S01E01", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode"},
+		}},
+	})
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+	tr := transfers[0]
+
+	sonarr := servarr.New(t,
+		servarr.Record{EventType: "grabbed", DownloadID: strings.ToUpper(tr.HashString())},
+		servarr.Record{EventType: "downloadFailed", DownloadID: strings.ToUpper(tr.HashString()), Message: "Not a valid episode"},
+	)
+
+	root := t.TempDir()
+	client := sb.Client()
+	dl := downloader.NewDownloader(root, 5, client, client,
+		[]*arr.Client{arr.NewClient(servarr.APIKey, sonarr.URL(), arr.WithApp(arr.Sonarr))})
+
+	ctx, cancel := context.WithCancel(logctx.WithLogger(context.Background(), testLogger()))
+	defer cancel()
+
+	_, err := dl.DownloadTransfer(ctx, tr)
+	require.NoError(t, err)
+
+	failed := collect(dl.OnTransferImportFailed)
+	dl.WatchForImported(ctx, tr, 10*time.Millisecond)
+
+	select {
+	case event := <-failed:
+		assert.Equal(t, downloader.ImportFailedEvent{
+			Transfer: tr, Instance: "sonarr", Reason: "download_failed", Message: "Not a valid episode",
+		}, event)
+	case <-time.After(wedgeTimeout):
+		t.Fatal("the failed import was never noticed")
+	}
+
+	assert.FileExists(t, filepath.Join(root, "Show.S01E01", "e01.mkv"))
+
+	dl.RemoveLocal(ctx, tr)
+	assert.NoDirExists(t, filepath.Join(root, "Show.S01E01"))
+}
+
+func TestWatchForImported_GivesUpAfterTheTimeout(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Show.S01E01",
+		Root: seedbox.Entry{Name: "Show.S01E01", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode"},
+		}},
+	})
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+	tr := transfers[0]
+
+	sonarr := servarr.New(t, servarr.Record{EventType: "grabbed", DownloadID: strings.ToUpper(tr.HashString())})
+
+	client := sb.Client()
+	dl := downloader.NewDownloader(t.TempDir(), 5, client, client,
+		[]*arr.Client{arr.NewClient(servarr.APIKey, sonarr.URL(), arr.WithApp(arr.Sonarr))},
+		downloader.WithImportTimeout(50*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithCancel(logctx.WithLogger(context.Background(), testLogger()))
+	defer cancel()
+
+	failed := collect(dl.OnTransferImportFailed)
+	imported := collect(dl.OnTransferImported)
+	dl.WatchForImported(ctx, tr, 10*time.Millisecond)
+
+	select {
+	case event := <-failed:
+		assert.Equal(t, "timeout", event.Reason)
+		assert.Empty(t, event.Instance)
+	case <-imported:
+		t.Fatal("reported imported without an import")
+	case <-time.After(wedgeTimeout):
+		t.Fatal("the watch never gave up")
+	}
+}
+
+// A season pack imported an episode at a time is not stuck: the timeout runs
+// from the last episode imported, so a pack whose import takes longer than it,
+// but never waits that long for the next episode, is not given up on.
+func TestWatchForImported_TimeoutRunsFromTheLastEpisodeImported(t *testing.T) {
+	episodes := []string{"e01.mkv", "e02.mkv", "e03.mkv", "e04.mkv"}
+
+	children := make([]seedbox.Entry, 0, len(episodes))
+	for _, name := range episodes {
+		children = append(children, seedbox.Entry{Name: name, Content: "episode " + name})
+	}
+
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Show.S01",
+		Root: seedbox.Entry{Name: "Show.S01", Children: children},
+	})
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+	tr := transfers[0]
+
+	downloadID := strings.ToUpper(tr.HashString())
+	sonarr := servarr.New(t, servarr.Record{EventType: "grabbed", DownloadID: downloadID})
+
+	const timeout = 200 * time.Millisecond
+
+	root := t.TempDir()
+	client := sb.Client()
+	dl := downloader.NewDownloader(root, 5, client, client,
+		[]*arr.Client{arr.NewClient(servarr.APIKey, sonarr.URL(), arr.WithApp(arr.Sonarr))},
+		downloader.WithImportTimeout(timeout),
+	)
+
+	ctx, cancel := context.WithCancel(logctx.WithLogger(context.Background(), testLogger()))
+	defer cancel()
+
+	_, err := dl.DownloadTransfer(ctx, tr)
+	require.NoError(t, err)
+
+	failed := collect(dl.OnTransferImportFailed)
+	imported := collect(dl.OnTransferImported)
+	dl.WatchForImported(ctx, tr, 10*time.Millisecond)
+
+	for _, name := range episodes {
+		select {
+		case event := <-failed:
+			t.Fatalf("gave up on a pack still being imported: %s", event.Reason)
+		case <-time.After(timeout / 2):
+		}
+
+		sonarr.Add(servarr.Record{
+			EventType:   "downloadFolderImported",
+			DownloadID:  downloadID,
+			DroppedPath: filepath.Join(root, "Show.S01", name),
+		})
+	}
+
+	select {
+	case got := <-imported:
+		assert.Equal(t, tr.ID, got.ID)
+	case event := <-failed:
+		t.Fatalf("gave up on a pack still being imported: %s", event.Reason)
+	case <-time.After(wedgeTimeout):
+		t.Fatal("the last episode's import was never noticed")
+	}
+}
+
+// A season pack is imported an episode at a time. Each episode goes as soon as it
+// is imported and the rest wait for the app; the release notes and the sample
+// are not media, so the pack counts as imported without them and the Local
+// Layout goes with the last episode.
+func TestWatchForImported_SeasonPackImportedEpisodeByEpisode(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Show.S01",
+		Root: seedbox.Entry{Name: "Show.S01", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode one"},
+			{Name: "e02.mkv", Content: "episode two"},
+			{Name: "Show.S01.nfo", Content: "release notes"},
+			{Name: "Sample", Children: []seedbox.Entry{{Name: "show.s01.sample.mkv", Content: "sample"}}},
+		}},
+	})
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+	tr := transfers[0]
+
+	downloadID := strings.ToUpper(tr.HashString())
+	sonarr := servarr.New(t, servarr.Record{EventType: "grabbed", DownloadID: downloadID})
+
+	root := t.TempDir()
+	client := sb.Client()
+	dl := downloader.NewDownloader(root, 5, client, client,
+		[]*arr.Client{arr.NewClient(servarr.APIKey, sonarr.URL(), arr.WithApp(arr.Sonarr))})
+
+	ctx, cancel := context.WithCancel(logctx.WithLogger(context.Background(), testLogger()))
+	defer cancel()
+
+	_, err := dl.DownloadTransfer(ctx, tr)
+	require.NoError(t, err)
+
+	imported := collect(dl.OnTransferImported)
+	dl.WatchForImported(ctx, tr, 10*time.Millisecond)
+
+	sonarr.Add(servarr.Record{
+		EventType:   "downloadFolderImported",
+		DownloadID:  downloadID,
+		DroppedPath: filepath.Join(root, "Show.S01", "e01.mkv"),
+	})
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(root, "Show.S01", "e01.mkv"))
+
+		return os.IsNotExist(err)
+	}, wedgeTimeout, 10*time.Millisecond, "the imported episode is removed")
+
+	select {
+	case <-imported:
+		t.Fatal("reported imported with an episode still to go")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assertFile(t, filepath.Join(root, "Show.S01", "e02.mkv"), "episode two")
+
+	sonarr.Add(servarr.Record{
+		EventType:   "downloadFolderImported",
+		DownloadID:  downloadID,
+		DroppedPath: filepath.Join(root, "Show.S01", "e02.mkv"),
+	})
+
+	select {
+	case got := <-imported:
+		assert.Equal(t, tr.ID, got.ID)
+	case <-time.After(wedgeTimeout):
+		t.Fatal("the last episode's import was never noticed")
+	}
+
+	assert.NoDirExists(t, filepath.Join(root, "Show.S01"), "the Local Layout goes once every episode is imported")
+}
+
+// An import recorded without paths to the pack's files cannot say which
+// episodes went, so none of them are deleted and the pack keeps waiting.
+func TestWatchForImported_SeasonPackImportWithoutPathsDeletesNothing(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Show.S01",
+		Root: seedbox.Entry{Name: "Show.S01", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode one"},
+			{Name: "e02.mkv", Content: "episode two"},
+		}},
+	})
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+	tr := transfers[0]
+
+	downloadID := strings.ToUpper(tr.HashString())
+	sonarr := servarr.New(t,
+		servarr.Record{EventType: "grabbed", DownloadID: downloadID},
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: downloadID},
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: downloadID, DroppedPath: "/unpacked/show.s01e01.mkv"},
+	)
+
+	root := t.TempDir()
+	client := sb.Client()
+	dl := downloader.NewDownloader(root, 5, client, client,
+		[]*arr.Client{arr.NewClient(servarr.APIKey, sonarr.URL(), arr.WithApp(arr.Sonarr))})
+
+	ctx, cancel := context.WithCancel(logctx.WithLogger(context.Background(), testLogger()))
+	defer cancel()
+
+	_, err := dl.DownloadTransfer(ctx, tr)
+	require.NoError(t, err)
+
+	imported := collect(dl.OnTransferImported)
+	dl.WatchForImported(ctx, tr, 10*time.Millisecond)
+
+	select {
+	case <-imported:
+		t.Fatal("reported imported without knowing which episodes were")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assertFile(t, filepath.Join(root, "Show.S01", "e01.mkv"), "episode one")
+	assertFile(t, filepath.Join(root, "Show.S01", "e02.mkv"), "episode two")
+}
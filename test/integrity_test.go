@@ -10,6 +10,7 @@ import (
 
 	"github.com/italolelis/seedbox_downloader/internal/dc/putio"
 	"github.com/italolelis/seedbox_downloader/internal/downloader"
+	"github.com/italolelis/seedbox_downloader/internal/events"
 	"github.com/italolelis/seedbox_downloader/internal/logctx"
 	"github.com/italolelis/seedbox_downloader/test/seedbox"
 	"github.com/stretchr/testify/assert"
@@ -154,3 +155,71 @@ func TestDownload_HonoursContextCancellation(t *testing.T) {
 		t.Fatal("download ignored a cancelled context")
 	}
 }
+
+// A transfer is verified once every file on disk has been checked against the
+// size the seedbox reported, and only then: Download publishes the event after
+// the check passes.
+func TestDownload_PublishesVerifiedAfterTheCheck(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Verified",
+		Root: seedbox.Entry{Name: "Verified", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode one"},
+			{Name: "e02.mkv", Content: "episode two"},
+		}},
+	})
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+
+	bus := events.NewBus(events.DefaultHistory)
+	stream, cancel := bus.Subscribe(0)
+	defer cancel()
+
+	client := sb.Client()
+	dl := downloader.NewDownloader(t.TempDir(), 5, client, client, nil, downloader.WithEvents(bus))
+
+	ctx := logctx.WithLogger(context.Background(), testLogger())
+
+	downloaded, err := dl.Download(ctx, transfers[0])
+	require.NoError(t, err)
+	assert.True(t, downloaded)
+
+	var published []string
+
+	for len(stream) > 0 {
+		if event := <-stream; event.Type != events.FileProgress {
+			published = append(published, event.Type)
+		}
+	}
+
+	assert.Equal(t, []string{events.TransferDownloaded, events.TransferVerified}, published)
+}
+
+// The check looks at the files as they are on disk, so one cut short or gone
+// since it was written fails it.
+func TestVerifyTransfer_ChecksTheFilesOnDisk(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Checked",
+		Root: seedbox.Entry{Name: "Checked", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode one"},
+			{Name: "e02.mkv", Content: "episode two"},
+		}},
+	})
+
+	dl, root := newDownloader(t, sb)
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+
+	ctx := logctx.WithLogger(context.Background(), testLogger())
+
+	_, err := dl.DownloadTransfer(ctx, transfers[0])
+	require.NoError(t, err)
+	require.NoError(t, dl.VerifyTransfer(transfers[0]))
+
+	require.NoError(t, os.Truncate(filepath.Join(root, "Checked", "e01.mkv"), 3))
+	assert.ErrorIs(t, dl.VerifyTransfer(transfers[0]), downloader.ErrSizeMismatch)
+
+	require.NoError(t, os.Remove(filepath.Join(root, "Checked", "e01.mkv")))
+	assert.ErrorIs(t, dl.VerifyTransfer(transfers[0]), os.ErrNotExist)
+}
@@ -0,0 +1,86 @@
+package test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/logctx"
+	"github.com/italolelis/seedbox_downloader/internal/storage/sqlite"
+	"github.com/italolelis/seedbox_downloader/internal/transfer"
+	"github.com/italolelis/seedbox_downloader/test/seedbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Waiting on the downloader is not a sign of life: the heartbeat stops while the
+// hand-off blocks, and beats again once the transfer is taken.
+func TestProduceTransfers_HandOffDoesNotTick(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Show.S01E01",
+		Root: seedbox.Entry{Name: "Show.S01E01", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode"},
+		}},
+	})
+
+	ctx, cancel := context.WithCancel(logctx.WithLogger(context.Background(), testLogger()))
+	defer cancel()
+
+	database, err := sqlite.InitDB(ctx, filepath.Join(t.TempDir(), "ledger.db"), 1, 1)
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+
+	orchestrator := transfer.NewTransferOrchestrator(sqlite.NewDownloadRepository(database), sb.Client(), sb.Label(), 10*time.Millisecond)
+	orchestrator.ProduceTransfers(ctx)
+
+	require.Eventually(t, orchestrator.HandingOff, wedgeTimeout, time.Millisecond)
+
+	blockedAt := orchestrator.LastTick()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, blockedAt, orchestrator.LastTick(), "the heartbeat beat while the hand-off was blocked")
+
+	select {
+	case <-orchestrator.OnDownloadQueued:
+	case <-time.After(wedgeTimeout):
+		t.Fatal("the transfer was never handed off")
+	}
+
+	require.Eventually(t, func() bool { return !orchestrator.HandingOff() }, wedgeTimeout, time.Millisecond)
+	assert.True(t, orchestrator.LastTick().After(blockedAt), "taking the transfer is a sign of life")
+}
+
+// An idle downloader is waiting, not stuck. One that holds a transfer and makes
+// no progress -- here, because nothing takes its result -- goes stale.
+func TestWatchDownloads_ReportsNoProgressWhenHung(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Show.S01E01",
+		Root: seedbox.Entry{Name: "Show.S01E01", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode"},
+		}},
+	})
+
+	dl, _ := newDownloader(t, sb)
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+
+	ctx, cancel := context.WithCancel(logctx.WithLogger(context.Background(), testLogger()))
+	defer cancel()
+
+	assert.True(t, dl.LastProgress().IsZero(), "not started")
+
+	queue := make(chan *transfer.Transfer)
+	dl.WatchDownloads(ctx, queue)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.WithinDuration(t, time.Now(), dl.LastProgress(), 10*time.Millisecond, "idle is not stale")
+
+	queue <- transfers[0]
+
+	require.Eventually(t, func() bool {
+		return time.Since(dl.LastProgress()) > 100*time.Millisecond
+	}, wedgeTimeout, 10*time.Millisecond, "a downloader blocked on a transfer reports no progress")
+
+	<-dl.OnTransferDownloadFinished
+}
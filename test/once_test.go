@@ -0,0 +1,184 @@
+package test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/italolelis/seedbox_downloader/internal/downloader"
+	"github.com/italolelis/seedbox_downloader/internal/logctx"
+	"github.com/italolelis/seedbox_downloader/internal/storage/sqlite"
+	"github.com/italolelis/seedbox_downloader/internal/svc/arr"
+	"github.com/italolelis/seedbox_downloader/internal/transfer"
+	"github.com/italolelis/seedbox_downloader/test/seedbox"
+	"github.com/italolelis/seedbox_downloader/test/servarr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A run from cron claims everything there is on its one poll and downloads it
+// itself; nothing is queued for a downloader that is not running.
+func TestPollOnce_ClaimsEveryDownloadableTransfer(t *testing.T) {
+	sb := seedbox.New(t, "itv",
+		seedbox.Transfer{Name: "First", Root: seedbox.Entry{Name: "First", Children: []seedbox.Entry{
+			{Name: "first.mkv", Content: "first"},
+		}}},
+		seedbox.Transfer{Name: "Second", Root: seedbox.Entry{Name: "Second", Children: []seedbox.Entry{
+			{Name: "second.mkv", Content: "second"},
+		}}},
+		seedbox.Transfer{Name: "Unfinished", Status: "DOWNLOADING", Root: seedbox.Entry{Name: "Unfinished", Children: []seedbox.Entry{
+			{Name: "unfinished.mkv", Content: "unfinished"},
+		}}},
+	)
+
+	ctx := logctx.WithLogger(context.Background(), testLogger())
+
+	database, err := sqlite.InitDB(ctx, filepath.Join(t.TempDir(), "ledger.db"), 1, 1)
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+
+	orchestrator := transfer.NewTransferOrchestrator(sqlite.NewDownloadRepository(database), sb.Client(), sb.Label(), time.Hour)
+
+	claimed, err := orchestrator.PollOnce(ctx)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(claimed))
+	for _, tr := range claimed {
+		names = append(names, tr.Name)
+	}
+
+	assert.ElementsMatch(t, []string{"First", "Second"}, names)
+
+	select {
+	case tr := <-orchestrator.OnDownloadQueued:
+		t.Fatalf("transfer %s was queued", tr.ID)
+	default:
+	}
+
+	claimed, err = orchestrator.PollOnce(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, claimed, "a transfer is claimed once")
+}
+
+// One check, with no watch left behind: the import is found and the files go,
+// as a watch would have it.
+func TestCheckImport_ChecksOnce(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Show.S01E01",
+		Root: seedbox.Entry{Name: "Show.S01E01", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode"},
+		}},
+	})
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+	tr := transfers[0]
+
+	sonarr := servarr.New(t, servarr.Record{EventType: "grabbed", DownloadID: strings.ToUpper(tr.HashString())})
+
+	root := t.TempDir()
+	client := sb.Client()
+	dl := downloader.NewDownloader(root, 5, client, client, []*arr.Client{arr.NewClient(servarr.APIKey, sonarr.URL())})
+
+	ctx := logctx.WithLogger(context.Background(), testLogger())
+
+	downloaded, err := dl.Download(ctx, tr)
+	require.NoError(t, err)
+	require.True(t, downloaded)
+
+	imported, refused, err := dl.CheckImport(ctx, tr, time.Now())
+	require.NoError(t, err)
+	assert.False(t, imported)
+	assert.Nil(t, refused, "grabbed but not imported is pending")
+	assertFile(t, filepath.Join(root, "Show.S01E01", "e01.mkv"), "episode")
+
+	sonarr.Add(servarr.Record{
+		EventType:   "downloadFolderImported",
+		DownloadID:  strings.ToUpper(tr.HashString()),
+		DroppedPath: "/data/torrents/Show.S01E01/e01.mkv",
+	})
+
+	imported, refused, err = dl.CheckImport(ctx, tr, time.Now())
+	require.NoError(t, err)
+	assert.True(t, imported)
+	assert.Nil(t, refused)
+	assert.NoDirExists(t, filepath.Join(root, "Show.S01E01"), "imported files are removed")
+}
+
+// Without a watch to time it, the import timeout runs from when the transfer was
+// downloaded, which may have been several runs ago.
+func TestCheckImport_TimesOutFromTheDownload(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Show.S01E01",
+		Root: seedbox.Entry{Name: "Show.S01E01", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode"},
+		}},
+	})
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+	tr := transfers[0]
+
+	sonarr := servarr.New(t, servarr.Record{EventType: "grabbed", DownloadID: strings.ToUpper(tr.HashString())})
+
+	client := sb.Client()
+	dl := downloader.NewDownloader(t.TempDir(), 5, client, client,
+		[]*arr.Client{arr.NewClient(servarr.APIKey, sonarr.URL(), arr.WithApp(arr.Sonarr))},
+		downloader.WithImportTimeout(time.Hour),
+	)
+
+	ctx := logctx.WithLogger(context.Background(), testLogger())
+
+	imported, refused, err := dl.CheckImport(ctx, tr, time.Now().Add(-30*time.Minute))
+	require.NoError(t, err)
+	assert.False(t, imported)
+	assert.Nil(t, refused, "still within the timeout")
+
+	imported, refused, err = dl.CheckImport(ctx, tr, time.Now().Add(-2*time.Hour))
+	require.NoError(t, err)
+	assert.False(t, imported)
+	require.NotNil(t, refused)
+	assert.Equal(t, "timeout", refused.Reason)
+}
+
+// A check that finds more of a season pack imported does not time it out,
+// however long ago it was downloaded: the pack is being imported.
+func TestCheckImport_DoesNotTimeOutAPackBeingImported(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Show.S01",
+		Root: seedbox.Entry{Name: "Show.S01", Children: []seedbox.Entry{
+			{Name: "e01.mkv", Content: "episode one"},
+			{Name: "e02.mkv", Content: "episode two"},
+		}},
+	})
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+	tr := transfers[0]
+
+	downloadID := strings.ToUpper(tr.HashString())
+	root := t.TempDir()
+	sonarr := servarr.New(t,
+		servarr.Record{EventType: "grabbed", DownloadID: downloadID},
+		servarr.Record{EventType: "downloadFolderImported", DownloadID: downloadID, DroppedPath: filepath.Join(root, "Show.S01", "e01.mkv")},
+	)
+
+	client := sb.Client()
+	dl := downloader.NewDownloader(root, 5, client, client,
+		[]*arr.Client{arr.NewClient(servarr.APIKey, sonarr.URL(), arr.WithApp(arr.Sonarr))},
+		downloader.WithImportTimeout(time.Hour),
+	)
+
+	ctx := logctx.WithLogger(context.Background(), testLogger())
+
+	_, err := dl.DownloadTransfer(ctx, tr)
+	require.NoError(t, err)
+
+	imported, refused, err := dl.CheckImport(ctx, tr, time.Now().Add(-2*time.Hour))
+	require.NoError(t, err)
+	assert.False(t, imported)
+	assert.Nil(t, refused, "an episode was imported on this check")
+	assert.False(t, dl.Report(tr.ID).ImportProgressAt.IsZero(), "the progress is reported, for the next run")
+}
@@ -111,6 +111,44 @@ func TestWatchDownloads_KeepsGoingAfterATransferFails(t *testing.T) {
 	assertFile(t, root+"/Healthy/good.mkv", "content that arrives fine")
 }
 
+// The reason a transfer failed is what an operator needs first, and it is held
+// nowhere else: the ledger records only that it failed.
+func TestWatchDownloads_RecordsTheFailureReason(t *testing.T) {
+	sb := seedbox.New(t, "itv", seedbox.Transfer{
+		Name: "Broken",
+		Root: seedbox.Entry{Name: "Broken", Children: []seedbox.Entry{
+			{Name: "broken.mkv", Content: "content that never arrives", AbortAfter: 4},
+		}},
+	})
+
+	dl, _ := newDownloader(t, sb)
+
+	transfers := fetch(t, sb)
+	require.Len(t, transfers, 1)
+
+	ctx, cancel := context.WithCancel(logctx.WithLogger(context.Background(), testLogger()))
+	defer cancel()
+
+	queue := make(chan *transfer.Transfer)
+	dl.WatchDownloads(ctx, queue)
+
+	failed := collect(dl.OnTransferDownloadError)
+
+	queue <- transfers[0]
+
+	select {
+	case <-failed:
+	case <-time.After(wedgeTimeout):
+		t.Fatal("the failing transfer never reported an error")
+	}
+
+	activity := dl.Activity()
+	require.Len(t, activity.Failures, 1)
+	assert.Equal(t, transfers[0].ID, activity.Failures[0].TransferID)
+	assert.NotEmpty(t, activity.Failures[0].Error)
+	assert.Empty(t, activity.Downloads, "a failed file must not linger as in progress")
+}
+
 // Nothing closes the event channels any more, so cancelling mid-download cannot
 // panic with a send on a closed channel. Cancellation while a download is in
 // flight is the window in which that used to be possible.
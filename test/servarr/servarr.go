@@ -0,0 +1,336 @@
+// Package servarr provides a fake Sonarr/Radarr for tests: a real HTTP server
+// that answers the history, status and command endpoints the way the real apps
+// do, from records the test supplies.
+//
+// The apps are the far end of import detection, and what matters about them is
+// exactly what a mock would paper over: how they filter history, that they
+// record download ids in upper case, and that older versions ignore filters they
+// do not know.
+package servarr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// APIKey is the key the fake accepts.
+const APIKey = "servarr-key"
+
+// Record is one history entry.
+type Record struct {
+	EventType string
+	// DownloadID is stored as given. The real apps store Transmission hashes in
+	// upper case, so tests mirroring them should too.
+	DownloadID  string
+	DroppedPath string
+	// Message is the app's explanation, as it gives for failures.
+	Message string
+}
+
+// epoch dates the first record. Each later one is a minute newer, so records are
+// dated in the order they were added.
+var epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// eventCodes numbers the event types the way the eventType filter does.
+var eventCodes = map[string]int{
+	"grabbed":                1,
+	"seriesFolderImported":   2,
+	"downloadFolderImported": 3,
+	"trackFileImported":      3,
+	"bookFileImported":       3,
+	"downloadFailed":         4,
+	"downloadIgnored":        7,
+}
+
+// Command is a command the app was asked to run.
+type Command struct {
+	ID               int    `json:"id"`
+	Name             string `json:"name"`
+	Path             string `json:"path"`
+	DownloadClientID string `json:"downloadClientId"`
+}
+
+// Servarr is a running fake *arr app.
+type Servarr struct {
+	srv *httptest.Server
+
+	mu sync.Mutex
+	// apiVersion is the only API version served: v3, as Sonarr and Radarr, or v1,
+	// as Lidarr and Readarr.
+	apiVersion string
+	records    []Record
+	// ignoreFilters serves every record whatever the query.
+	ignoreFilters bool
+	requests      []string
+	commands      []Command
+	// onCommand runs each command and returns its final status.
+	onCommand func(Command) string
+}
+
+// New starts a fake app with the given history, newest last, and stops it when
+// the test ends.
+func New(t *testing.T, records ...Record) *Servarr {
+	t.Helper()
+
+	s := &Servarr{records: records, apiVersion: "v3"}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.srv.Close)
+
+	return s
+}
+
+// URL is the app's base URL.
+func (s *Servarr) URL() string {
+	return s.srv.URL
+}
+
+// UseAPIVersion makes the app serve version instead of v3.
+func (s *Servarr) UseAPIVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apiVersion = version
+}
+
+// IgnoreFilters makes the app behave like a version without history filters.
+func (s *Servarr) IgnoreFilters() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ignoreFilters = true
+}
+
+// Add appends a history record, as the app does when something happens.
+func (s *Servarr) Add(r Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, r)
+}
+
+// OnCommand sets how commands run: fn is called each time a command is checked
+// on and returns its status. Without it every command completes having done
+// nothing.
+func (s *Servarr) OnCommand(fn func(Command) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onCommand = fn
+}
+
+// Commands returns every command the app was asked to run.
+func (s *Servarr) Commands() []Command {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Command(nil), s.commands...)
+}
+
+// Requests returns the query string of every history request so far, paged or
+// since a date.
+func (s *Servarr) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+type historyRecord struct {
+	ID         int               `json:"id"`
+	Date       time.Time         `json:"date"`
+	EventType  string            `json:"eventType"`
+	DownloadID string            `json:"downloadId,omitempty"`
+	Data       map[string]string `json:"data"`
+}
+
+func (s *Servarr) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Api-Key") != APIKey {
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	s.mu.Lock()
+	path, ok := strings.CutPrefix(r.URL.Path, "/api/"+s.apiVersion)
+	s.mu.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	}
+
+	switch path {
+	case "/system/status":
+		writeJSON(w, map[string]string{"appName": "Servarr", "version": "4.0.0"})
+	case "/history":
+		s.serveHistory(w, r)
+	case "/history/since":
+		s.serveHistorySince(w, r)
+	case "/command":
+		s.queueCommand(w, r)
+	default:
+		if id, ok := strings.CutPrefix(path, "/command/"); ok {
+			s.serveCommand(w, id)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Servarr) serveHistory(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r.URL.RawQuery)
+
+	q := r.URL.Query()
+
+	var matched []historyRecord
+
+	// Newest first, as the apps sort by date descending.
+	for i := len(s.records) - 1; i >= 0; i-- {
+		rec := s.records[i]
+
+		if !s.ignoreFilters {
+			// An exact match, as the apps' database query is.
+			if id := q.Get("downloadId"); id != "" && rec.DownloadID != id {
+				continue
+			}
+
+			if code := q.Get("eventType"); code != "" && strconv.Itoa(eventCodes[rec.EventType]) != code {
+				continue
+			}
+		}
+
+		matched = append(matched, s.historyRecord(i))
+	}
+
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(q.Get("pageSize"))
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	start := min((page-1)*pageSize, len(matched))
+	end := min(start+pageSize, len(matched))
+
+	writeJSON(w, map[string]any{
+		"page":         page,
+		"pageSize":     pageSize,
+		"totalRecords": len(matched),
+		"records":      matched[start:end],
+	})
+}
+
+// serveHistorySince serves every record from the date given on, oldest first
+// and unpaged, as the apps do.
+func (s *Servarr) serveHistorySince(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r.URL.RawQuery)
+
+	since, err := time.Parse(time.RFC3339Nano, r.URL.Query().Get("date"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	matched := []historyRecord{}
+
+	for i := range s.records {
+		if rec := s.historyRecord(i); !rec.Date.Before(since) {
+			matched = append(matched, rec)
+		}
+	}
+
+	writeJSON(w, matched)
+}
+
+// historyRecord is the i-th record, numbered and dated in the order added, as
+// the app serves it. s.mu must be held.
+func (s *Servarr) historyRecord(i int) historyRecord {
+	rec := s.records[i]
+
+	data := map[string]string{"droppedPath": rec.DroppedPath}
+	if rec.Message != "" {
+		data["message"] = rec.Message
+	}
+
+	return historyRecord{
+		ID:         i + 1,
+		Date:       epoch.Add(time.Duration(i) * time.Minute),
+		EventType:  rec.EventType,
+		DownloadID: rec.DownloadID,
+		Data:       data,
+	}
+}
+
+// queueCommand queues a command as the apps do: accepted, and not yet run.
+func (s *Servarr) queueCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	var cmd Command
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	s.mu.Lock()
+	cmd.ID = len(s.commands) + 1
+	s.commands = append(s.commands, cmd)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]any{"id": cmd.ID, "name": cmd.Name, "status": "queued"})
+}
+
+// serveCommand runs the command, outside the lock so that onCommand may Add to
+// the history.
+func (s *Servarr) serveCommand(w http.ResponseWriter, rawID string) {
+	id, _ := strconv.Atoi(rawID)
+
+	s.mu.Lock()
+	if id < 1 || id > len(s.commands) {
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	}
+
+	cmd := s.commands[id-1]
+	run := s.onCommand
+	s.mu.Unlock()
+
+	status := "completed"
+	if run != nil {
+		status = run(cmd)
+	}
+
+	writeJSON(w, map[string]any{"id": cmd.ID, "name": cmd.Name, "status": status})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}